@@ -0,0 +1,170 @@
+package logrus_appinsights
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultAggregationInterval = time.Minute
+
+// Aggregation is the way values of a field are aggregated client-side.
+type Aggregation int
+
+const (
+	// Counter sends the sum of the values.
+	Counter Aggregation = iota
+	// Gauge sends the last value.
+	Gauge
+	// Histogram sends the count, sum, minimum, maximum and standard
+	// deviation of the values.
+	Histogram
+)
+
+type aggregate struct {
+	name  string
+	kind  Aggregation
+	count int
+	sum   float64
+	last  float64
+	hist  *appinsights.AggregateMetricTelemetry
+}
+
+// aggregator accumulates the values of fields between flushes.
+type aggregator struct {
+	mu     sync.Mutex
+	fields map[string]*aggregate
+	start  time.Time
+	stop   chan struct{}
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{
+		fields: make(map[string]*aggregate),
+		start:  time.Now(),
+	}
+}
+
+// AddAggregation sets the numeric field to be aggregated client-side into the
+// metric metricName, sent at every aggregation interval instead of once per
+// entry.
+func (hook *AppInsightsHook) AddAggregation(field, metricName string, kind Aggregation) {
+	if hook.aggregates == nil {
+		hook.aggregates = newAggregator()
+		hook.SetAggregationInterval(defaultAggregationInterval)
+	}
+	hook.aggregates.mu.Lock()
+	hook.aggregates.fields[field] = &aggregate{name: metricName, kind: kind}
+	hook.aggregates.mu.Unlock()
+}
+
+// SetAggregationInterval sets how often aggregated metrics are sent, one
+// minute by default.
+func (hook *AppInsightsHook) SetAggregationInterval(interval time.Duration) {
+	if hook.aggregates == nil {
+		hook.aggregates = newAggregator()
+	}
+	a := hook.aggregates
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	a.stop = make(chan struct{})
+	go hook.runAggregation(interval, a.stop)
+}
+
+// FlushMetrics sends the metrics aggregated so far, e.g. before the
+// application exits.
+func (hook *AppInsightsHook) FlushMetrics() {
+	if hook.aggregates == nil {
+		return
+	}
+	for _, metric := range hook.aggregates.flush(time.Now()) {
+		hook.track(metric)
+	}
+}
+
+func (hook *AppInsightsHook) runAggregation(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			hook.FlushMetrics()
+		}
+	}
+}
+
+// observe adds the values of the aggregated fields of entry.
+func (a *aggregator) observe(entry *logrus.Entry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for field, agg := range a.fields {
+		value, ok := toFloat(entry.Data[field])
+		if !ok {
+			continue
+		}
+		agg.count++
+		agg.sum += value
+		agg.last = value
+		if agg.kind == Histogram {
+			if agg.hist == nil {
+				agg.hist = appinsights.NewAggregateMetricTelemetry(agg.name)
+			}
+			agg.hist.AddData([]float64{value})
+		}
+	}
+}
+
+// flush returns the metrics aggregated since the last flush, sorted by name,
+// and resets the aggregates.
+func (a *aggregator) flush(now time.Time) []appinsights.Telemetry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var metrics []appinsights.Telemetry
+	for _, agg := range a.fields {
+		if agg.count == 0 {
+			continue
+		}
+		switch agg.kind {
+		case Histogram:
+			agg.hist.Timestamp = a.start
+			metrics = append(metrics, agg.hist)
+		case Gauge:
+			metric := appinsights.NewMetricTelemetry(agg.name, agg.last)
+			metric.Timestamp = a.start
+			metrics = append(metrics, metric)
+		default:
+			metric := appinsights.NewMetricTelemetry(agg.name, agg.sum)
+			metric.Timestamp = a.start
+			metrics = append(metrics, metric)
+		}
+		agg.count, agg.sum, agg.last, agg.hist = 0, 0, 0, nil
+	}
+	a.start = now
+	sort.Slice(metrics, func(i, j int) bool {
+		return metricName(metrics[i]) < metricName(metrics[j])
+	})
+	return metrics
+}
+
+func metricName(item appinsights.Telemetry) string {
+	switch m := item.(type) {
+	case *appinsights.MetricTelemetry:
+		return m.Name
+	case *appinsights.AggregateMetricTelemetry:
+		return m.Name
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregator(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		kind     Aggregation
+		values   []interface{}
+		expected float64
+	}{
+		{Counter, nil, 0},
+		{Counter, []interface{}{1, 2, 3.5, "ignored"}, 6.5},
+		{Gauge, []interface{}{5, 7, 3}, 3},
+		{Histogram, []interface{}{2, 4, 9}, 15},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		a := newAggregator()
+		a.fields["queue_depth"] = &aggregate{name: "QueueDepth", kind: tt.kind}
+		start := a.start
+		for _, v := range tt.values {
+			a.observe(logrus.NewEntry(logrus.New()).WithField("queue_depth", v))
+		}
+		a.observe(logrus.NewEntry(logrus.New()).WithField("other", 1))
+
+		metrics := a.flush(time.Now())
+		if tt.values == nil {
+			assert.Empty(metrics, target)
+			continue
+		}
+		if !assert.Len(metrics, 1, target) {
+			continue
+		}
+		assert.Equal(start, metrics[0].Time(), target)
+		switch metric := metrics[0].(type) {
+		case *appinsights.MetricTelemetry:
+			assert.NotEqual(Histogram, tt.kind, target)
+			assert.Equal("QueueDepth", metric.Name, target)
+			assert.Equal(tt.expected, metric.Value, target)
+		case *appinsights.AggregateMetricTelemetry:
+			assert.Equal(Histogram, tt.kind, target)
+			assert.Equal(tt.expected, metric.Value, target)
+			assert.Equal(len(tt.values), metric.Count, target)
+			assert.Equal(2.0, metric.Min, target)
+			assert.Equal(9.0, metric.Max, target)
+		}
+
+		// reset once flushed
+		assert.Empty(a.flush(time.Now()), target)
+	}
+}
+
+func TestAddAggregation(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.AddAggregation("latency_ms", "Latency", Histogram)
+	hook.AddAggregation("queue_depth", "QueueDepth", Gauge)
+	defer hook.SetAggregationInterval(0)
+
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"latency_ms": 12, "queue_depth": 3})
+	hook.aggregates.observe(entry)
+	metrics := hook.aggregates.flush(time.Now())
+	if assert.Len(metrics, 2) {
+		assert.Equal("Latency", metricName(metrics[0]))
+		assert.Equal("QueueDepth", metricName(metrics[1]))
+	}
+}
@@ -0,0 +1,39 @@
+package logrus_appinsights
+
+import (
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// TrackAvailability records the result of a synthetic check run by the
+// application, sending it with the hook's client so it carries the same
+// context tags as the logs. props are handled as the fields of entries:
+// ignored, filtered, mapped, scrubbed and bounded. Processors are called with
+// the availability item and an Info entry with the name of the check as
+// message and props as fields.
+func (hook *AppInsightsHook) TrackAvailability(name string, duration time.Duration, success bool, props map[string]string) {
+	availability, entry := hook.buildAvailability(name, duration, success, props)
+	if items := hook.process(entry, []appinsights.Telemetry{availability}); len(items) > 0 {
+		hook.track(items...)
+	}
+}
+
+// buildAvailability returns the availability item of a check and the entry
+// it is processed with.
+func (hook *AppInsightsHook) buildAvailability(name string, duration time.Duration, success bool, props map[string]string) (*appinsights.AvailabilityTelemetry, *logrus.Entry) {
+	end := time.Now()
+	fields := make(logrus.Fields, len(props))
+	for k, v := range props {
+		fields[k] = v
+	}
+	entry := &logrus.Entry{Data: fields, Time: end, Level: logrus.InfoLevel, Message: name}
+
+	availability := appinsights.NewAvailabilityTelemetry(name, duration, success)
+	availability.MarkTime(end.Add(-duration), end)
+	hook.addFields(availability.Properties, fields, hook.plainStrings())
+	hook.finishProperties(availability.Properties)
+	hook.scrubItem(availability)
+	return availability, entry
+}
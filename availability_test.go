@@ -0,0 +1,62 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildAvailability(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		duration time.Duration
+		success  bool
+		props    map[string]string
+		expected map[string]string
+	}{
+		{time.Second, true, nil, map[string]string{}},
+		{time.Millisecond * 250, false, map[string]string{"probe": "login", "private": "secret", "region": "mars"}, map[string]string{"probe": "login", "region": OtherValue}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{ignoreFields: map[string]struct{}{"private": {}}}
+		hook.AddAllowedValues("region", "westeurope")
+
+		before := time.Now()
+		availability, entry := hook.buildAvailability("checkout", tt.duration, tt.success, tt.props)
+		assert.Equal("checkout", entry.Message, target)
+		assert.Len(entry.Data, len(tt.props), target)
+		assert.Equal("checkout", availability.Name, target)
+		assert.Equal(tt.duration, availability.Duration, target)
+		assert.Equal(tt.success, availability.Success, target)
+		assert.True(!availability.Timestamp.Before(before.Add(-tt.duration)), target)
+		assert.Equal(tt.expected, availability.Properties, target)
+	}
+}
+
+func TestTrackAvailability(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingClient()
+	hook := AppInsightsHook{client: client, ignoreFields: make(map[string]struct{})}
+	hook.SetPIIScrubbing(EmailAddresses)
+	hook.Use(func(item appinsights.Telemetry, entry *logrus.Entry) bool {
+		return entry.Data["probe"] != "skipped"
+	})
+
+	hook.TrackAvailability("checkout", time.Second, true, map[string]string{"probe": "login", "owner": "alice@example.com"})
+	hook.TrackAvailability("checkout", time.Second, true, map[string]string{"probe": "skipped"})
+	tracked := client.tracked()
+	if assert.Len(tracked, 1) {
+		props := tracked[0].GetProperties()
+		assert.Equal("login", props["probe"])
+		assert.NotContains(props["owner"], "alice@example.com")
+	}
+}
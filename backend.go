@@ -0,0 +1,103 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend is where the hook sends telemetry.
+type Backend int
+
+const (
+	// ApplicationInsightsBackend sends telemetry to the Application Insights
+	// ingestion endpoint with the Application Insights SDK.
+	ApplicationInsightsBackend Backend = iota
+	// OTLPBackend exports telemetry as OTLP logs to the OTLPEndpoint only,
+	// e.g. to reach Azure Monitor through the azuremonitor exporter of an
+	// OpenTelemetry Collector. No instrumentation key is needed. There is no
+	// backend exporting to Azure Monitor in process, as Azure Monitor offers
+	// no OpenTelemetry exporter for Go.
+	OTLPBackend
+)
+
+// backend returns the backend selected by conf, OTLPBackend if the
+// deprecated OTLPOnly is set.
+func (conf Config) backend() Backend {
+	if conf.OTLPOnly {
+		return OTLPBackend
+	}
+	return conf.Backend
+}
+
+// AADScope is the scope of the Azure AD access tokens authenticating
+// ingestion.
+const AADScope = "https://monitor.azure.com//.default"
+
+// aadTokenRefresh is how long before they expire access tokens are renewed.
+const aadTokenRefresh = 5 * time.Minute
+
+// TokenProvider returns an Azure AD access token for scope and when it
+// expires, e.g. with the GetToken method of an azidentity credential.
+type TokenProvider func(ctx context.Context, scope string) (token string, expires time.Time, err error)
+
+// bearerTransport authenticates the requests it sends with an Azure AD access
+// token.
+type bearerTransport struct {
+	base     http.RoundTripper
+	provider TokenProvider
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newBearerTransport(base http.RoundTripper, provider TokenProvider) *bearerTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &bearerTransport{base: base, provider: provider}
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.accessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	authenticated := req.Clone(req.Context())
+	authenticated.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(authenticated)
+}
+
+// accessToken returns the cached token, renewed once about to expire.
+func (t *bearerTransport) accessToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Add(aadTokenRefresh).Before(t.expires) {
+		return t.token, nil
+	}
+	token, expires, err := t.provider(ctx, AADScope)
+	if err != nil {
+		return "", err
+	}
+	t.token, t.expires = token, expires
+	return token, nil
+}
+
+// close closes the base transport if it can be.
+func (t *bearerTransport) close() {
+	if base, ok := t.base.(interface{ close() }); ok {
+		base.close()
+	}
+}
+
+// aadEndpoint returns the ingestion endpoint accepting Azure AD
+// authenticated telemetry in place of endpoint.
+func aadEndpoint(endpoint string) string {
+	if strings.HasSuffix(endpoint, "/v2/track") {
+		return strings.TrimSuffix(endpoint, "/v2/track") + "/v2.1/track"
+	}
+	return endpoint
+}
@@ -0,0 +1,176 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBearerTransport(t *testing.T) {
+	assert := assert.New(t)
+
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		expiresIn time.Duration
+		err       error
+		expected  []string
+	}{
+		{time.Hour, nil, []string{"Bearer token1", "Bearer token1"}},
+		// renewed once about to expire
+		{time.Minute, nil, []string{"Bearer token1", "Bearer token2"}},
+		{time.Hour, errors.New("unauthorized"), nil},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		authorizations = nil
+		var scopes []string
+		issued := 0
+		transport := newBearerTransport(nil, func(ctx context.Context, scope string) (string, time.Time, error) {
+			scopes = append(scopes, scope)
+			issued++
+			return fmt.Sprintf("token%d", issued), time.Now().Add(tt.expiresIn), tt.err
+		})
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("POST", server.URL, strings.NewReader("batch"))
+			resp, err := transport.RoundTrip(req)
+			if tt.err != nil {
+				assert.Equal(tt.err, err, target)
+				continue
+			}
+			assert.NoError(err, target)
+			resp.Body.Close()
+			assert.Empty(req.Header.Get("Authorization"), target)
+		}
+		assert.Equal(tt.expected, authorizations, target)
+		assert.Equal(AADScope, scopes[0], target)
+	}
+}
+
+func TestAADEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		endpoint string
+		expected string
+	}{
+		{"https://dc.services.visualstudio.com/v2/track", "https://dc.services.visualstudio.com/v2.1/track"},
+		{"https://westeurope-5.in.applicationinsights.azure.com/v2.1/track", "https://westeurope-5.in.applicationinsights.azure.com/v2.1/track"},
+		{"http://localhost:8080/relay", "http://localhost:8080/relay"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Equal(tt.expected, aadEndpoint(tt.endpoint), target)
+	}
+}
+
+func TestAADIngestion(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	var paths, authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	hook, err := New("test", Config{
+		InstrumentationKey: "NotEmpty",
+		EndpointUrl:        server.URL + "/v2/track",
+		AADTokenProvider: func(ctx context.Context, scope string) (string, time.Time, error) {
+			return "token", time.Now().Add(time.Hour), nil
+		},
+	})
+	assert.NoError(err)
+	defer hook.Close()
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "authenticated"
+	assert.NoError(hook.FireAndWait(entry))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal([]string{"/v2.1/track"}, paths)
+	assert.Equal([]string{"Bearer token"}, authorizations)
+}
+
+func TestOTLPBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	_, err := New("test", Config{Backend: OTLPBackend})
+	assert.Error(err)
+
+	// no instrumentation key needed
+	hook, err := New("test", Config{Backend: OTLPBackend, OTLPEndpoint: server.URL + "/v1/logs"})
+	assert.NoError(err)
+	defer hook.Close()
+	assert.NotNil(hook.otlpClient())
+	assert.False(hook.otlpClient().forward)
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "exported"
+	assert.NoError(hook.Fire(entry))
+	select {
+	case body := <-received:
+		assert.Contains(body, "exported")
+	case <-time.After(5 * time.Second):
+		t.Fatal("logs not exported")
+	}
+}
+
+func TestConfigBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		backend  Backend
+		otlpOnly bool
+		expected Backend
+	}{
+		{ApplicationInsightsBackend, false, ApplicationInsightsBackend},
+		{OTLPBackend, false, OTLPBackend},
+		// the deprecated OTLPOnly selects the OTLP backend
+		{ApplicationInsightsBackend, true, OTLPBackend},
+		{OTLPBackend, true, OTLPBackend},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		conf := Config{InstrumentationKey: "NotEmpty", Backend: tt.backend, OTLPOnly: tt.otlpOnly, OTLPEndpoint: "http://localhost:4318/v1/logs"}
+		assert.Equal(tt.expected, conf.backend(), target)
+
+		hook, err := New("test", conf)
+		if !assert.NoError(err, target) {
+			continue
+		}
+		assert.Equal(tt.expected == OTLPBackend, hook.otlpOnly(), target)
+		hook.Close()
+	}
+}
@@ -0,0 +1,53 @@
+package logrus_appinsights
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// AddBaggageKeys selects baggage members sent as properties of every item
+// logged with a context carrying them, so cross-cutting attributes such as
+// the tenant or a feature flag cohort travel with every entry. The baggage is
+// read by the Baggage function of the span bridge.
+func (hook *AppInsightsHook) AddBaggageKeys(keys ...string) {
+	if hook.baggageKeys == nil {
+		hook.baggageKeys = make(map[string]struct{})
+	}
+	for _, key := range keys {
+		hook.baggageKeys[key] = struct{}{}
+	}
+}
+
+// AddContextValue sends the value stored under key in the context of entries
+// as the property, if any.
+func (hook *AppInsightsHook) AddContextValue(property string, key interface{}) {
+	if hook.contextValues == nil {
+		hook.contextValues = make(map[string]interface{})
+	}
+	hook.contextValues[property] = key
+}
+
+// addBaggageProperties adds the selected baggage members and context values
+// of the entry's context that are not already set.
+func (hook *AppInsightsHook) addBaggageProperties(props map[string]string, entry *logrus.Entry) {
+	if entry.Context == nil {
+		return
+	}
+	if len(hook.baggageKeys) > 0 && hook.spans.Baggage != nil {
+		for k, v := range hook.spans.Baggage(entry.Context) {
+			if _, ok := hook.baggageKeys[k]; !ok {
+				continue
+			}
+			if _, ok := props[k]; !ok {
+				hook.addProperty(props, k, v)
+			}
+		}
+	}
+	for property, key := range hook.contextValues {
+		if _, ok := props[property]; ok {
+			continue
+		}
+		if v := entry.Context.Value(key); v != nil {
+			hook.addProperty(props, property, v)
+		}
+	}
+}
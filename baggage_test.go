@@ -0,0 +1,61 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type baggageKey struct{}
+
+type cohortKey struct{}
+
+func TestBaggage(t *testing.T) {
+	assert := assert.New(t)
+
+	bridge := SpanBridge{
+		Baggage: func(ctx context.Context) map[string]string {
+			members, _ := ctx.Value(baggageKey{}).(map[string]string)
+			return members
+		},
+	}
+
+	tests := []struct {
+		baggage  map[string]string
+		cohort   interface{}
+		fields   logrus.Fields
+		expected map[string]string
+	}{
+		{nil, nil, nil, map[string]string{}},
+		{map[string]string{"tenant": "contoso", "secret": "s"}, nil, nil, map[string]string{"tenant": "contoso"}},
+		{map[string]string{"tenant": "contoso"}, "beta", nil, map[string]string{"tenant": "contoso", "cohort": "beta"}},
+		{map[string]string{"tenant": "contoso"}, 7, logrus.Fields{"tenant": "fabrikam"}, map[string]string{"tenant": "fabrikam", "cohort": "7"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetSpanBridge(bridge)
+		hook.AddBaggageKeys("tenant")
+		hook.AddContextValue("cohort", cohortKey{})
+		ctx := context.WithValue(context.Background(), baggageKey{}, tt.baggage)
+		if tt.cohort != nil {
+			ctx = context.WithValue(ctx, cohortKey{}, tt.cohort)
+		}
+		entry := logrus.NewEntry(logrus.New()).WithContext(ctx).WithFields(tt.fields)
+
+		props := hook.buildProperties(entry)
+		for _, k := range []string{"tenant", "cohort", "secret"} {
+			v, ok := tt.expected[k]
+			if ok {
+				assert.Equal(v, props[k], target)
+			} else {
+				assert.NotContains(props, k, target)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package logrus_appinsights
+
+import (
+	"sync"
+	"time"
+)
+
+// adaptiveBatcher flushes the client's channel before its batches are full
+// or due when few items are logged, scaling the batch size and interval
+// between their minimum and maximum with the rate of items. The client still
+// cuts batches at the maximum size and interval.
+type adaptiveBatcher struct {
+	minSize     int
+	maxSize     int
+	minInterval time.Duration
+	maxInterval time.Duration
+
+	mu      sync.Mutex
+	pending int       // items since the last flush
+	last    time.Time // time of the last flush
+	seen    int       // items since the rate was last measured
+	start   time.Time
+	average float64 // items per second
+	now     func() time.Time
+
+	kick     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once // pipelines share the batcher of their hook
+}
+
+func newAdaptiveBatcher(minSize, maxSize int, minInterval, maxInterval time.Duration) *adaptiveBatcher {
+	if minSize < 1 {
+		minSize = 1
+	}
+	if maxSize < minSize {
+		maxSize = minSize
+	}
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+	now := time.Now()
+	return &adaptiveBatcher{
+		minSize:     minSize,
+		maxSize:     maxSize,
+		minInterval: minInterval,
+		maxInterval: maxInterval,
+		last:        now,
+		start:       now,
+		now:         time.Now,
+		kick:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+	}
+}
+
+// load returns the share of a full batch logged within the maximum interval,
+// from 0 when idle to 1 under load.
+func (b *adaptiveBatcher) load() float64 {
+	rate := b.average
+	// bursts count before the rate is next measured
+	if current := float64(b.seen) / b.minInterval.Seconds(); current > rate {
+		rate = current
+	}
+	load := rate * b.maxInterval.Seconds() / float64(b.maxSize)
+	if load > 1 {
+		return 1
+	}
+	return load
+}
+
+// batch returns the current batch size and interval.
+func (b *adaptiveBatcher) batch() (int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.batchLocked()
+}
+
+func (b *adaptiveBatcher) batchLocked() (int, time.Duration) {
+	load := b.load()
+	size := b.minSize + int(load*float64(b.maxSize-b.minSize))
+	interval := b.minInterval + time.Duration(load*float64(b.maxInterval-b.minInterval))
+	return size, interval
+}
+
+// observe counts n items tracked, flushing once a batch is complete.
+func (b *adaptiveBatcher) observe(n int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.pending += n
+	b.seen += n
+	size, _ := b.batchLocked()
+	full := b.pending >= size && size < b.maxSize // the client cuts full batches itself
+	b.mu.Unlock()
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default: // a flush is already due
+		}
+	}
+}
+
+// due measures the rate of items and reports whether the pending items must
+// be flushed.
+func (b *adaptiveBatcher) due(kicked bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if elapsed := now.Sub(b.start); elapsed >= b.minInterval {
+		observed := float64(b.seen) / elapsed.Seconds()
+		b.average = b.average*(1-adaptiveRatio) + observed*adaptiveRatio
+		b.seen, b.start = 0, now
+	}
+	size, interval := b.batchLocked()
+	if b.pending == 0 || (!kicked && now.Sub(b.last) < interval) || (kicked && b.pending < size) {
+		return false
+	}
+	b.pending, b.last = 0, now
+	return true
+}
+
+// run flushes with flush whenever a batch is complete or due, until stopped.
+func (b *adaptiveBatcher) run(flush func()) {
+	ticker := time.NewTicker(b.minInterval)
+	defer ticker.Stop()
+	for {
+		kicked := false
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		case <-b.kick:
+			kicked = true
+		}
+		if b.due(kicked) {
+			flush()
+		}
+	}
+}
+
+// close stops flushing.
+func (b *adaptiveBatcher) close() {
+	if b != nil {
+		b.stopOnce.Do(func() { close(b.stop) })
+	}
+}
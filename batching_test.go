@@ -0,0 +1,102 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveBatcherBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		average  float64
+		seen     int
+		size     int
+		interval time.Duration
+	}{
+		{0, 0, 10, time.Second},
+		{25, 0, 255, 5500 * time.Millisecond},
+		{100, 0, 500, 10 * time.Second},
+		{1000, 0, 500, 10 * time.Second},
+		{0, 100, 500, 10 * time.Second}, // a burst before the rate is measured
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		b := newAdaptiveBatcher(10, 500, time.Second, 10*time.Second)
+		b.average, b.seen = tt.average, tt.seen
+		size, interval := b.batch()
+		assert.Equal(tt.size, size, target)
+		assert.Equal(tt.interval, interval, target)
+	}
+}
+
+func TestAdaptiveBatcherDue(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		items   int
+		elapsed time.Duration
+		kicked  bool
+		due     bool
+	}{
+		{0, 2 * time.Second, false, false},
+		{1, 500 * time.Millisecond, false, false},
+		{1, 2 * time.Second, false, true},
+		{2, 0, true, false},
+		{5, 0, true, true},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		now := time.Now()
+		b := newAdaptiveBatcher(5, 500, time.Second, 10*time.Second)
+		b.now = func() time.Time { return now }
+		b.last = now.Add(-tt.elapsed)
+		b.observe(tt.items)
+		b.seen = 0 // keep the load minimal
+		assert.Equal(tt.due, b.due(tt.kicked), target)
+		if tt.due {
+			assert.Zero(b.pending, target)
+		}
+	}
+}
+
+func TestNewWithAdaptiveBatching(t *testing.T) {
+	assert := assert.New(t)
+
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	defer server.Close()
+
+	hook, err := New("test", Config{
+		InstrumentationKey: "NotEmpty",
+		EndpointUrl:        server.URL,
+		MaxBatchSize:       1000,
+		MaxBatchInterval:   time.Minute,
+		MinBatchInterval:   10 * time.Millisecond,
+	})
+	assert.NoError(err)
+	defer hook.Close()
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "fresh"
+	assert.NoError(hook.Fire(entry))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&received) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&received))
+}
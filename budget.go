@@ -0,0 +1,382 @@
+package logrus_appinsights
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OverflowPolicy decides what happens to an entry that would push the
+// pending telemetry over its memory budget.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the entry that would exceed the budget.
+	DropNewest OverflowPolicy = iota
+	// Block makes Fire wait until enough pending telemetry has been
+	// delivered, or given up on by the client.
+	Block
+)
+
+// envelopeOverhead approximates the serialized size of the envelope parts
+// that do not depend on the entry (name, time, tags, source properties).
+const envelopeOverhead = 512
+
+// pendingBudget bounds the approximate number of bytes held by traces that
+// have been fired asynchronously but not yet handed over to the client, and
+// by the batches of the client until they complete.
+type pendingBudget struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	max      int64
+	bytes    int64
+	policy   OverflowPolicy
+	priority bool // reserve errorReserve of the budget for urgent entries
+
+	// batches accounts the telemetry handed over to the client, if set
+	batches *batchBytes
+}
+
+// errorReserve is the share of the pending budget reserved for Error, Fatal
+// and Panic entries when they are prioritized.
+const errorReserve = 0.25
+
+func (b *pendingBudget) enabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.max > 0
+}
+
+// acquire reserves n bytes for an entry, urgent if of Error level or above,
+// returning false if the entry must be dropped.
+func (b *pendingBudget) acquire(n int64, urgent bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.max <= 0 {
+		return true
+	}
+	max := b.max
+	if b.priority && !urgent {
+		max -= int64(float64(b.max) * errorReserve)
+	}
+	for held := b.batches.held(); b.bytes+held > 0 && b.bytes+held+n > max; held = b.batches.held() {
+		if b.policy != Block {
+			return false
+		}
+		if b.cond == nil {
+			b.cond = sync.NewCond(&b.mu)
+		}
+		// bytes expiring release nothing, so wake up once they do
+		if expiry, ok := b.batches.nextExpiry(); ok {
+			timer := time.AfterFunc(time.Until(expiry), b.notify)
+			b.cond.Wait()
+			timer.Stop()
+		} else {
+			b.cond.Wait()
+		}
+	}
+	b.bytes += n
+	return true
+}
+
+// release returns n previously acquired bytes to the budget.
+func (b *pendingBudget) release(n int64) {
+	if n == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.bytes -= n
+	if b.cond != nil {
+		b.cond.Broadcast()
+	}
+	b.mu.Unlock()
+}
+
+// handOff releases n previously acquired bytes of an entry whose items were
+// handed over to the client, accounted instead until their batch completes.
+func (b *pendingBudget) handOff(n int64, items int) {
+	if n == 0 {
+		return
+	}
+	b.batches.handOff(n, items)
+	b.release(n)
+}
+
+// notify wakes up the entries waiting for the budget.
+func (b *pendingBudget) notify() {
+	b.mu.Lock()
+	if b.cond != nil {
+		b.cond.Broadcast()
+	}
+	b.mu.Unlock()
+}
+
+// submissionAttempts is how many times the client submits a batch before
+// giving up on it: once, then once per retry.
+const submissionAttempts = 4
+
+// submissionWindow bounds how long the envelopes of a batch are accounted
+// after their last submission, should the client give up on them unseen, e.g.
+// when not retrying on Close.
+const submissionWindow = 5 * time.Minute
+
+// bufferedWindow is how long, on top of the batch interval, items handed
+// over to the client are accounted before their batch is submitted.
+const bufferedWindow = 10 * time.Second
+
+// batchBytes accounts the telemetry handed over to the client until the
+// batch holding it completes: while the client buffers it, then by the actual
+// size of its envelopes while they are submitted and awaiting retries.
+type batchBytes struct {
+	mu       sync.Mutex
+	tracking bool
+	budgets  []*pendingBudget
+
+	// window bounds how long handed over telemetry may be buffered
+	window       time.Duration
+	buffered     []bufferedBytes
+	bufferedSize int64
+
+	lines         map[[sha256.Size]byte]*submittedLine
+	submittedSize int64
+}
+
+// bufferedBytes is the estimated size of an item handed over to the client.
+type bufferedBytes struct {
+	size int64
+	at   time.Time
+}
+
+// submittedLine is an envelope of a submitted batch.
+type submittedLine struct {
+	size     int64
+	attempts int
+	expires  time.Time
+}
+
+func newBatchBytes(window time.Duration) *batchBytes {
+	return &batchBytes{window: window, lines: make(map[[sha256.Size]byte]*submittedLine)}
+}
+
+// watch sets b to account the telemetry of budget, notified when bytes are
+// released.
+func (b *batchBytes) watch(budget *pendingBudget) {
+	budget.batches = b
+	b.mu.Lock()
+	b.budgets = append(b.budgets, budget)
+	b.mu.Unlock()
+}
+
+// track enables accounting, once a budget is set.
+func (b *batchBytes) track() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.tracking = true
+	b.mu.Unlock()
+}
+
+func (b *batchBytes) isTracking() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tracking
+}
+
+// held returns the bytes accounted.
+func (b *batchBytes) held() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(time.Now())
+	return b.bufferedSize + b.submittedSize
+}
+
+// nextExpiry returns when the first accounted bytes expire, if any.
+func (b *batchBytes) nextExpiry() (time.Time, bool) {
+	if b == nil {
+		return time.Time{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var next time.Time
+	if len(b.buffered) > 0 {
+		next = b.buffered[0].at.Add(b.window)
+	}
+	for _, line := range b.lines {
+		if next.IsZero() || line.expires.Before(next) {
+			next = line.expires
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// expire releases the bytes of the items buffered for longer than the window
+// and of the envelopes not submitted again within submissionWindow.
+func (b *batchBytes) expire(now time.Time) {
+	for len(b.buffered) > 0 && now.Sub(b.buffered[0].at) > b.window {
+		b.bufferedSize -= b.buffered[0].size
+		b.buffered = b.buffered[1:]
+	}
+	for sum, line := range b.lines {
+		if now.After(line.expires) {
+			b.submittedSize -= line.size
+			delete(b.lines, sum)
+		}
+	}
+}
+
+// handOff accounts n bytes of items handed over to the client, until the
+// batch holding them is submitted.
+func (b *batchBytes) handOff(n int64, items int) {
+	if b == nil {
+		return
+	}
+	now := time.Now()
+	b.mu.Lock()
+	for i := 0; i < items; i++ {
+		// the estimate covers every item of the entry
+		size := int64(0)
+		if i == 0 {
+			size = n
+		}
+		b.buffered = append(b.buffered, bufferedBytes{size, now})
+	}
+	b.bufferedSize += n
+	b.mu.Unlock()
+}
+
+// submit accounts the envelopes of a submitted batch by their actual size,
+// releasing as many buffered items, and returns their sums to complete.
+func (b *batchBytes) submit(payload []byte, gzipped bool) [][sha256.Size]byte {
+	var reader io.Reader = bytes.NewReader(payload)
+	if gzipped {
+		gzipReader, err := getGzipReader(reader)
+		if err != nil {
+			return nil
+		}
+		defer putGzipReader(gzipReader)
+		reader = gzipReader
+	}
+	var sums [][sha256.Size]byte
+	var sizes []int64
+	buf := scanBuffers.Get().(*[]byte)
+	defer scanBuffers.Put(buf)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(*buf, 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		sums = append(sums, sha256.Sum256(scanner.Bytes()))
+		sizes = append(sizes, int64(len(scanner.Bytes())))
+	}
+
+	now := time.Now()
+	b.mu.Lock()
+	for i, sum := range sums {
+		line, ok := b.lines[sum]
+		if !ok {
+			line = &submittedLine{size: sizes[i]}
+			b.lines[sum] = line
+			b.submittedSize += line.size
+			if len(b.buffered) > 0 {
+				b.bufferedSize -= b.buffered[0].size
+				b.buffered = b.buffered[1:]
+			}
+		}
+		line.attempts++
+		line.expires = now.Add(submissionWindow)
+	}
+	b.mu.Unlock()
+	b.notify()
+	return sums
+}
+
+// complete releases the envelopes of a submitted batch the client will not
+// submit again, according to the outcome of the submission.
+func (b *batchBytes) complete(sums [][sha256.Size]byte, resp *http.Response, err error) {
+	if len(sums) == 0 {
+		return
+	}
+	retried := make(map[int]bool, len(sums))
+	switch {
+	case err != nil:
+		for i := range sums {
+			retried[i] = true
+		}
+	case resp.StatusCode == http.StatusPartialContent:
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		response, _ := decodeResponse(body, resp.Header.Get("Content-Encoding") == "gzip")
+		for _, e := range response.Errors {
+			retried[e.Index] = clientRetries(e.StatusCode)
+		}
+	case clientRetries(resp.StatusCode) || resp.Header.Get("Retry-After") != "":
+		for i := range sums {
+			retried[i] = true
+		}
+	}
+
+	b.mu.Lock()
+	for i, sum := range sums {
+		line, ok := b.lines[sum]
+		if ok && (!retried[i] || line.attempts >= submissionAttempts) {
+			b.submittedSize -= line.size
+			delete(b.lines, sum)
+		}
+	}
+	b.mu.Unlock()
+	b.notify()
+}
+
+// clientRetries reports whether the client submits a batch, or an envelope
+// of a partially accepted one, again after a failure with status.
+func clientRetries(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, 439,
+		http.StatusInternalServerError, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// notify wakes up the entries waiting for the budgets.
+func (b *batchBytes) notify() {
+	b.mu.Lock()
+	budgets := b.budgets
+	b.mu.Unlock()
+	for _, budget := range budgets {
+		budget.notify()
+	}
+}
+
+// estimateSize returns the approximate serialized size of the trace built
+// from entry.
+func estimateSize(entry *logrus.Entry) int64 {
+	// the message is sent both as the trace message and as a property
+	size := envelopeOverhead + 2*len(entry.Message)
+	for k, v := range entry.Data {
+		size += len(k)
+		if s, ok := v.(string); ok {
+			size += len(s)
+		} else {
+			size += len(fmt.Sprintf("%v", formatData(v)))
+		}
+	}
+	return int64(size)
+}
@@ -0,0 +1,220 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMaxPendingBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	assert.False(hook.pending.enabled())
+
+	hook.SetMaxPendingBytes(1024)
+	assert.True(hook.pending.enabled())
+	assert.Equal(int64(1024), hook.pending.max)
+
+	hook.SetMaxPendingBytes(0)
+	assert.False(hook.pending.enabled())
+}
+
+func TestPendingBudgetDropNewest(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		max      int64
+		priority bool
+		sizes    []int64
+		urgent   []bool
+		accepted []bool
+	}{
+		{0, false, []int64{100, 100}, []bool{false, false}, []bool{true, true}},
+		{100, false, []int64{60, 40, 1}, []bool{false, false, false}, []bool{true, true, false}},
+		{100, false, []int64{500, 1}, []bool{false, false}, []bool{true, false}},
+		{100, false, []int64{60, 20, 20}, []bool{false, false, true}, []bool{true, true, true}},
+		{100, true, []int64{60, 20, 20}, []bool{false, false, true}, []bool{true, false, true}},
+		{100, true, []int64{60, 15, 25, 1}, []bool{false, false, true, true}, []bool{true, true, true, false}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		b := pendingBudget{max: tt.max, priority: tt.priority}
+		for i, size := range tt.sizes {
+			assert.Equal(tt.accepted[i], b.acquire(size, tt.urgent[i]), target)
+		}
+	}
+}
+
+func TestPendingBudgetBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	b := pendingBudget{max: 100, policy: Block}
+	assert.True(b.acquire(80, false))
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- b.acquire(80, false)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire did not block while the budget was exhausted")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	b.release(80)
+	assert.True(<-acquired)
+	assert.Equal(int64(80), b.bytes)
+}
+
+func TestPendingBudgetBlockUntilExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	batches := newBatchBytes(50 * time.Millisecond)
+	b := &pendingBudget{max: 100, policy: Block}
+	batches.watch(b)
+	batches.track()
+	assert.True(b.acquire(80, false))
+	b.handOff(80, 1)
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- b.acquire(80, false)
+	}()
+
+	// the handed over bytes are never submitted, but expire
+	select {
+	case ok := <-acquired:
+		assert.True(ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire still blocked once the pending bytes expired")
+	}
+	assert.Equal(int64(0), batches.held())
+}
+
+func TestBatchBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	partial := `{"itemsReceived":2,"itemsAccepted":0,"errors":[{"index":0,"statusCode":500},{"index":1,"statusCode":400}]}`
+	tests := []struct {
+		status   int
+		body     string
+		header   string
+		err      error
+		attempts int
+		held     int64
+	}{
+		{http.StatusOK, "", "", nil, 1, 0},
+		{http.StatusBadRequest, "", "", nil, 1, 0},
+		{StatusStoredOffline, "", "", nil, 1, 0},
+		{http.StatusServiceUnavailable, "", "", nil, 1, 14},
+		{http.StatusBadGateway, "", "", nil, 1, 0},
+		{http.StatusBadGateway, "", "Mon, 02 Jan 2006 15:04:05 GMT", nil, 1, 14},
+		{0, "", "", errors.New("timeout"), 1, 14},
+		{http.StatusServiceUnavailable, "", "", nil, submissionAttempts, 0},
+		{http.StatusPartialContent, partial, "", nil, 1, 7},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		b := newBatchBytes(time.Minute)
+		budget := &pendingBudget{max: 1000}
+		b.watch(budget)
+		b.track()
+		assert.True(budget.acquire(100, false), target)
+		budget.handOff(100, 2)
+		assert.Equal(int64(100), b.held(), target)
+
+		for i := 0; i < tt.attempts; i++ {
+			sums := b.submit([]byte("{\"a\":1}\n{\"b\":2}\n"), false)
+			assert.Len(sums, 2, target)
+			assert.Equal(int64(14), b.held(), target)
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status, Header: make(http.Header), Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+				if tt.header != "" {
+					resp.Header.Set("Retry-After", tt.header)
+				}
+			}
+			b.complete(sums, resp, tt.err)
+		}
+		assert.Equal(tt.held, b.held(), target)
+		assert.Equal(int64(0), budget.bytes, target)
+	}
+}
+
+func TestPendingBytesUntilDelivered(t *testing.T) {
+	assert := assert.New(t)
+
+	submitted := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case submitted <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer server.Close()
+
+	hook, err := New("test", Config{
+		InstrumentationKey: "NotEmpty",
+		EndpointUrl:        server.URL,
+		MaxBatchInterval:   10 * time.Millisecond,
+		MaxPendingBytes:    4096,
+	})
+	assert.NoError(err)
+	hook.SetAsync(true)
+	defer hook.Close()
+
+	fire := func() Disposition {
+		receipt := NewReceipt()
+		entry := logrus.NewEntry(logrus.New()).WithField(ReceiptKey, receipt)
+		entry.Message = string(bytes.Repeat([]byte("x"), 1024))
+		assert.NoError(hook.Fire(entry))
+		<-receipt.Done()
+		disposition, _ := receipt.Disposition()
+		return disposition
+	}
+	assert.Equal(Sent, fire())
+
+	// accounted while the batch is submitted
+	<-submitted
+	assert.True(hook.pending.batches.held() > 1024)
+	assert.Equal(Dropped, fire())
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for hook.pending.batches.held() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("batch not completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(Sent, fire())
+}
+
+func TestEstimateSize(t *testing.T) {
+	assert := assert.New(t)
+
+	entry := logrus.NewEntry(logrus.New())
+	empty := estimateSize(entry)
+	assert.Equal(int64(envelopeOverhead), empty)
+
+	entry.Message = "hello"
+	entry.Data = logrus.Fields{"key": "value", "n": 42}
+	assert.Equal(empty+10+8+3, estimateSize(entry))
+}
@@ -0,0 +1,139 @@
+package logrus_appinsights
+
+import (
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+)
+
+// bufferedClient hands items over to the wrapped client through a buffer, so
+// tracking only blocks once the buffer is full.
+type bufferedClient struct {
+	appinsights.TelemetryClient
+
+	items   chan appinsights.Telemetry
+	urgent  chan appinsights.Telemetry // nil unless errors are prioritized
+	mu      sync.Mutex
+	drained *sync.Cond
+	pending int
+
+	stopped  bool
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newBufferedClient returns a client buffering size items. If prioritize is
+// set, Error and Critical items are buffered apart and handed over first.
+func newBufferedClient(client appinsights.TelemetryClient, size int, prioritize bool) *bufferedClient {
+	c := &bufferedClient{
+		TelemetryClient: client,
+		items:           make(chan appinsights.Telemetry, size),
+		done:            make(chan struct{}),
+	}
+	if prioritize {
+		c.urgent = make(chan appinsights.Telemetry, size)
+	}
+	c.drained = sync.NewCond(&c.mu)
+	go c.run()
+	return c
+}
+
+func (c *bufferedClient) run() {
+	for {
+		var item appinsights.Telemetry
+		select {
+		case item = <-c.urgent:
+		default:
+			select {
+			case item = <-c.urgent:
+			case item = <-c.items:
+			case <-c.done:
+				return
+			}
+		}
+		c.TelemetryClient.Track(item)
+		c.mu.Lock()
+		c.pending--
+		if c.pending == 0 {
+			c.drained.Broadcast()
+		}
+		c.mu.Unlock()
+	}
+}
+
+// stop stops handing items over; items buffered afterwards are dropped.
+func (c *bufferedClient) stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		c.stopped = true
+		c.drained.Broadcast()
+		c.mu.Unlock()
+	})
+}
+
+// wait returns once the buffered items have been handed over.
+func (c *bufferedClient) wait() {
+	c.mu.Lock()
+	for c.pending > 0 && !c.stopped {
+		c.drained.Wait()
+	}
+	c.mu.Unlock()
+}
+
+func (c *bufferedClient) Track(item appinsights.Telemetry) {
+	if !c.IsEnabled() {
+		return
+	}
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return
+	}
+	c.pending++
+	c.mu.Unlock()
+	items := c.items
+	if c.urgent != nil && isUrgent(item) {
+		items = c.urgent
+	}
+	select {
+	case items <- item:
+	case <-c.done:
+	}
+}
+
+// isUrgent reports whether item is a trace or exception of Error severity or
+// above.
+func isUrgent(item appinsights.Telemetry) bool {
+	switch item := item.(type) {
+	case *appinsights.TraceTelemetry:
+		return item.SeverityLevel >= appinsights.Error
+	case *appinsights.ExceptionTelemetry:
+		return item.SeverityLevel >= appinsights.Error
+	case *typedException:
+		return item.SeverityLevel >= appinsights.Error
+	}
+	return false
+}
+
+// Channel returns the channel of the wrapped client, handing the buffered
+// items over before flushing or closing it.
+func (c *bufferedClient) Channel() appinsights.TelemetryChannel {
+	return &bufferedChannel{c.TelemetryClient.Channel(), c}
+}
+
+type bufferedChannel struct {
+	appinsights.TelemetryChannel
+	client *bufferedClient
+}
+
+func (ch *bufferedChannel) Flush() {
+	ch.client.wait()
+	ch.TelemetryChannel.Flush()
+}
+
+func (ch *bufferedChannel) Close(timeout ...time.Duration) <-chan struct{} {
+	ch.client.wait()
+	return ch.TelemetryChannel.Close(timeout...)
+}
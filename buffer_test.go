@@ -0,0 +1,108 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferedClient(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		size  int
+		items int
+	}{
+		{1, 1},
+		{1, 10},
+		{16, 10},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		recording := newRecordingClient()
+		client := newBufferedClient(recording, tt.size, false)
+		for i := 0; i < tt.items; i++ {
+			client.Track(appinsights.NewTraceTelemetry(fmt.Sprint(i), appinsights.Information))
+		}
+		client.Channel().Flush()
+
+		tracked := recording.tracked()
+		if assert.Len(tracked, tt.items, target) {
+			for i, item := range tracked {
+				assert.Equal(fmt.Sprint(i), item.(*appinsights.TraceTelemetry).Message, target)
+			}
+		}
+	}
+}
+
+// gatedClient records items once the gate is opened, signaling the items
+// waiting for it.
+type gatedClient struct {
+	*recordingClient
+	waiting chan struct{}
+	gate    chan struct{}
+}
+
+func (c *gatedClient) Track(item appinsights.Telemetry) {
+	select {
+	case c.waiting <- struct{}{}:
+	default:
+	}
+	<-c.gate
+	c.recordingClient.Track(item)
+}
+
+func TestBufferedClientPriority(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		prioritize bool
+		expected   []string
+	}{
+		{false, []string{"info 0", "info 1", "info 2", "error", "info 3"}},
+		{true, []string{"info 0", "error", "info 1", "info 2", "info 3"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		gated := &gatedClient{newRecordingClient(), make(chan struct{}, 1), make(chan struct{})}
+		client := newBufferedClient(gated, 8, tt.prioritize)
+		client.Track(appinsights.NewTraceTelemetry("info 0", appinsights.Information))
+		<-gated.waiting // handed over, the others queue up behind it
+		for i := 1; i < 3; i++ {
+			client.Track(appinsights.NewTraceTelemetry(fmt.Sprint("info ", i), appinsights.Information))
+		}
+		client.Track(appinsights.NewTraceTelemetry("error", appinsights.Error))
+		client.Track(appinsights.NewTraceTelemetry("info 3", appinsights.Information))
+		close(gated.gate)
+		client.Channel().Flush()
+
+		var messages []string
+		for _, item := range gated.tracked() {
+			messages = append(messages, item.(*appinsights.TraceTelemetry).Message)
+		}
+		assert.Equal(tt.expected, messages, target)
+	}
+}
+
+func TestNewWithChannelBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty", ChannelBufferSize: 8})
+	assert.NoError(err)
+	client := hook.client.(*rotatingClient).active()
+	assert.IsType(&bufferedClient{}, client)
+	assert.Equal(8, cap(client.(*bufferedClient).items))
+	assert.Nil(client.(*bufferedClient).urgent)
+
+	hook, err = New("test", Config{InstrumentationKey: "NotEmpty", ChannelBufferSize: 8, PrioritizeErrors: true})
+	assert.NoError(err)
+	client = hook.client.(*rotatingClient).active()
+	assert.Equal(8, cap(client.(*bufferedClient).urgent))
+	assert.True(hook.pending.priority)
+}
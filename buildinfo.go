@@ -0,0 +1,48 @@
+package logrus_appinsights
+
+import "runtime/debug"
+
+// Properties holding the build that emitted an item.
+const (
+	BuildVersionKey = "build.version"
+	BuildCommitKey  = "build.commit"
+	BuildDateKey    = "build.date"
+)
+
+// readBuildInfo returns the build information embedded in the binary.
+var readBuildInfo = debug.ReadBuildInfo
+
+// SetBuildInfo sends the version, commit and date of the build with every
+// item, the version also setting the application version tag, so errors can
+// be traced to the build that introduced them. Empty values are taken from
+// the build information Go embeds in the binary: the module version and the
+// revision and time of the version control checkout.
+func (hook *AppInsightsHook) SetBuildInfo(version, commit, buildDate string) {
+	if info, ok := readBuildInfo(); ok {
+		if version == "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && buildDate == "":
+				buildDate = setting.Value
+			}
+		}
+	}
+	for key, v := range map[string]string{
+		BuildVersionKey: version,
+		BuildCommitKey:  commit,
+		BuildDateKey:    buildDate,
+	} {
+		if v != "" {
+			hook.AddGlobalProperty(key, v)
+		}
+	}
+	if version != "" {
+		for _, ctx := range hook.contexts() {
+			ctx.Tags.Application().SetVer(version)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetBuildInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	previous := readBuildInfo
+	defer func() { readBuildInfo = previous }()
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			Main: debug.Module{Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.time", Value: "2020-01-02T03:04:05Z"},
+			},
+		}, true
+	}
+
+	tests := []struct {
+		version   string
+		commit    string
+		buildDate string
+		expected  map[string]string
+	}{
+		{"", "", "", map[string]string{
+			BuildVersionKey: "v1.2.3", BuildCommitKey: "abc123", BuildDateKey: "2020-01-02T03:04:05Z",
+		}},
+		{"2.0.0", "def456", "", map[string]string{
+			BuildVersionKey: "2.0.0", BuildCommitKey: "def456", BuildDateKey: "2020-01-02T03:04:05Z",
+		}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook, err := New("test", Config{InstrumentationKey: "key"})
+		assert.NoError(err, target)
+		hook.SetBuildInfo(tt.version, tt.commit, tt.buildDate)
+		ctx := hook.client.Context()
+		for k, v := range tt.expected {
+			assert.Equal(v, ctx.CommonProperties[k], target)
+		}
+		assert.Equal(tt.expected[BuildVersionKey], ctx.Tags.Application().GetVer(), target)
+	}
+
+	// development builds have no version
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}}, true
+	}
+	hook, err := New("test", Config{InstrumentationKey: "key"})
+	assert.NoError(err)
+	hook.SetBuildInfo("", "", "")
+	assert.NotContains(hook.client.Context().CommonProperties, BuildVersionKey)
+	assert.Empty(hook.client.Context().Tags.Application().GetVer())
+}
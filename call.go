@@ -0,0 +1,94 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Headers carrying the correlation of calls between components, in the W3C
+// and the legacy Application Insights formats.
+const (
+	TraceparentHeader = "traceparent"
+	RequestIDHeader   = "Request-Id"
+)
+
+// Fields setting the IDs of requests and dependencies, so calls between
+// components are stitched together in the Application Map.
+const (
+	RequestIDKey       = "request.id"
+	RequestParentIDKey = "request.parent_id"
+	DependencyIDKey    = "dependency.id"
+)
+
+// Call identifies a call between components: the operation it is part of,
+// the ID of its caller and its own ID.
+type Call struct {
+	OperationID string
+	ParentID    string
+	ID          string
+}
+
+// StartCall returns an outgoing call made from ctx, part of the operation of
+// ctx, or of a new operation if none.
+func StartCall(ctx context.Context) Call {
+	operationID, parentID := OperationFromContext(ctx)
+	if operationID == "" {
+		operationID = newID(16)
+	}
+	return Call{OperationID: operationID, ParentID: parentID, ID: newID(8)}
+}
+
+// ReceiveCall returns an incoming call, part of the operation of the caller
+// set by the correlation headers of h, or of a new operation if none.
+func ReceiveCall(h http.Header) Call {
+	call := Call{ID: newID(8)}
+	if m := traceparentFormat.FindStringSubmatch(strings.ToLower(h.Get(TraceparentHeader))); m != nil {
+		call.OperationID, call.ParentID = m[1], m[2]
+	} else if id := h.Get(RequestIDHeader); strings.HasPrefix(id, "|") {
+		// hierarchical ID of the form |operation.parent.
+		call.OperationID = strings.SplitN(id[1:], ".", 2)[0]
+		call.ParentID = id
+	}
+	if call.OperationID == "" {
+		call.OperationID = newID(16)
+	}
+	return call
+}
+
+// Inject sets the correlation headers of the call on h, to be sent with it.
+func (c Call) Inject(h http.Header) {
+	if traceIDFormat.MatchString(c.OperationID) && spanIDFormat.MatchString(c.ID) {
+		h.Set(TraceparentHeader, "00-"+c.OperationID+"-"+c.ID+"-01")
+	}
+	h.Set(RequestIDHeader, "|"+c.OperationID+"."+c.ID+".")
+}
+
+// Context returns a copy of ctx in which entries are part of the call, as
+// for the handling of an incoming call.
+func (c Call) Context(ctx context.Context) context.Context {
+	return WithOperation(ctx, c.OperationID, c.ID)
+}
+
+// RequestFields returns the fields identifying the request telemetry of an
+// incoming call. Log them with the context of the call.
+func (c Call) RequestFields() logrus.Fields {
+	return logrus.Fields{RequestIDKey: c.ID, RequestParentIDKey: c.ParentID}
+}
+
+// DependencyFields returns the fields identifying the dependency telemetry of
+// an outgoing call. Log them with the context the call was started from.
+func (c Call) DependencyFields() logrus.Fields {
+	return logrus.Fields{DependencyIDKey: c.ID}
+}
+
+// newID returns a random hex ID of n bytes.
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
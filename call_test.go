@@ -0,0 +1,90 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiveCall(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		headers   map[string]string
+		operation string
+		parent    string
+	}{
+		{map[string]string{TraceparentHeader: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}, "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331"},
+		{map[string]string{RequestIDHeader: "|4bf92f35.a3ce929d."}, "4bf92f35", "|4bf92f35.a3ce929d."},
+		{map[string]string{TraceparentHeader: "invalid"}, "", ""},
+		{nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		h := make(http.Header)
+		for k, v := range tt.headers {
+			h.Set(k, v)
+		}
+		call := ReceiveCall(h)
+		if tt.operation == "" {
+			assert.Len(call.OperationID, 32, target)
+		} else {
+			assert.Equal(tt.operation, call.OperationID, target)
+		}
+		assert.Equal(tt.parent, call.ParentID, target)
+		assert.Len(call.ID, 16, target)
+	}
+}
+
+func TestCallAcrossComponents(t *testing.T) {
+	assert := assert.New(t)
+
+	// the caller logs the outgoing call as a dependency
+	caller := AppInsightsHook{}
+	ctx := WithOperation(context.Background(), "0af7651916cd43dd8448eb211c80319c", "caller-request")
+	outgoing := StartCall(ctx)
+	h := make(http.Header)
+	outgoing.Inject(h)
+	assert.Equal("00-0af7651916cd43dd8448eb211c80319c-"+outgoing.ID+"-01", h.Get(TraceparentHeader))
+	assert.Equal("|0af7651916cd43dd8448eb211c80319c."+outgoing.ID+".", h.Get(RequestIDHeader))
+
+	entry := logrus.NewEntry(logrus.New()).WithContext(ctx).
+		WithFields(outgoing.DependencyFields()).
+		WithField(DependencyTypeKey, "HTTP")
+	entry.Message = "GET /orders"
+	items, err := caller.buildItems(entry)
+	assert.NoError(err)
+	dependency := items[0].(*appinsights.RemoteDependencyTelemetry)
+	assert.Equal(outgoing.ID, dependency.Id)
+	assert.NotContains(dependency.Properties, DependencyIDKey)
+
+	// the callee logs the incoming call as a request, and its traces as
+	// part of it
+	callee := AppInsightsHook{}
+	callee.SetRequestsEnabled(true)
+	incoming := ReceiveCall(h)
+	entry = logrus.NewEntry(logrus.New()).WithContext(incoming.Context(context.Background())).
+		WithFields(incoming.RequestFields()).
+		WithFields(logrus.Fields{HTTPMethodKey: "GET", HTTPURLKey: "/orders"})
+	items, err = callee.buildItems(entry)
+	assert.NoError(err)
+	if assert.Len(items, 2) {
+		request := items[1].(*appinsights.RequestTelemetry)
+		assert.Equal(incoming.ID, request.Id)
+		assert.Equal("0af7651916cd43dd8448eb211c80319c", request.Tags.Operation().GetId())
+		assert.Equal(outgoing.ID, request.Tags.Operation().GetParentId())
+		assert.NotContains(request.Properties, RequestIDKey)
+
+		trace := contracts.ContextTags(items[0].ContextTags()).Operation()
+		assert.Equal("0af7651916cd43dd8448eb211c80319c", trace.GetId())
+		assert.Equal(incoming.ID, trace.GetParentId())
+	}
+}
@@ -0,0 +1,49 @@
+package logrus_appinsights
+
+import (
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CallerKeys are the properties holding the file, line and function that
+// logged an entry, when the logger reports the caller. Empty keys are not
+// sent.
+type CallerKeys struct {
+	File     string
+	Line     string
+	Function string
+}
+
+// DefaultCallerKeys are the caller properties sent unless set otherwise.
+var DefaultCallerKeys = CallerKeys{
+	File:     "source_file",
+	Line:     "source_line",
+	Function: "source_func",
+}
+
+// SetCallerKeys sets the properties holding the caller of entries logged with
+// logrus.SetReportCaller(true), DefaultCallerKeys by default.
+func (hook *AppInsightsHook) SetCallerKeys(keys CallerKeys) {
+	hook.callerKeys = &keys
+}
+
+// addCallerProperties adds the caller of entry to props, if reported.
+func (hook *AppInsightsHook) addCallerProperties(props map[string]string, entry *logrus.Entry) {
+	if entry.Caller == nil {
+		return
+	}
+	keys := DefaultCallerKeys
+	if hook.callerKeys != nil {
+		keys = *hook.callerKeys
+	}
+	for k, v := range map[string]string{
+		keys.File:     entry.Caller.File,
+		keys.Line:     strconv.Itoa(entry.Caller.Line),
+		keys.Function: entry.Caller.Function,
+	} {
+		if k != "" {
+			hook.addHookProperty(props, k, v)
+		}
+	}
+}
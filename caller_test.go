@@ -0,0 +1,75 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallerProperties(t *testing.T) {
+	assert := assert.New(t)
+
+	caller := &runtime.Frame{File: "/src/app/main.go", Line: 42, Function: "main.serve"}
+
+	tests := []struct {
+		caller   *runtime.Frame
+		keys     *CallerKeys
+		expected map[string]string
+	}{
+		{nil, nil, map[string]string{}},
+		{caller, nil, map[string]string{"source_file": "/src/app/main.go", "source_line": "42", "source_func": "main.serve"}},
+		{caller, &CallerKeys{File: "code.file", Line: "code.line"}, map[string]string{"code.file": "/src/app/main.go", "code.line": "42"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		if tt.keys != nil {
+			hook.SetCallerKeys(*tt.keys)
+		}
+		entry := logrus.NewEntry(logrus.New())
+		entry.Caller = tt.caller
+
+		props := hook.NewPipeline().buildProperties(entry)
+		for k, v := range tt.expected {
+			assert.Equal(v, props[k], target)
+		}
+		delete(props, "message")
+		delete(props, "source_level")
+		delete(props, "source_timestamp")
+		assert.Len(props, len(tt.expected), target)
+	}
+}
+
+func TestCallerPropertiesReported(t *testing.T) {
+	assert := assert.New(t)
+
+	var props map[string]string
+	hook := AppInsightsHook{levels: []logrus.Level{logrus.InfoLevel}}
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.SetReportCaller(true)
+	logger.AddHook(hookFunc(func(entry *logrus.Entry) {
+		props = hook.buildProperties(entry)
+	}))
+	logger.Info("served")
+
+	assert.True(strings.HasSuffix(props["source_file"], "caller_test.go"))
+	assert.Equal("github.com/jjcollinge/logrus-appinsights.TestCallerPropertiesReported", props["source_func"])
+}
+
+// hookFunc adapts a function to a logrus hook fired for every level.
+type hookFunc func(entry *logrus.Entry)
+
+func (fn hookFunc) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (fn hookFunc) Fire(entry *logrus.Entry) error {
+	fn(entry)
+	return nil
+}
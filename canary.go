@@ -0,0 +1,63 @@
+package logrus_appinsights
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+)
+
+var (
+	canaryMu   sync.Mutex
+	canaryRand = rand.New(rand.NewSource(rand.Int63()))
+)
+
+// newCanaryClient returns the client of the canary resource configured by
+// conf, if any.
+func newCanaryClient(name string, conf Config) (appinsights.TelemetryClient, error) {
+	if conf.CanaryConnectionString == "" || conf.CanaryFraction <= 0 {
+		return nil, nil
+	}
+	iKey, endpointUrl, err := parseConnectionString(conf.CanaryConnectionString)
+	if err != nil {
+		return nil, err
+	}
+	canaryConf := appinsights.NewTelemetryConfiguration(iKey)
+	canaryConf.EndpointUrl = endpointUrl
+	if conf.MaxBatchSize != 0 {
+		canaryConf.MaxBatchSize = conf.MaxBatchSize
+	}
+	if conf.MaxBatchInterval != 0 {
+		canaryConf.MaxBatchInterval = conf.MaxBatchInterval
+	}
+	client := appinsights.NewTelemetryClientFromConfig(canaryConf)
+	if name != "" {
+		client.Context().Tags.Cloud().SetRole(name)
+	}
+	return client, nil
+}
+
+// sampleCanary reports whether telemetry should also be sent to the canary.
+func (hook *AppInsightsHook) sampleCanary() bool {
+	if hook.canary == nil {
+		return false
+	}
+	canaryMu.Lock()
+	defer canaryMu.Unlock()
+	return canaryRand.Float64() < hook.canaryFraction
+}
+
+// track sends items to the client, and to the canary for the sampled
+// fraction of them. Items of an entry are sampled together.
+func (hook *AppInsightsHook) track(items ...appinsights.Telemetry) {
+	canary := hook.sampleCanary()
+	for _, item := range items {
+		hook.client.Track(item)
+		if canary {
+			hook.canary.Track(item)
+		}
+	}
+	hook.batching.observe(len(items))
+	hook.mirrorItems(items)
+	hook.submitted(items, nil)
+}
@@ -0,0 +1,94 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingClient is a client keeping the items it tracks.
+type recordingClient struct {
+	appinsights.TelemetryClient
+
+	mu    sync.Mutex
+	items []appinsights.Telemetry
+}
+
+func newRecordingClient() *recordingClient {
+	return &recordingClient{TelemetryClient: appinsights.NewTelemetryClient("recording")}
+}
+
+func (c *recordingClient) Track(item appinsights.Telemetry) {
+	c.mu.Lock()
+	c.items = append(c.items, item)
+	c.mu.Unlock()
+}
+
+func (c *recordingClient) tracked() []appinsights.Telemetry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]appinsights.Telemetry{}, c.items...)
+}
+
+func TestCanary(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fraction float64
+		min, max int
+	}{
+		{0, 0, 0},
+		{1, 1000, 1000},
+		{0.1, 50, 150},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		primary, canary := newRecordingClient(), newRecordingClient()
+		hook := AppInsightsHook{client: primary, canary: canary, canaryFraction: tt.fraction}
+		hook.AddMetricMapping("queue_depth", "QueueDepth")
+
+		entry := logrus.NewEntry(logrus.New()).WithField("queue_depth", 1)
+		for i := 0; i < 1000; i++ {
+			assert.NoError(hook.Fire(entry), target)
+		}
+		assert.Len(primary.tracked(), 2000, target)
+		n := len(canary.tracked())
+		assert.True(n >= 2*tt.min && n <= 2*tt.max, target)
+		// the items of an entry are sampled together
+		assert.Equal(0, n%2, target)
+	}
+}
+
+func TestNewWithCanaryConnectionString(t *testing.T) {
+	assert := assert.New(t)
+
+	hook, err := New("test", Config{
+		InstrumentationKey:     "primary",
+		CanaryConnectionString: "IngestionEndpoint=https://localhost",
+		CanaryFraction:         0.01,
+	})
+	assert.Error(err)
+	assert.Nil(hook)
+
+	hook, err = New("test", Config{
+		InstrumentationKey:     "primary",
+		CanaryConnectionString: "InstrumentationKey=canary;IngestionEndpoint=https://localhost",
+	})
+	assert.NoError(err)
+	assert.Nil(hook.canary)
+
+	hook, err = New("test", Config{
+		InstrumentationKey:     "primary",
+		CanaryConnectionString: "InstrumentationKey=canary;IngestionEndpoint=https://localhost",
+		CanaryFraction:         0.01,
+	})
+	assert.NoError(err)
+	assert.Equal("canary", hook.canary.InstrumentationKey())
+	assert.Equal("primary", hook.client.InstrumentationKey())
+}
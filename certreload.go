@@ -0,0 +1,133 @@
+package logrus_appinsights
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultCertReloadInterval = time.Minute
+
+// certReloader serves a client certificate loaded from disk, reloading it
+// whenever the certificate or key file changes.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, done: make(chan struct{})}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the key pair if either file changed since the last load and
+// reports whether the certificate was replaced.
+func (r *certReloader) reload() (bool, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return true, nil
+}
+
+// run reloads the certificate at every interval until stopped, closing the
+// idle connections of transport so new ones present the new certificate.
+func (r *certReloader) run(interval time.Duration, transport *http.Transport) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+		// keep serving the previous certificate while the files are
+		// being replaced
+		if changed, err := r.reload(); err == nil && changed {
+			transport.CloseIdleConnections()
+		}
+	}
+}
+
+// stop stops reloading the certificate.
+func (r *certReloader) stop() {
+	r.stopOnce.Do(func() { close(r.done) })
+}
+
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reloadingTransport is an HTTP transport presenting a client certificate
+// reloaded from disk.
+type reloadingTransport struct {
+	*http.Transport
+	reloader *certReloader
+}
+
+// close stops reloading the certificate.
+func (t *reloadingTransport) close() {
+	t.reloader.stop()
+}
+
+// newReloadingTransport returns an HTTP transport presenting the client
+// certificate found in certFile and keyFile, reloaded at every interval. It
+// is otherwise configured as the default transport.
+func newReloadingTransport(certFile, keyFile string, interval time.Duration) (*reloadingTransport, error) {
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = defaultCertReloadInterval
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		GetClientCertificate: reloader.getClientCertificate,
+	}
+	go reloader.run(interval, transport)
+	return &reloadingTransport{transport, reloader}, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,137 @@
+package logrus_appinsights
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeKeyPair writes a self-signed certificate for commonName to dir.
+func writeKeyPair(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, r *certReloader) string {
+	cert, err := r.getClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "certreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = newCertReloader(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"))
+	assert.Error(err)
+
+	certFile, keyFile := writeKeyPair(t, dir, "first")
+	reloader, err := newCertReloader(certFile, keyFile)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("first", commonName(t, reloader))
+
+	changed, err := reloader.reload()
+	assert.NoError(err)
+	assert.False(changed)
+
+	writeKeyPair(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+
+	changed, err = reloader.reload()
+	assert.NoError(err)
+	assert.True(changed)
+	assert.Equal("second", commonName(t, reloader))
+}
+
+func TestNewWithClientCertificate(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "certreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hook, err := New("test", Config{
+		InstrumentationKey: "NotEmpty",
+		ClientCertFile:     filepath.Join(dir, "missing.crt"),
+		ClientKeyFile:      filepath.Join(dir, "missing.key"),
+	})
+	assert.Error(err)
+	assert.Nil(hook)
+
+	certFile, keyFile := writeKeyPair(t, dir, "client")
+	hook, err = New("test", Config{
+		InstrumentationKey: "NotEmpty",
+		ClientCertFile:     certFile,
+		ClientKeyFile:      keyFile,
+	})
+	assert.NoError(err)
+	if !assert.NotNil(hook.httpClient) {
+		return
+	}
+	reloading := hook.httpClient.Transport.(*deliveryTransport).base.(*reloadingTransport)
+	assert.NotNil(reloading.TLSClientConfig.GetClientCertificate)
+	assert.True(reloading.ForceAttemptHTTP2)
+	assert.NotZero(reloading.TLSHandshakeTimeout)
+
+	hook.Close()
+	select {
+	case <-reloading.reloader.done:
+	default:
+		t.Error("certificate still reloaded after Close")
+	}
+}
@@ -0,0 +1,117 @@
+package logrus_appinsights
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cliBatchInterval is the batch interval of hooks for command line tools,
+// short enough for batches to be sent before most commands complete.
+const cliBatchInterval = 100 * time.Millisecond
+
+// NewCLI returns a hook for short-lived command line tools. Batches are sent
+// every 100ms unless conf sets another interval, Error, Fatal and Panic
+// entries are delivered before logging returns, and the hook is closed when
+// logrus exits on a Fatal entry. Delivering and closing never take longer
+// than budget in total from now, so telemetry cannot hang the command;
+// Close the hook before the command returns.
+func NewCLI(name string, conf Config, budget time.Duration) (*AppInsightsHook, error) {
+	if conf.MaxBatchInterval == 0 {
+		conf.MaxBatchInterval = cliBatchInterval
+	}
+	hook, err := New(name, conf)
+	if err != nil {
+		return nil, err
+	}
+	hook.deadline = time.Now().Add(budget)
+	logrus.DeferExitHandler(func() { hook.Close() })
+	return hook, nil
+}
+
+// remaining returns how much of the time budget is left, or false if the hook
+// has none.
+func (hook *AppInsightsHook) remaining() (time.Duration, bool) {
+	if hook.deadline.IsZero() {
+		return 0, false
+	}
+	return time.Until(hook.deadline), true
+}
+
+// sendWithinBudget delivers Error, Fatal and Panic entries before returning
+// while the time budget lasts, returning false if entry must be sent as
+// usual.
+func (hook *AppInsightsHook) sendWithinBudget(entry *logrus.Entry) (bool, error) {
+	remaining, ok := hook.remaining()
+	if !ok || remaining <= 0 || entry.Level > logrus.ErrorLevel {
+		return false, nil
+	}
+	items, err := hook.buildItems(entry)
+	if err != nil {
+		return true, err
+	}
+	client := *hook.httpClient
+	client.Timeout = remaining
+	return true, hook.transmit(&client, items)
+}
+
+// Close sends the pending telemetry and stops the hook and its pipelines,
+// waiting for delivery at most as long as the time budget of NewCLI allows,
+// or else until the pending telemetry is sent. The hook must not be used
+// afterwards; closing it again does nothing.
+func (hook *AppInsightsHook) Close() {
+	hook.closeOnce.Do(hook.close)
+}
+
+// stopReports stops sending aggregated metrics, suppression summaries,
+// redaction reports and self-reports.
+func (hook *AppInsightsHook) stopReports() {
+	if hook.aggregates != nil {
+		hook.SetAggregationInterval(0)
+	}
+	if b := hook.operations; b != nil {
+		b.mu.Lock()
+		if b.stop != nil {
+			close(b.stop)
+			b.stop = nil
+		}
+		b.mu.Unlock()
+	}
+	hook.SetRedactionReportInterval(0)
+	hook.SetSelfReportInterval(0)
+}
+
+func (hook *AppInsightsHook) close() {
+	hook.batching.close()
+	if delivery := hook.delivery(); delivery != nil {
+		delivery.close()
+	}
+	for _, server := range hook.statsServers {
+		server.Close()
+	}
+	hook.FlushMetrics()
+	hook.flushSummaries()
+	hook.stopReports()
+	defer hook.mirror.close()
+	defer stopClient(hook.client)
+	var done []<-chan struct{}
+	if rotating := hook.rotatingClient(); rotating != nil {
+		done = append(done, rotating.stop()...)
+	}
+	done = append(done, hook.client.Channel().Close())
+	if hook.canary != nil {
+		done = append(done, hook.canary.Channel().Close())
+	}
+
+	var timeout <-chan time.Time
+	if remaining, ok := hook.remaining(); ok {
+		timeout = time.After(remaining)
+	}
+	for _, ch := range done {
+		select {
+		case <-ch:
+		case <-timeout:
+			return
+		}
+	}
+}
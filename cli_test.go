@@ -0,0 +1,113 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCLI(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	var messages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return
+		}
+		buffer := new(bytes.Buffer)
+		buffer.ReadFrom(reader)
+		received, _ := parsePayload(buffer.Bytes())
+		mu.Lock()
+		for _, item := range received {
+			message, _ := item.getPath("data.baseData.message")
+			messages = append(messages, message.(string))
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, messages...)
+	}
+
+	hook, err := NewCLI("cli", Config{InstrumentationKey: "NotEmpty", EndpointUrl: server.URL}, 5*time.Second)
+	assert.NoError(err)
+	logger := logrus.New()
+	logger.Hooks.Add(hook)
+
+	logger.Info("started")
+	logger.Error("failed")
+	assert.Equal([]string{"failed"}, received())
+
+	hook.Close()
+	hook.Close()
+	assert.Equal([]string{"failed", "started"}, received())
+}
+
+func TestNewCLIBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	hook, err := NewCLI("cli", Config{InstrumentationKey: "NotEmpty", EndpointUrl: server.URL}, 200*time.Millisecond)
+	assert.NoError(err)
+	logger := logrus.New()
+	logger.Out = new(bytes.Buffer)
+	logger.Hooks.Add(hook)
+
+	start := time.Now()
+	logger.Info("started")
+	logger.Error("failed")
+	logger.Error("failed again")
+	hook.Close()
+	assert.True(time.Since(start) < time.Second, time.Since(start).String())
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	assert := assert.New(t)
+
+	hook, err := New("test", Config{
+		InstrumentationKey:        "NotEmpty",
+		SecondaryConnectionString: "InstrumentationKey=secondary",
+		ChannelBufferSize:         10,
+	})
+	assert.NoError(err)
+	hook.SetAggregationInterval(time.Minute)
+	hook.SetOperationBudget(10, time.Minute)
+	hook.SetRedactionReportInterval(time.Minute)
+	hook.SetSelfReportInterval(time.Minute)
+	operations := hook.operations
+	hook.Close()
+
+	assert.Nil(hook.aggregates.stop)
+	assert.Nil(operations.stop)
+	assert.Nil(hook.auditReporter)
+	assert.Nil(hook.reporter)
+	for _, client := range hook.clients() {
+		switch c := client.(type) {
+		case *bufferedClient:
+			assert.True(c.stopped)
+		case *failoverClient:
+			select {
+			case <-c.done:
+			default:
+				t.Error("failover still probing")
+			}
+		}
+	}
+}
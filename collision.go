@@ -0,0 +1,79 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// CollisionSuffix is appended to the name of a field colliding with a
+// property added by the hook under the SuffixCollisions policy.
+const CollisionSuffix = "_field"
+
+// CollisionPolicy decides which value is sent when a field has the name of a
+// property the hook adds itself, such as message, source_level or
+// time_bucket.
+type CollisionPolicy int
+
+const (
+	// HookWins sends the value added by the hook, dropping the field.
+	HookWins CollisionPolicy = iota
+	// UserWins sends the value of the field, as for the properties of
+	// contexts and providers.
+	UserWins
+	// SuffixCollisions sends the value added by the hook and the value of
+	// the field under its name followed by CollisionSuffix.
+	SuffixCollisions
+)
+
+// collisions resolves the collisions of fields with the properties added by
+// the hook, warning once about each colliding field.
+type collisions struct {
+	policy CollisionPolicy
+	warned sync.Map
+}
+
+// SetCollisionPolicy sets how fields named after a property added by the hook
+// are sent, HookWins by default.
+func (hook *AppInsightsHook) SetCollisionPolicy(policy CollisionPolicy) {
+	hook.collisions = &collisions{policy: policy}
+}
+
+// SetCollisionWarnings sets where a warning is written the first time each
+// field collides with a property added by the hook, whatever the policy. A
+// nil writer, the default, disables the warnings.
+func (hook *AppInsightsHook) SetCollisionWarnings(w io.Writer) {
+	hook.collisionWarnings = w
+	if hook.collisions == nil {
+		hook.collisions = &collisions{}
+	}
+}
+
+// addHookProperty adds the property k the hook adds itself to props,
+// resolving collisions with the fields of entry already added.
+func (hook *AppInsightsHook) addHookProperty(props map[string]string, k, v string) {
+	field, ok := props[k]
+	if !ok {
+		props[k] = v
+		return
+	}
+	c := hook.collisions
+	if c == nil {
+		c = defaultCollisions
+	}
+	if hook.collisionWarnings != nil {
+		if _, warned := c.warned.LoadOrStore(k, struct{}{}); !warned {
+			fmt.Fprintf(hook.collisionWarnings, "logrus_appinsights: field %q collides with a property added by the hook\n", k)
+		}
+	}
+	switch c.policy {
+	case HookWins:
+		props[k] = v
+	case SuffixCollisions:
+		props[k] = v
+		props[k+CollisionSuffix] = field
+	}
+}
+
+// defaultCollisions resolves collisions for hooks without a policy.
+var defaultCollisions = &collisions{}
@@ -0,0 +1,104 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCollisionPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		policy   CollisionPolicy
+		expected map[string]string
+	}{
+		{UserWins, map[string]string{
+			"message":      "field message",
+			"source_level": "custom",
+			"time_bucket":  "today",
+		}},
+		{HookWins, map[string]string{
+			"message":      "entry message",
+			"source_level": "info",
+			"time_bucket":  "2020-01-02T03:00:00Z",
+		}},
+		{SuffixCollisions, map[string]string{
+			"message":            "entry message",
+			"message_field":      "field message",
+			"source_level":       "info",
+			"source_level_field": "custom",
+			"time_bucket":        "2020-01-02T03:00:00Z",
+			"time_bucket_field":  "today",
+		}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		output := new(bytes.Buffer)
+		hook := AppInsightsHook{}
+		hook.SetTimeBucket(time.Hour)
+		hook.SetCollisionPolicy(tt.policy)
+		hook.SetCollisionWarnings(output)
+		entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+			"message":      "field message",
+			"source_level": "custom",
+			"time_bucket":  "today",
+		})
+		entry.Message = "entry message"
+		entry.Level = logrus.InfoLevel
+		entry.Time = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		hook.buildProperties(entry)
+		props := hook.NewPipeline().buildProperties(entry)
+		for k, v := range tt.expected {
+			assert.Equal(v, props[k], target)
+		}
+		assert.NotContains(props, "source_timestamp_field", target)
+
+		// each field is warned about once
+		assert.Equal(3, strings.Count(output.String(), "collides"), target)
+		assert.Equal(1, strings.Count(output.String(), `"source_level"`), target)
+	}
+}
+
+func TestBuildPropertiesWithoutCollisions(t *testing.T) {
+	assert := assert.New(t)
+
+	output := new(bytes.Buffer)
+	hook := AppInsightsHook{}
+	hook.SetCollisionPolicy(SuffixCollisions)
+	hook.SetCollisionWarnings(output)
+	entry := logrus.NewEntry(logrus.New()).WithField("user", "jane")
+	entry.Message = "signed in"
+
+	props := hook.buildProperties(entry)
+	assert.Equal("signed in", props["message"])
+	assert.NotContains(props, "message_field")
+	assert.Empty(output.String())
+}
+
+func TestDefaultCollisionPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"message":      "field message",
+		"source_level": "custom",
+	})
+	entry.Message = "entry message"
+	entry.Level = logrus.WarnLevel
+
+	// the hook wins, without warnings
+	props := hook.buildProperties(entry)
+	assert.Equal("entry message", props["message"])
+	assert.Equal("warning", props["source_level"])
+	assert.NotContains(props, "message_field")
+	assert.Nil(hook.collisionWarnings)
+}
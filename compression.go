@@ -0,0 +1,46 @@
+package logrus_appinsights
+
+import (
+	"compress/gzip"
+	"fmt"
+)
+
+// payloadEncoding describes how the payloads the hook sends itself are
+// encoded.
+type payloadEncoding struct {
+	level        int // gzip level
+	preSerialize bool
+}
+
+var defaultEncoding = payloadEncoding{level: gzip.DefaultCompression}
+
+// SetCompressionLevel sets the gzip level of the payloads the hook sends
+// itself, with FireAndWait, within the time budget of NewCLI and when
+// panicking, from gzip.HuffmanOnly to gzip.BestCompression. Higher levels
+// spend more CPU for less egress. The client compresses the telemetry it
+// batches at the default level whatever the setting.
+func (hook *AppInsightsHook) SetCompressionLevel(level int) error {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return fmt.Errorf("invalid compression level %d", level)
+	}
+	hook.gzipLevel = &level
+	return nil
+}
+
+// SetPreSerialization sets whether the payloads the hook sends itself are
+// serialized in full before being compressed in a single pass, as the client
+// does, rather than compressed envelope by envelope as they are encoded. The
+// uncompressed payload is held in memory meanwhile.
+func (hook *AppInsightsHook) SetPreSerialization(enabled bool) {
+	hook.preSerialize = enabled
+}
+
+// encoding returns how the hook encodes the payloads it sends itself.
+func (hook *AppInsightsHook) encoding() payloadEncoding {
+	encoding := defaultEncoding
+	if hook.gzipLevel != nil {
+		encoding.level = *hook.gzipLevel
+	}
+	encoding.preSerialize = hook.preSerialize
+	return encoding
+}
@@ -0,0 +1,74 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCompressionLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		level     int
+		expectErr bool
+	}{
+		{gzip.HuffmanOnly, false},
+		{gzip.NoCompression, false},
+		{gzip.BestSpeed, false},
+		{gzip.BestCompression, false},
+		{gzip.HuffmanOnly - 1, true},
+		{gzip.BestCompression + 1, true},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		err := hook.SetCompressionLevel(tt.level)
+		if tt.expectErr {
+			assert.Error(err, target)
+			assert.Equal(defaultEncoding, hook.encoding(), target)
+		} else {
+			assert.NoError(err, target)
+			assert.Equal(tt.level, hook.encoding().level, target)
+		}
+	}
+}
+
+func TestEncodePayload(t *testing.T) {
+	assert := assert.New(t)
+
+	client := appinsights.NewTelemetryClient("key")
+	envelopes := make([]*contracts.Envelope, 10)
+	for i := range envelopes {
+		envelopes[i] = envelop(client.Context(), appinsights.NewTraceTelemetry(fmt.Sprint("message ", i), appinsights.Information))
+	}
+
+	for _, level := range []int{gzip.HuffmanOnly, gzip.NoCompression, gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression} {
+		for _, preSerialize := range []bool{false, true} {
+			encoding := payloadEncoding{level: level, preSerialize: preSerialize}
+			target := fmt.Sprintf("%+v", encoding)
+
+			// twice, the second time with pooled writers and buffers
+			for i := 0; i < 2; i++ {
+				payload, err := encodePayload(envelopes, encoding)
+				assert.NoError(err, target)
+				reader, err := gzip.NewReader(payload)
+				assert.NoError(err, target)
+				buffer := new(bytes.Buffer)
+				buffer.ReadFrom(reader)
+				messages, err := parsePayload(buffer.Bytes())
+				assert.NoError(err, target)
+				if assert.Len(messages, len(envelopes), target) {
+					assert.NoError(messages[9].assertPath("data.baseData.message", "message 9"), target)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package logrus_appinsights
+
+import "github.com/sirupsen/logrus"
+
+// conditionalFilter filters the field name of the entries matching predicate.
+type conditionalFilter struct {
+	predicate func(*logrus.Entry) bool
+	name      string
+	fn        func(interface{}) interface{}
+}
+
+// AddConditionalFilter adds a filter of the field name applied only to the
+// entries predicate returns true for, e.g. to redact a field in production
+// only. It applies before the other filters of the field, under its name
+// after renaming.
+func (hook *AppInsightsHook) AddConditionalFilter(predicate func(entry *logrus.Entry) bool, name string, fn func(interface{}) interface{}) {
+	hook.redactionCounts()
+	hook.conditionals = append(hook.conditionals, conditionalFilter{predicate: predicate, name: name, fn: fn})
+}
+
+// conditionalEntry returns entry with its conditional filters applied,
+// copying it if any applies.
+func (hook *AppInsightsHook) conditionalEntry(entry *logrus.Entry) *logrus.Entry {
+	var data logrus.Fields
+	for _, filter := range hook.conditionals {
+		if _, ok := entry.Data[filter.name]; !ok || !filter.predicate(entry) {
+			continue
+		}
+		if data == nil {
+			data = make(logrus.Fields, len(entry.Data))
+			for k, v := range entry.Data {
+				data[k] = v
+			}
+		}
+		v := data[filter.name]
+		filtered := filter.fn(v)
+		hook.redactions.changed("conditional_filter."+filter.name, v, filtered)
+		data[filter.name] = filtered
+	}
+	if data == nil {
+		return entry
+	}
+	filtered := *entry
+	filtered.Data = data
+	return &filtered
+}
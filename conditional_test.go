@@ -0,0 +1,39 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddConditionalFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	production := func(entry *logrus.Entry) bool { return entry.Data["environment"] == "prod" }
+	redact := func(interface{}) interface{} { return "[redacted]" }
+	tests := []struct {
+		fields   logrus.Fields
+		expected string
+	}{
+		{logrus.Fields{"environment": "prod", "user_email": "jane@example.com"}, "[redacted]"},
+		{logrus.Fields{"environment": "dev", "user_email": "jane@example.com"}, "jane@example.com"},
+		{logrus.Fields{"user_email": "jane@example.com"}, "jane@example.com"},
+		{logrus.Fields{"environment": "prod", "email": "jane@example.com"}, "[redacted]"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.RenameField("email", "user_email")
+		hook.AddConditionalFilter(production, "user_email", redact)
+		pipeline := hook.NewPipeline()
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+		items, err := pipeline.buildItems(entry)
+		assert.NoError(err, target)
+		assert.Equal(tt.expected, items[0].GetProperties()["user_email"], target)
+		assert.NotEqual("[redacted]", fmt.Sprint(entry.Data["user_email"]), target)
+	}
+}
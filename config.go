@@ -1,6 +1,11 @@
 package logrus_appinsights
 
-import "time"
+import (
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
 
 // Config for Application Insights settings
 type Config struct {
@@ -8,4 +13,131 @@ type Config struct {
 	EndpointUrl        string
 	MaxBatchSize       int
 	MaxBatchInterval   time.Duration
+
+	// Backend is where telemetry is sent, Application Insights by default.
+	Backend Backend
+	// AADTokenProvider enables Azure AD authenticated ingestion, presenting
+	// its access tokens for AADScope to the ingestion endpoint, which must
+	// then be the v2.1 one. The default and /v2/track endpoints are changed
+	// accordingly.
+	AADTokenProvider TokenProvider
+
+	// MinBatchInterval enables adaptive batching: while few entries are
+	// logged, batches of at least MinBatchSize items are sent every
+	// MinBatchInterval for freshness, growing with the rate of items up to
+	// MaxBatchSize and MaxBatchInterval under load for efficiency.
+	MinBatchSize     int
+	MinBatchInterval time.Duration
+
+	// CloudRole and CloudRoleInstance set the cloud role and role instance
+	// tags naming the service and its instance in the Application Map. The
+	// role defaults to the name the hook is created with and the instance to
+	// the host name.
+	CloudRole         string
+	CloudRoleInstance string
+
+	// ResourceDetectors are run once when the hook is created, as with
+	// AddResourceDetectors, e.g. VMDetector to attribute telemetry to the
+	// Azure virtual machine or scale set the application runs on. The
+	// AppServiceDetector always runs after them, naming the cloud role after
+	// the site in Azure App Service unless CloudRole is set.
+	ResourceDetectors []ResourceDetector
+
+	// DefaultProperties are sent with every item, e.g. the environment,
+	// region or version of a deployment.
+	DefaultProperties map[string]string
+	// EnvironmentProperties are environment variables sent with every item,
+	// as with AddEnvironmentProperties.
+	EnvironmentProperties []string
+
+	// SamplingRates is the percentage of the entries of each level to send,
+	// as with SetSamplingRates.
+	SamplingRates map[logrus.Level]float64
+	// MaxItemsPerSecond enables adaptive sampling, sending about as many
+	// entries per second as with SetAdaptiveSampling.
+	MaxItemsPerSecond float64
+	// MaxItemsPerHour and MaxBytesPerHour cap the volume sent per hour, as
+	// with SetVolumeBudget.
+	MaxItemsPerHour int64
+	MaxBytesPerHour int64
+
+	// MaxConcurrentBatches bounds how many batches may be submitted
+	// concurrently, the others being queued until one completes. The client
+	// starts a submission for every batch, each MaxBatchSize items or
+	// MaxBatchInterval, so raise it for high throughput or set it to 1 to
+	// submit batches one at a time. Unbounded by default.
+	MaxConcurrentBatches int
+
+	// ChannelBufferSize is how many items may be buffered before tracking
+	// blocks, absorbing bursts while the client is busy. The client groups
+	// the items it accepts into batches of up to MaxBatchSize items, sent at
+	// least every MaxBatchInterval, so the buffer only fills when items come
+	// in faster than batches are cut. Unbuffered by default.
+	ChannelBufferSize int
+	// PrioritizeErrors hands Error, Fatal and Panic items over to the client
+	// ahead of the others when the channel buffer fills, buffering them
+	// apart, and reserves a quarter of the pending bytes budget set with
+	// SetMaxPendingBytes for them, so they are the last to be dropped.
+	PrioritizeErrors bool
+	// MaxPendingBytes bounds the bytes held by entries fired asynchronously
+	// until delivered, as with SetMaxPendingBytes, entries exceeding it being
+	// dropped or blocking according to OverflowPolicy.
+	MaxPendingBytes int64
+	OverflowPolicy  OverflowPolicy
+
+	// IdempotencyKeys enables setting the IdempotencyKeyHeader of every
+	// batch, kept for retries of the batch within ten minutes.
+	IdempotencyKeys bool
+
+	// StatsAddress enables serving the delivery statistics of the hook on
+	// the address, as with ServeStats. Off by default.
+	StatsAddress string
+
+	// SecondaryConnectionString is the connection string of the resource
+	// telemetry fails over to while the primary endpoint is unhealthy.
+	SecondaryConnectionString string
+	// FailoverAfter is how long the primary endpoint must be unhealthy before
+	// failing over, one minute by default.
+	FailoverAfter time.Duration
+
+	// ClientCertFile and ClientKeyFile hold the PEM encoded client
+	// certificate presented to an mTLS relay. They are reloaded when changed
+	// on disk, checked every CertReloadInterval (one minute by default).
+	ClientCertFile     string
+	ClientKeyFile      string
+	CertReloadInterval time.Duration
+
+	// OfflineDir enables keeping the batches that could not be delivered in
+	// files of the directory, resubmitted once the endpoint is reachable
+	// again. OfflineStore sets another Store instead.
+	OfflineDir   string
+	OfflineStore Store
+
+	// CanaryConnectionString is the connection string of a resource also
+	// receiving CanaryFraction (between 0 and 1) of the telemetry, e.g. to
+	// validate a new resource against real traffic before cutting over.
+	CanaryConnectionString string
+	CanaryFraction         float64
+
+	// DryRun enables writing the envelopes to the writer instead of sending
+	// them, as with SetDryRun.
+	DryRun io.Writer
+
+	// MirrorFile enables also writing the envelopes to a rotating JSON Lines
+	// file at the path, as with SetMirrorFile.
+	MirrorFile string
+
+	// OTLPEndpoint enables exporting logs to an OTLP/HTTP receiver as with
+	// SetOTLPExport, alongside Application Insights unless the Backend is
+	// OTLPBackend.
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+	// Deprecated: OTLPOnly selects the OTLPBackend, whatever the Backend;
+	// set the Backend instead.
+	OTLPOnly bool
+
+	// DeviceTags decides how the device ID and role instance tags, set to
+	// the host name by default, are sent. Privacy sensitive applications
+	// running on end user machines can suppress or hash them.
+	DeviceTags DeviceTagPolicy
 }
@@ -0,0 +1,120 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+)
+
+// CorrelationExtractor returns the operation and parent IDs carried by ctx,
+// or empty strings if there are none.
+type CorrelationExtractor func(ctx context.Context) (operationID, parentID string)
+
+type operationKey struct{}
+
+type operation struct {
+	id, parentID string
+}
+
+// WithOperation returns a copy of ctx carrying the operation and parent IDs
+// the default correlation extractor looks for.
+func WithOperation(ctx context.Context, operationID, parentID string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation{operationID, parentID})
+}
+
+// OperationFromContext is the default correlation extractor, returning the
+// IDs set by WithOperation.
+func OperationFromContext(ctx context.Context) (operationID, parentID string) {
+	op, _ := ctx.Value(operationKey{}).(operation)
+	return op.id, op.parentID
+}
+
+// SetCorrelationExtractor sets how the operation an entry belongs to is
+// extracted from its context, OperationFromContext by default. Items are
+// tagged with the operation so they nest under the owning request in
+// end-to-end transaction views.
+func (hook *AppInsightsHook) SetCorrelationExtractor(extractor CorrelationExtractor) {
+	hook.correlation = extractor
+}
+
+// Fields carrying W3C trace context, either as a traceparent header value or
+// as separate trace and span IDs.
+const (
+	TraceparentKey = "traceparent"
+	TraceIDKey     = "trace_id"
+	SpanIDKey      = "span_id"
+)
+
+var (
+	traceparentFormat = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+	traceIDFormat     = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	spanIDFormat      = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// traceContext returns the trace and span IDs of the W3C trace context
+// fields of entry, if valid.
+func traceContext(entry *logrus.Entry) (traceID, spanID string) {
+	if v, ok := entry.Data[TraceparentKey].(string); ok {
+		if m := traceparentFormat.FindStringSubmatch(strings.ToLower(v)); m != nil {
+			return m[1], m[2]
+		}
+	}
+	if v, ok := entry.Data[TraceIDKey].(string); ok && traceIDFormat.MatchString(strings.ToLower(v)) {
+		traceID = strings.ToLower(v)
+		if v, ok := entry.Data[SpanIDKey].(string); ok && spanIDFormat.MatchString(strings.ToLower(v)) {
+			spanID = strings.ToLower(v)
+		}
+	}
+	return traceID, spanID
+}
+
+// operation returns the operation and parent IDs of the entry's W3C trace
+// context fields, or else of the span active in its context, or else of its
+// context.
+func (hook *AppInsightsHook) operation(entry *logrus.Entry) (operationID, parentID string) {
+	operationID, parentID = traceContext(entry)
+	if operationID == "" {
+		operationID, parentID = hook.activeSpan(entry)
+	}
+	if operationID == "" && entry.Context != nil {
+		extractor := hook.correlation
+		if extractor == nil {
+			extractor = OperationFromContext
+		}
+		operationID, parentID = extractor(entry.Context)
+	}
+	return operationID, parentID
+}
+
+// operationID returns the ID of the operation the items of entry are tagged
+// with: the field mapped to the operation ID tag if any, or else its
+// OperationIDKey field, or else its operation.
+func (hook *AppInsightsHook) operationID(entry *logrus.Entry) string {
+	for field, tag := range hook.tagFields {
+		if v, ok := entry.Data[field]; ok && tag == contracts.OperationId {
+			return fmt.Sprintf("%v", formatData(v))
+		}
+	}
+	if id, ok := entry.Data[OperationIDKey]; ok {
+		return fmt.Sprintf("%v", id)
+	}
+	operationID, _ := hook.operation(entry)
+	return operationID
+}
+
+// correlate tags item with the operation of entry.
+func (hook *AppInsightsHook) correlate(entry *logrus.Entry, item appinsights.Telemetry) {
+	operationID, parentID := hook.operation(entry)
+	tags := contracts.ContextTags(item.ContextTags()).Operation()
+	if operationID != "" {
+		tags.SetId(operationID)
+	}
+	if parentID != "" {
+		tags.SetParentId(parentID)
+	}
+}
@@ -0,0 +1,107 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type traceIDKey struct{}
+
+func TestCorrelate(t *testing.T) {
+	assert := assert.New(t)
+
+	custom := func(ctx context.Context) (string, string) {
+		id, _ := ctx.Value(traceIDKey{}).(string)
+		return id, ""
+	}
+
+	tests := []struct {
+		ctx       context.Context
+		extractor CorrelationExtractor
+		operation string
+		parent    string
+	}{
+		{nil, nil, "", ""},
+		{context.Background(), nil, "", ""},
+		{WithOperation(context.Background(), "op", "parent"), nil, "op", "parent"},
+		{WithOperation(context.Background(), "op", ""), nil, "op", ""},
+		{context.WithValue(context.Background(), traceIDKey{}, "trace"), custom, "trace", ""},
+		{WithOperation(context.Background(), "op", "parent"), custom, "", ""},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetCorrelationExtractor(tt.extractor)
+		entry := logrus.NewEntry(logrus.New())
+		if tt.ctx != nil {
+			entry = entry.WithContext(tt.ctx)
+		}
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		tags := contracts.ContextTags(items[0].ContextTags()).Operation()
+		assert.Equal(tt.operation, tags.GetId(), target)
+		assert.Equal(tt.parent, tags.GetParentId(), target)
+	}
+}
+
+func TestCorrelateRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.SetRequestsEnabled(true)
+	entry := logrus.NewEntry(logrus.New()).
+		WithContext(WithOperation(context.Background(), "op", "caller")).
+		WithFields(logrus.Fields{HTTPMethodKey: "GET", HTTPURLKey: "/orders"})
+
+	items, err := hook.buildItems(entry)
+	assert.NoError(err)
+	if assert.Len(items, 2) {
+		request := contracts.ContextTags(items[1].ContextTags()).Operation()
+		assert.Equal("op", request.GetId())
+		assert.Equal("caller", request.GetParentId())
+		trace := contracts.ContextTags(items[0].ContextTags()).Operation()
+		assert.Equal("op", trace.GetId())
+		assert.NotEqual("caller", trace.GetParentId())
+	}
+}
+
+func TestCorrelateTraceContext(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields    logrus.Fields
+		operation string
+		parent    string
+	}{
+		{logrus.Fields{TraceparentKey: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"},
+		{logrus.Fields{TraceparentKey: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"},
+		{logrus.Fields{TraceparentKey: "invalid"}, "op", "parent"},
+		{logrus.Fields{TraceIDKey: "4bf92f3577b34da6a3ce929d0e0e4736", SpanIDKey: "00f067aa0ba902b7"}, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"},
+		{logrus.Fields{TraceIDKey: "4bf92f3577b34da6a3ce929d0e0e4736"}, "4bf92f3577b34da6a3ce929d0e0e4736", ""},
+		{logrus.Fields{TraceIDKey: "short", SpanIDKey: "00f067aa0ba902b7"}, "op", "parent"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		// the fields take precedence over the context
+		entry := logrus.NewEntry(logrus.New()).
+			WithContext(WithOperation(context.Background(), "op", "parent")).
+			WithFields(tt.fields)
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		tags := contracts.ContextTags(items[0].ContextTags()).Operation()
+		assert.Equal(tt.operation, tags.GetId(), target)
+		assert.Equal(tt.parent, tags.GetParentId(), target)
+	}
+}
@@ -0,0 +1,127 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// Reserved fields describing a database call. Entries carrying a statement
+// are sent as dependency telemetry instead of traces.
+const (
+	DBSystemKey    = "db.system"
+	DBStatementKey = "db.statement"
+	DBRowsKey      = "db.rows"
+	DBDurationKey  = "db.duration"
+)
+
+// maxDBNameLength bounds the dependency name derived from the statement,
+// which should stay low cardinality.
+const maxDBNameLength = 128
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlHexLiteral     = regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b`)
+	sqlNumericLiteral = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlWhitespace     = regexp.MustCompile(`\s+`)
+)
+
+// DBFields returns the reserved fields for a database call log.
+func DBFields(system, statement string, rows int64, duration time.Duration) logrus.Fields {
+	return logrus.Fields{
+		DBSystemKey:    system,
+		DBStatementKey: statement,
+		DBRowsKey:      rows,
+		DBDurationKey:  duration,
+	}
+}
+
+// SanitizeStatement strips literal parameters from a SQL statement so it
+// carries no data values and groups well.
+func SanitizeStatement(statement string) string {
+	statement = sqlStringLiteral.ReplaceAllString(statement, "?")
+	statement = sqlHexLiteral.ReplaceAllString(statement, "?")
+	statement = sqlNumericLiteral.ReplaceAllString(statement, "?")
+	return strings.TrimSpace(sqlWhitespace.ReplaceAllString(statement, " "))
+}
+
+func isDBEntry(entry *logrus.Entry) bool {
+	_, ok := entry.Data[DBStatementKey]
+	return ok
+}
+
+// buildDBDependency returns the dependency telemetry for a database call log.
+func (hook *AppInsightsHook) buildDBDependency(entry *logrus.Entry) *appinsights.RemoteDependencyTelemetry {
+	system, _ := entry.Data[DBSystemKey].(string)
+	statement, _ := entry.Data[DBStatementKey].(string)
+	statement = SanitizeStatement(statement)
+
+	name := truncateBytes(statement, maxDBNameLength)
+	dependency := appinsights.NewRemoteDependencyTelemetry(name, system, system, entry.Level > logrus.ErrorLevel)
+	dependency.Data = statement
+	duration, _ := toDuration(entry.Data[DBDurationKey])
+	dependency.MarkTime(hook.entryTime(entry).Add(-duration), hook.entryTime(entry))
+	if id, ok := entry.Data[DependencyIDKey]; ok {
+		dependency.Id = fmt.Sprintf("%v", id)
+	}
+	if rows, ok := toFloat(entry.Data[DBRowsKey]); ok {
+		dependency.Measurements[DBRowsKey] = rows
+	}
+
+	for k, v := range hook.buildProperties(entry) {
+		switch k {
+		case DBSystemKey, DBStatementKey, DBRowsKey, DBDurationKey, DependencyIDKey:
+		default:
+			dependency.Properties[k] = v
+		}
+	}
+	return dependency
+}
+
+// toDuration converts a duration field, given either as a time.Duration or
+// as a number of milliseconds.
+func toDuration(value interface{}) (time.Duration, bool) {
+	if d, ok := value.(time.Duration); ok {
+		return d, true
+	}
+	if ms, ok := toFloat(value); ok {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	return 0, false
+}
+
+// toFloat converts numeric field values to float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,83 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeStatement(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		statement string
+		expected  string
+	}{
+		{"SELECT * FROM users WHERE id = 42", "SELECT * FROM users WHERE id = ?"},
+		{"SELECT * FROM users WHERE name = 'O''Brien' AND age > 3.5", "SELECT * FROM users WHERE name = ? AND age > ?"},
+		{"UPDATE t1 SET data = 0xDEADBEEF\n\tWHERE  id IN (1, 2, 3)", "UPDATE t1 SET data = ? WHERE id IN (?, ?, ?)"},
+		{"SELECT id FROM table2 WHERE id = $1", "SELECT id FROM table2 WHERE id = $?"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Equal(tt.expected, SanitizeStatement(tt.statement), target)
+	}
+}
+
+func TestBuildDBDependency(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	entry := logrus.NewEntry(logrus.New()).
+		WithFields(DBFields("postgresql", "SELECT * FROM users WHERE id = 42", 1, time.Millisecond*20)).
+		WithField("tag", "fieldTag")
+	entry.Level = logrus.InfoLevel
+	entry.Message = "query"
+	entry.Time = time.Now()
+
+	item, err := hook.buildItem(entry)
+	assert.NoError(err)
+
+	dependency, ok := item.(*appinsights.RemoteDependencyTelemetry)
+	if !assert.True(ok) {
+		return
+	}
+	assert.Equal("SELECT * FROM users WHERE id = ?", dependency.Name)
+	assert.Equal("SELECT * FROM users WHERE id = ?", dependency.Data)
+	assert.Equal("postgresql", dependency.Type)
+	assert.Equal(time.Millisecond*20, dependency.Duration)
+	assert.Equal(entry.Time.Add(-time.Millisecond*20), dependency.Timestamp)
+	assert.True(dependency.Success)
+	assert.Equal(1.0, dependency.Measurements[DBRowsKey])
+	assert.Equal("fieldTag", dependency.Properties["tag"])
+	assert.NotContains(dependency.Properties, DBStatementKey)
+
+	entry.Level = logrus.ErrorLevel
+	item, _ = hook.buildItem(entry)
+	assert.False(item.(*appinsights.RemoteDependencyTelemetry).Success)
+}
+
+func TestBuildDBDependencyLongName(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	// the 128th byte falls within a two-byte character
+	statement := "SELECT " + strings.Repeat("é", 100) + " FROM users"
+	entry := logrus.NewEntry(logrus.New()).WithFields(DBFields("postgresql", statement, 1, 0))
+
+	item, err := hook.buildItem(entry)
+	assert.NoError(err)
+	name := item.(*appinsights.RemoteDependencyTelemetry).Name
+	assert.True(utf8.ValidString(name))
+	assert.Equal(127, len(name))
+	assert.Equal(statement, item.(*appinsights.RemoteDependencyTelemetry).Data)
+}
@@ -0,0 +1,270 @@
+package logrus_appinsights
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of recent delivery latencies percentiles are
+// computed over.
+const latencySamples = 1024
+
+// deliveryStats aggregates the outcome of every batch submitted by the hook.
+type deliveryStats struct {
+	mu        sync.Mutex
+	tracking  bool
+	accepted  uint64
+	rejected  uint64
+	latencies [latencySamples]time.Duration
+	next      int
+	count     int
+}
+
+func (s *deliveryStats) setTracking(tracking bool) {
+	s.mu.Lock()
+	s.tracking = tracking
+	s.mu.Unlock()
+}
+
+func (s *deliveryStats) isTracking() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tracking
+}
+
+// record adds the outcome of a batch. latencies holds the delivery latency of
+// every accepted item when tracking.
+func (s *deliveryStats) record(accepted, rejected int, latencies []time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accepted += uint64(accepted)
+	s.rejected += uint64(rejected)
+	for _, latency := range latencies {
+		s.latencies[s.next] = latency
+		s.next = (s.next + 1) % latencySamples
+		if s.count < latencySamples {
+			s.count++
+		}
+	}
+}
+
+// percentiles returns the latency percentiles p (between 0 and 1) of the
+// recent deliveries.
+func (s *deliveryStats) percentiles(p ...float64) []time.Duration {
+	s.mu.Lock()
+	samples := make([]time.Duration, s.count)
+	copy(samples, s.latencies[:s.count])
+	s.mu.Unlock()
+
+	result := make([]time.Duration, len(p))
+	if len(samples) == 0 {
+		return result
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	for i, q := range p {
+		idx := int(q*float64(len(samples))+0.5) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(samples) {
+			idx = len(samples) - 1
+		}
+		result[i] = samples[idx]
+	}
+	return result
+}
+
+// deliveryTransport observes the batches submitted to the ingestion endpoint
+// and records their outcome.
+type deliveryTransport struct {
+	base  http.RoundTripper
+	stats *deliveryStats
+
+	// inflight bounds the batches submitted concurrently, if set
+	inflight chan struct{}
+
+	// idempotency keys the batches submitted, if set
+	idempotency *idempotencyKeys
+
+	// chain chains the signed items of the batches submitted
+	chain *chainKey
+
+	// batches accounts the envelopes of the batches submitted, if set
+	batches *batchBytes
+
+	// store keeps the batches that could not be delivered, if set
+	store      Store
+	mu         sync.Mutex
+	endpoint   *url.URL
+	replayOnce sync.Once
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+func newDeliveryTransport(base http.RoundTripper, stats *deliveryStats) *deliveryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &deliveryTransport{base: base, stats: stats, chain: &chainKey{}, stop: make(chan struct{})}
+}
+
+// delivery returns the transport observing the batches of the hook, if any.
+func (hook *AppInsightsHook) delivery() *deliveryTransport {
+	if hook.httpClient == nil {
+		return nil
+	}
+	delivery, _ := hook.httpClient.Transport.(*deliveryTransport)
+	return delivery
+}
+
+// limitInflight bounds the batches submitted concurrently to n, queueing the
+// others. A value of zero or less leaves them unbounded.
+func (t *deliveryTransport) limitInflight(n int) {
+	if n > 0 {
+		t.inflight = make(chan struct{}, n)
+	}
+}
+
+func (t *deliveryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.inflight != nil {
+		select {
+		case t.inflight <- struct{}{}:
+			defer func() { <-t.inflight }()
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	integrity := t.chain.get()
+	tracking := t.batches.isTracking()
+	if req.Body == nil || (t.store == nil && t.idempotency == nil && integrity == nil && !tracking && !t.stats.isTracking()) {
+		return t.observe(req, nil)
+	}
+	payload, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if integrity != nil {
+		if chained, err := integrity.chainBatch(payload, req.Header.Get("Content-Encoding") == "gzip"); err == nil {
+			payload = chained
+			req = req.Clone(req.Context())
+			req.ContentLength = int64(len(payload))
+		}
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	var sums [][sha256.Size]byte
+	if tracking {
+		sums = t.batches.submit(payload, req.Header.Get("Content-Encoding") == "gzip")
+	}
+	var resp *http.Response
+	if t.store != nil {
+		resp, err = t.storeAndForward(req, payload)
+	} else {
+		resp, err = t.observe(req, payload)
+	}
+	t.batches.complete(sums, resp, err)
+	return resp, err
+}
+
+// observe sends req and records the outcome of the batch. payload is the
+// body of req, needed to track latency.
+func (t *deliveryTransport) observe(req *http.Request, payload []byte) (*http.Response, error) {
+	req = t.setIdempotencyKey(req, payload)
+	var times []time.Time
+	if payload != nil && t.stats.isTracking() {
+		times = envelopeTimes(payload, req.Header.Get("Content-Encoding") == "gzip")
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	acknowledged := time.Now()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	response, ok := decodeResponse(body, resp.Header.Get("Content-Encoding") == "gzip")
+	if !ok {
+		if resp.StatusCode == http.StatusOK {
+			// some proxies reply with an empty body, trust the status code
+			response.ItemsReceived = len(times)
+			response.ItemsAccepted = len(times)
+		} else {
+			return resp, nil
+		}
+	}
+
+	rejected := make(map[int]struct{}, len(response.Errors))
+	for _, e := range response.Errors {
+		rejected[e.Index] = struct{}{}
+	}
+	var latencies []time.Duration
+	for i, created := range times {
+		if _, ok := rejected[i]; !ok && !created.IsZero() {
+			latencies = append(latencies, acknowledged.Sub(created))
+		}
+	}
+	t.stats.record(response.ItemsAccepted, response.ItemsReceived-response.ItemsAccepted, latencies)
+	return resp, nil
+}
+
+func decodeResponse(body []byte, gzipped bool) (backendResponse, bool) {
+	response := backendResponse{}
+	var reader io.Reader = bytes.NewReader(body)
+	if gzipped {
+		gzipReader, err := getGzipReader(reader)
+		if err != nil {
+			return response, false
+		}
+		defer putGzipReader(gzipReader)
+		reader = gzipReader
+	}
+	if err := json.NewDecoder(reader).Decode(&response); err != nil {
+		return response, false
+	}
+	return response, true
+}
+
+// envelopeTimes returns the time of every envelope of a submitted payload.
+func envelopeTimes(payload []byte, gzipped bool) []time.Time {
+	var reader io.Reader = bytes.NewReader(payload)
+	if gzipped {
+		gzipReader, err := getGzipReader(reader)
+		if err != nil {
+			return nil
+		}
+		defer putGzipReader(gzipReader)
+		reader = gzipReader
+	}
+
+	var times []time.Time
+	buf := scanBuffers.Get().(*[]byte)
+	defer scanBuffers.Put(buf)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(*buf, 64*1024*1024)
+	for scanner.Scan() {
+		var envelope struct {
+			Time time.Time `json:"time"`
+		}
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		json.Unmarshal(scanner.Bytes(), &envelope)
+		times = append(times, envelope.Time)
+	}
+	return times
+}
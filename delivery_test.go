@@ -0,0 +1,161 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryTransport(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		statusCode int
+		body       string
+		accepted   uint64
+		rejected   uint64
+		latencies  int
+	}{
+		{http.StatusOK, `{"itemsReceived":2,"itemsAccepted":2,"errors":[]}`, 2, 0, 2},
+		{http.StatusOK, ``, 2, 0, 2},
+		{http.StatusPartialContent, `{"itemsReceived":2,"itemsAccepted":1,"errors":[{"index":0,"statusCode":400,"message":"invalid"}]}`, 1, 1, 1},
+		{http.StatusInternalServerError, ``, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.statusCode)
+			w.Write([]byte(tt.body))
+		}))
+
+		stats := &deliveryStats{}
+		stats.setTracking(true)
+		client := &http.Client{Transport: newDeliveryTransport(nil, stats)}
+
+		hook := AppInsightsHook{}
+		var envelopes []*contracts.Envelope
+		for i := 0; i < 2; i++ {
+			entry := logrus.NewEntry(logrus.New())
+			entry.Message = "delivered"
+			entry.Time = time.Now().Add(-time.Second)
+			item, err := hook.buildItem(entry)
+			assert.NoError(err, target)
+			envelopes = append(envelopes, envelop(appinsights.NewTelemetryContext("NotEmpty"), item))
+		}
+		transmit(client, server.URL, envelopes, defaultEncoding)
+		server.Close()
+
+		stats.mu.Lock()
+		assert.Equal(tt.accepted, stats.accepted, target)
+		assert.Equal(tt.rejected, stats.rejected, target)
+		assert.Equal(tt.latencies, stats.count, target)
+		for i := 0; i < stats.count; i++ {
+			assert.True(stats.latencies[i] >= time.Second, target)
+		}
+		stats.mu.Unlock()
+	}
+}
+
+func TestDeliveryStatsPercentiles(t *testing.T) {
+	assert := assert.New(t)
+
+	stats := &deliveryStats{}
+	assert.Equal([]time.Duration{0, 0}, stats.percentiles(0.5, 0.99))
+
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	stats.record(100, 0, latencies)
+	assert.Equal([]time.Duration{50 * time.Millisecond, 90 * time.Millisecond, 99 * time.Millisecond}, stats.percentiles(0.5, 0.9, 0.99))
+
+	// only the most recent samples are kept
+	for i := 0; i < latencySamples; i++ {
+		stats.record(1, 0, []time.Duration{time.Second})
+	}
+	assert.Equal([]time.Duration{time.Second}, stats.percentiles(0.5))
+}
+
+func TestLimitInflight(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		limit    int
+		expected int32
+	}{
+		{1, 1},
+		{2, 2},
+		{0, 4},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		var current, peak int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&current, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt32(&current, -1)
+		}))
+
+		transport := newDeliveryTransport(nil, &deliveryStats{})
+		transport.limitInflight(tt.limit)
+		client := &http.Client{Transport: transport}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Post(server.URL, "application/x-json-stream", strings.NewReader("{}"))
+				if assert.NoError(err, target) {
+					resp.Body.Close()
+				}
+			}()
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		server.Close()
+
+		assert.Equal(tt.expected, atomic.LoadInt32(&peak), target)
+	}
+}
+
+func BenchmarkEnvelopeTimes(b *testing.B) {
+	client := appinsights.NewTelemetryClient("key")
+	envelopes := make([]*contracts.Envelope, 100)
+	for i := range envelopes {
+		envelopes[i] = envelop(client.Context(), appinsights.NewTraceTelemetry("my message", appinsights.Information))
+	}
+	payload, err := encodePayload(envelopes, defaultEncoding)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if times := envelopeTimes(payload.Bytes(), true); len(times) != len(envelopes) {
+			b.Fatal(len(times))
+		}
+	}
+}
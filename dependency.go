@@ -0,0 +1,59 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// Conventional fields describing an outbound call. Entries carrying a
+// dependency type are sent as dependency telemetry named after their message
+// instead of traces.
+const (
+	DependencyTypeKey   = "dependency.type"
+	DependencyTargetKey = "dependency.target"
+	SuccessKey          = "success"
+)
+
+func isDependencyEntry(entry *logrus.Entry) bool {
+	_, ok := entry.Data[DependencyTypeKey]
+	return ok
+}
+
+// buildDependency returns the dependency telemetry for an outbound call log.
+// Calls are successful unless SuccessKey says otherwise or the entry is an
+// error.
+func (hook *AppInsightsHook) buildDependency(entry *logrus.Entry) *appinsights.RemoteDependencyTelemetry {
+	dependencyType := fmt.Sprintf("%v", entry.Data[DependencyTypeKey])
+	target := ""
+	if v, ok := entry.Data[DependencyTargetKey]; ok {
+		target = fmt.Sprintf("%v", v)
+	}
+	success := entry.Level > logrus.ErrorLevel
+	switch v := entry.Data[SuccessKey].(type) {
+	case bool:
+		success = v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			success = b
+		}
+	}
+
+	dependency := appinsights.NewRemoteDependencyTelemetry(entry.Message, dependencyType, target, success)
+	duration, _ := toDuration(entry.Data[DurationKey])
+	dependency.MarkTime(hook.entryTime(entry).Add(-duration), hook.entryTime(entry))
+	if id, ok := entry.Data[DependencyIDKey]; ok {
+		dependency.Id = fmt.Sprintf("%v", id)
+	}
+
+	for k, v := range hook.buildProperties(entry) {
+		switch k {
+		case DependencyTypeKey, DependencyTargetKey, SuccessKey, DurationKey, DependencyIDKey:
+		default:
+			dependency.Properties[k] = v
+		}
+	}
+	return dependency
+}
@@ -0,0 +1,54 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildDependency(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		level    logrus.Level
+		fields   logrus.Fields
+		success  bool
+		target   string
+		duration time.Duration
+	}{
+		{logrus.InfoLevel, logrus.Fields{DependencyTypeKey: "HTTP"}, true, "", 0},
+		{logrus.ErrorLevel, logrus.Fields{DependencyTypeKey: "HTTP"}, false, "", 0},
+		{logrus.InfoLevel, logrus.Fields{DependencyTypeKey: "HTTP", DependencyTargetKey: "api.example.com", DurationKey: 35}, true, "api.example.com", 35 * time.Millisecond},
+		{logrus.InfoLevel, logrus.Fields{DependencyTypeKey: "Azure blob", SuccessKey: false}, false, "", 0},
+		{logrus.ErrorLevel, logrus.Fields{DependencyTypeKey: "Azure blob", SuccessKey: "true"}, true, "", 0},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields).WithField("tag", "fieldTag")
+		entry.Level = tt.level
+		entry.Message = "fetch profile"
+		entry.Time = time.Now()
+
+		item, err := hook.buildItem(entry)
+		assert.NoError(err, target)
+		dependency, ok := item.(*appinsights.RemoteDependencyTelemetry)
+		if !assert.True(ok, target) {
+			continue
+		}
+		assert.Equal("fetch profile", dependency.Name, target)
+		assert.Equal(tt.fields[DependencyTypeKey], dependency.Type, target)
+		assert.Equal(tt.target, dependency.Target, target)
+		assert.Equal(tt.success, dependency.Success, target)
+		assert.Equal(tt.duration, dependency.Duration, target)
+		assert.Equal(entry.Time.Add(-tt.duration), dependency.Timestamp, target)
+		assert.Equal("fieldTag", dependency.Properties["tag"], target)
+		assert.NotContains(dependency.Properties, DependencyTypeKey, target)
+	}
+}
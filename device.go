@@ -0,0 +1,69 @@
+package logrus_appinsights
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+// DeviceTagPolicy decides how the device ID and role instance tags, derived
+// from the host name, are sent, along with the resource attributes naming the
+// host, pod, node or virtual machine.
+type DeviceTagPolicy int
+
+const (
+	// KeepDeviceTags sends the tags as is.
+	KeepDeviceTags DeviceTagPolicy = iota
+	// SuppressDeviceTags does not send the tags.
+	SuppressDeviceTags
+	// HashDeviceTags sends a hash of the tags, still telling devices apart
+	// without revealing their name.
+	HashDeviceTags
+)
+
+// deviceTags are the context tags identifying the device.
+var deviceTags = []string{
+	contracts.DeviceId,
+	contracts.CloudRoleInstance,
+	"ai.device.machineName",
+}
+
+// deviceAttributes are the resource attributes identifying the device, sent
+// according to the policy as well.
+var deviceAttributes = map[string]struct{}{
+	"host.name":     {},
+	"k8s.pod.name":  {},
+	"k8s.node.name": {},
+	"vm.id":         {},
+	"vm.name":       {},
+}
+
+// anonymize returns the value sent for a device tag, or false if none is.
+func (p DeviceTagPolicy) anonymize(value string) (string, bool) {
+	switch p {
+	case SuppressDeviceTags:
+		return "", false
+	case HashDeviceTags:
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:16]), true
+	default:
+		return value, true
+	}
+}
+
+// apply anonymizes the device tags of ctx.
+func (p DeviceTagPolicy) apply(ctx *appinsights.TelemetryContext) {
+	for _, tag := range deviceTags {
+		value, ok := ctx.Tags[tag]
+		if !ok {
+			continue
+		}
+		if value, ok = p.anonymize(value); ok {
+			ctx.Tags[tag] = value
+		} else {
+			delete(ctx.Tags, tag)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceTags(t *testing.T) {
+	assert := assert.New(t)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	hashed, _ := HashDeviceTags.anonymize(hostname)
+
+	tests := []struct {
+		policy   DeviceTagPolicy
+		expected string
+	}{
+		{KeepDeviceTags, hostname},
+		{SuppressDeviceTags, ""},
+		{HashDeviceTags, hashed},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook, err := New("test", Config{
+			InstrumentationKey:        "NotEmpty",
+			SecondaryConnectionString: "InstrumentationKey=Secondary",
+			DeviceTags:                tt.policy,
+		})
+		assert.NoError(err, target)
+		for _, ctx := range hook.contexts() {
+			for _, tag := range []string{contracts.DeviceId, contracts.CloudRoleInstance} {
+				value, ok := ctx.Tags[tag]
+				assert.Equal(tt.expected != "", ok, target)
+				assert.Equal(tt.expected, value, target)
+			}
+		}
+
+		err = hook.AddResourceDetectors(ResourceDetectorFunc(func() (map[string]string, error) {
+			return map[string]string{RoleInstanceKey: hostname, "host.name": hostname, "vm.name": hostname, "os.type": "linux"}, nil
+		}))
+		assert.NoError(err, target)
+		assert.Equal(tt.expected, hook.client.Context().Tags[contracts.CloudRoleInstance], target)
+		for _, attribute := range []string{"host.name", "vm.name"} {
+			value, ok := hook.client.Context().CommonProperties[attribute]
+			assert.Equal(tt.expected != "", ok, target)
+			assert.Equal(tt.expected, value, target)
+		}
+		assert.Equal("linux", hook.client.Context().CommonProperties["os.type"], target)
+		hook.Close()
+	}
+}
@@ -0,0 +1,56 @@
+package logrus_appinsights
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+)
+
+// dryRunClient writes the envelopes of the items it tracks to a writer
+// instead of sending them.
+type dryRunClient struct {
+	appinsights.TelemetryClient
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// SetDryRun sets the hook to write the envelopes it would send to w, one JSON
+// object per line, instead of sending them to Application Insights, e.g. to
+// validate filters, sampling and schema changes locally. Every setting of the
+// hook applies as usual. It must be set before creating pipelines.
+func (hook *AppInsightsHook) SetDryRun(w io.Writer) {
+	if dryRun := hook.dryRunClient(); dryRun != nil {
+		dryRun.mu.Lock()
+		dryRun.w = w
+		dryRun.mu.Unlock()
+		return
+	}
+	hook.client = &dryRunClient{TelemetryClient: hook.client, w: w}
+	hook.canary = nil
+}
+
+// Track writes the envelope of item.
+func (c *dryRunClient) Track(item appinsights.Telemetry) {
+	envelope := envelop(c.Context(), item)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	json.NewEncoder(c.w).Encode(envelope)
+}
+
+// dryRun reports whether the hook is in dry-run mode.
+func (hook *AppInsightsHook) dryRun() bool {
+	return hook.dryRunClient() != nil
+}
+
+// dryRunClient returns the dry-run client of the hook, if any.
+func (hook *AppInsightsHook) dryRunClient() *dryRunClient {
+	for _, client := range hook.clients() {
+		if dryRun, ok := client.(*dryRunClient); ok {
+			return dryRun
+		}
+	}
+	return nil
+}
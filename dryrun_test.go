@@ -0,0 +1,47 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetDryRun(t *testing.T) {
+	assert := assert.New(t)
+
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	defer server.Close()
+
+	buffer := new(bytes.Buffer)
+	hook, err := New("test", Config{InstrumentationKey: "key", EndpointUrl: server.URL, DryRun: buffer})
+	assert.NoError(err)
+	hook.SetPIIScrubbing(EmailAddresses)
+	hook.AddMetricMapping("queue_depth", "QueueDepth")
+
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"user": "jane@example.com", "queue_depth": 3})
+	entry.Message = "dequeued"
+	assert.NoError(hook.Fire(entry))
+	assert.NoError(hook.FireAndWait(entry))
+	hook.Close()
+	assert.Equal(int32(0), atomic.LoadInt32(&received))
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(lines, 4)
+	var envelope jsonMessage
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &envelope))
+	assert.NoError(envelope.assertPath("iKey", "key"))
+	assert.NoError(envelope.assertPath("data.baseData.message", "dequeued"))
+	assert.NoError(envelope.assertPath("data.baseData.properties.user", "[email]"))
+	tags, _ := envelope.getPath("tags")
+	assert.Equal("test", tags.(map[string]interface{})["ai.cloud.role"])
+}
@@ -0,0 +1,30 @@
+package logrus_appinsights
+
+import (
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// EventKey is the reserved field marking an entry to be sent as event
+// telemetry, e.g. log.WithField(EventKey, true).Info("checkout completed").
+const EventKey = "ai_event"
+
+// isEventEntry reports whether entry is marked to be sent as an event.
+func isEventEntry(entry *logrus.Entry) bool {
+	switch v := entry.Data[EventKey].(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	default:
+		return v != nil
+	}
+}
+
+// buildEvent returns the event telemetry for entry, named after its message.
+func (hook *AppInsightsHook) buildEvent(entry *logrus.Entry) *appinsights.EventTelemetry {
+	event := appinsights.NewEventTelemetry(entry.Message)
+	event.Timestamp = hook.entryTime(entry)
+	event.Properties = hook.buildProperties(entry)
+	return event
+}
@@ -0,0 +1,69 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEventEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		value    interface{}
+		expected bool
+	}{
+		{nil, false},
+		{false, false},
+		{"", false},
+		{true, true},
+		{"yes", true},
+		{1, true},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		entry := logrus.NewEntry(logrus.New())
+		if tt.value != nil {
+			entry.Data[EventKey] = tt.value
+		}
+		assert.Equal(tt.expected, isEventEntry(entry), target)
+	}
+}
+
+func TestBuildEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	entry := logrus.NewEntry(logrus.New()).WithField("tag", "fieldTag")
+	entry.Message = "checkout completed"
+
+	item, err := hook.buildItem(entry)
+	assert.NoError(err)
+	assert.IsType(&appinsights.TraceTelemetry{}, item)
+
+	// per entry
+	marked := entry.WithField(EventKey, true)
+	marked.Message = "item added"
+	item, err = hook.buildItem(marked)
+	assert.NoError(err)
+	event, ok := item.(*appinsights.EventTelemetry)
+	if assert.True(ok) {
+		assert.Equal("item added", event.Name)
+		assert.NotContains(event.Properties, EventKey)
+	}
+
+	// globally
+	hook.SetEventsEnabled(true)
+	item, err = hook.buildItem(entry)
+	assert.NoError(err)
+	event, ok = item.(*appinsights.EventTelemetry)
+	if assert.True(ok) {
+		assert.Equal("checkout completed", event.Name)
+		assert.Equal("fieldTag", event.Properties["tag"])
+	}
+}
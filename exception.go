@@ -0,0 +1,50 @@
+package logrus_appinsights
+
+import (
+	"reflect"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	hookPackage   = reflect.TypeOf(AppInsightsHook{}).PkgPath()
+	logrusPackage = reflect.TypeOf(logrus.Entry{}).PkgPath()
+)
+
+// entryError returns the error of an Error, Fatal or Panic entry.
+func entryError(entry *logrus.Entry) (error, bool) {
+	if entry.Level > logrus.ErrorLevel {
+		return nil, false
+	}
+	err, ok := entry.Data[logrus.ErrorKey].(error)
+	return err, ok && err != nil
+}
+
+// buildException returns the exception telemetry for an entry carrying err,
+// with secrets and personal data scrubbed from the type and message of err.
+func (hook *AppInsightsHook) buildException(entry *logrus.Entry, err error) appinsights.Telemetry {
+	exception := appinsights.NewExceptionTelemetry(err)
+	exception.Frames = callerStack()
+	exception.SeverityLevel = hook.severity(entry)
+	exception.Timestamp = hook.entryTime(entry)
+	exception.Properties = hook.buildProperties(entry)
+	if hook.pii == 0 && !hook.secrets {
+		return exception
+	}
+	return &typedException{exception, hook.scrub(reflect.TypeOf(err).String()), hook.scrub(err.Error())}
+}
+
+// callerStack returns the current callstack without the frames of the hook
+// and logrus, so it starts at the logging call site.
+func callerStack() []*contracts.StackFrame {
+	frames := appinsights.GetCallstack(1)
+	for len(frames) > 0 && (frames[0].Assembly == hookPackage || frames[0].Assembly == logrusPackage) {
+		frames = frames[1:]
+	}
+	for i, frame := range frames {
+		frame.Level = i
+	}
+	return frames
+}
@@ -0,0 +1,72 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryError(t *testing.T) {
+	assert := assert.New(t)
+
+	err := errors.New("this is a test error")
+	tests := []struct {
+		level    logrus.Level
+		value    interface{}
+		expected bool
+	}{
+		{logrus.ErrorLevel, err, true},
+		{logrus.FatalLevel, err, true},
+		{logrus.PanicLevel, err, true},
+		{logrus.WarnLevel, err, false},
+		{logrus.ErrorLevel, "not an error", false},
+		{logrus.ErrorLevel, nil, false},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		entry := logrus.NewEntry(logrus.New())
+		entry.Level = tt.level
+		if tt.value != nil {
+			entry.Data[logrus.ErrorKey] = tt.value
+		}
+		_, ok := entryError(entry)
+		assert.Equal(tt.expected, ok, target)
+	}
+}
+
+func TestBuildException(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	entry := logrus.NewEntry(logrus.New()).WithError(errors.New("boom")).WithField("tag", "fieldTag")
+	entry.Level = logrus.ErrorLevel
+	entry.Message = "failed"
+
+	item, err := hook.buildItem(entry)
+	assert.NoError(err)
+	assert.IsType(&appinsights.TraceTelemetry{}, item)
+
+	hook.SetExceptionsEnabled(true)
+	item, err = hook.buildItem(entry)
+	assert.NoError(err)
+
+	exception, ok := item.(*appinsights.ExceptionTelemetry)
+	if !assert.True(ok) {
+		return
+	}
+	assert.Equal(entry.Data[logrus.ErrorKey], exception.Error)
+	assert.Equal(appinsights.Error, exception.SeverityLevel)
+	assert.Equal("fieldTag", exception.Properties["tag"])
+	assert.Equal("failed", exception.Properties["message"])
+	if assert.NotEmpty(exception.Frames) {
+		assert.NotEqual(hookPackage, exception.Frames[0].Assembly)
+		assert.NotEqual(logrusPackage, exception.Frames[0].Assembly)
+		assert.Equal(0, exception.Frames[0].Level)
+	}
+}
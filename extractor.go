@@ -0,0 +1,70 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"strings"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// contextTagPrefix marks the extracted values set as context tags, such as
+// ai.user.id, rather than properties.
+const contextTagPrefix = "ai."
+
+// AddContextExtractor registers a function pulling values such as request
+// IDs, tenant IDs or auth subjects from the context of entries. Values are
+// sent as properties, unless their key is a context tag such as ai.user.id.
+// Fields take precedence over extracted values, and earlier extractors over
+// later ones. Extractors may be called several times per entry.
+func (hook *AppInsightsHook) AddContextExtractor(extractor func(ctx context.Context) map[string]string) {
+	hook.extractors = append(hook.extractors, extractor)
+}
+
+// extract returns the values extracted from the entry's context for which
+// keep returns true.
+func (hook *AppInsightsHook) extract(entry *logrus.Entry, keep func(key string) bool) map[string]string {
+	if len(hook.extractors) == 0 || entry.Context == nil {
+		return nil
+	}
+	values := make(map[string]string)
+	for _, extractor := range hook.extractors {
+		for k, v := range extractor(entry.Context) {
+			if _, ok := values[k]; !ok && keep(k) {
+				values[k] = v
+			}
+		}
+	}
+	return values
+}
+
+func isContextTag(key string) bool {
+	return strings.HasPrefix(key, contextTagPrefix)
+}
+
+func isNotContextTag(key string) bool {
+	return !isContextTag(key)
+}
+
+// addContextProperties adds the values extracted from the entry's context
+// that are not already set.
+func (hook *AppInsightsHook) addContextProperties(props map[string]string, entry *logrus.Entry) {
+	for k, v := range hook.extract(entry, isNotContextTag) {
+		if _, ok := entry.Data[k]; !ok {
+			hook.addProperty(props, k, v)
+		}
+	}
+}
+
+// addContextTags sets the context tags extracted from the entry's context on
+// items, unless already set.
+func (hook *AppInsightsHook) addContextTags(entry *logrus.Entry, items ...appinsights.Telemetry) {
+	tags := hook.extract(entry, isContextTag)
+	for _, item := range items {
+		for k, v := range tags {
+			if _, ok := item.ContextTags()[k]; !ok {
+				item.ContextTags()[k] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantKey struct{}
+
+func TestAddContextExtractor(t *testing.T) {
+	assert := assert.New(t)
+
+	tenant := func(ctx context.Context) map[string]string {
+		id, _ := ctx.Value(tenantKey{}).(string)
+		return map[string]string{"tenant": id, "ai.user.authUserId": "user-" + id}
+	}
+	fallback := func(ctx context.Context) map[string]string {
+		return map[string]string{"tenant": "default", "region": "westeurope"}
+	}
+
+	tests := []struct {
+		ctx    context.Context
+		fields logrus.Fields
+		props  map[string]string
+		user   string
+	}{
+		{nil, nil, map[string]string{}, ""},
+		{context.WithValue(context.Background(), tenantKey{}, "contoso"), nil, map[string]string{"tenant": "contoso", "region": "westeurope"}, "user-contoso"},
+		{context.WithValue(context.Background(), tenantKey{}, "contoso"), logrus.Fields{"tenant": "fabrikam"}, map[string]string{"tenant": "fabrikam", "region": "westeurope"}, "user-contoso"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.AddContextExtractor(tenant)
+		hook.AddContextExtractor(fallback)
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+		if tt.ctx != nil {
+			entry = entry.WithContext(tt.ctx)
+		}
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		props := items[0].GetProperties()
+		for k, v := range tt.props {
+			assert.Equal(v, props[k], target)
+		}
+		assert.NotContains(props, "ai.user.authUserId", target)
+		assert.Equal(tt.user, items[0].ContextTags()["ai.user.authUserId"], target)
+	}
+}
+
+func TestContextExtractorTagsAllItems(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.SetRequestsEnabled(true)
+	hook.AddContextExtractor(func(ctx context.Context) map[string]string {
+		return map[string]string{"ai.session.id": "session", "ai.operation.parentId": "ignored"}
+	})
+	entry := logrus.NewEntry(logrus.New()).
+		WithContext(WithOperation(context.Background(), "op", "caller")).
+		WithFields(logrus.Fields{HTTPMethodKey: "GET", HTTPURLKey: "/orders"})
+
+	items, err := hook.buildItems(entry)
+	assert.NoError(err)
+	if assert.Len(items, 2) {
+		for _, item := range items {
+			assert.Equal("session", item.ContextTags()["ai.session.id"])
+			assert.NotEqual("ignored", item.ContextTags()["ai.operation.parentId"])
+		}
+	}
+}
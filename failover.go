@@ -0,0 +1,199 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+const (
+	defaultIngestionEndpoint = "https://dc.services.visualstudio.com"
+	defaultFailoverAfter     = time.Minute
+)
+
+// failoverProbeInterval is how often the primary endpoint health is checked.
+var failoverProbeInterval = time.Second * 10
+
+// parseConnectionString returns the instrumentation key and track endpoint
+// of an Application Insights connection string.
+func parseConnectionString(connectionString string) (iKey string, endpointUrl string, err error) {
+	ingestion := defaultIngestionEndpoint
+	for _, part := range strings.Split(connectionString, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToLower(kv[0]) {
+		case "instrumentationkey":
+			iKey = kv[1]
+		case "ingestionendpoint":
+			ingestion = kv[1]
+		}
+	}
+	if iKey == "" {
+		return "", "", fmt.Errorf("InstrumentationKey is missing from connection string")
+	}
+	return iKey, strings.TrimRight(ingestion, "/") + "/v2/track", nil
+}
+
+// newProbe returns a probe reporting whether an ingestion endpoint is
+// reachable through transport, the default one if nil, and not failing server
+// side.
+func newProbe(transport http.RoundTripper) func(endpoint string) bool {
+	client := &http.Client{Transport: transport, Timeout: failoverProbeInterval}
+	return func(endpoint string) bool {
+		resp, err := client.Post(endpoint, "application/x-json-stream", strings.NewReader(""))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode < http.StatusInternalServerError
+	}
+}
+
+// failoverClient sends telemetry to the primary client, and to the secondary
+// client while the primary endpoint has been unhealthy for longer than after.
+type failoverClient struct {
+	appinsights.TelemetryClient // primary
+
+	secondary appinsights.TelemetryClient
+	after     time.Duration
+	probe     func(endpoint string) bool
+
+	mu             sync.RWMutex
+	unhealthySince time.Time
+	failedOver     bool
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newFailoverClient returns a client failing over from primary to secondary,
+// probing the primary endpoint through transport.
+func newFailoverClient(primary, secondary appinsights.TelemetryClient, after time.Duration, transport http.RoundTripper) *failoverClient {
+	if after <= 0 {
+		after = defaultFailoverAfter
+	}
+	return &failoverClient{
+		TelemetryClient: primary,
+		secondary:       secondary,
+		after:           after,
+		probe:           newProbe(transport),
+		done:            make(chan struct{}),
+	}
+}
+
+// run checks the primary endpoint health at every interval, until stopped.
+func (c *failoverClient) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.check(now)
+		}
+	}
+}
+
+// stop stops checking the primary endpoint health.
+func (c *failoverClient) stop() {
+	c.stopOnce.Do(func() { close(c.done) })
+}
+
+// check probes the primary endpoint and fails over or back accordingly.
+func (c *failoverClient) check(now time.Time) {
+	healthy := c.probe(c.TelemetryClient.Channel().EndpointAddress())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case healthy:
+		c.unhealthySince = time.Time{}
+		c.failedOver = false
+	case c.unhealthySince.IsZero():
+		c.unhealthySince = now
+	case now.Sub(c.unhealthySince) >= c.after:
+		c.failedOver = true
+	}
+}
+
+// active returns the client telemetry is currently sent to.
+func (c *failoverClient) active() appinsights.TelemetryClient {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.failedOver {
+		return c.secondary
+	}
+	return c.TelemetryClient
+}
+
+func (c *failoverClient) InstrumentationKey() string {
+	return c.active().InstrumentationKey()
+}
+
+// Channel returns the channel of the active client, flushing or closing the
+// channels of both clients.
+func (c *failoverClient) Channel() appinsights.TelemetryChannel {
+	return &failoverChannel{c.active().Channel(), c}
+}
+
+type failoverChannel struct {
+	appinsights.TelemetryChannel
+	client *failoverClient
+}
+
+func (ch *failoverChannel) Flush() {
+	ch.client.TelemetryClient.Channel().Flush()
+	ch.client.secondary.Channel().Flush()
+}
+
+func (ch *failoverChannel) Close(timeout ...time.Duration) <-chan struct{} {
+	primary := ch.client.TelemetryClient.Channel().Close(timeout...)
+	secondary := ch.client.secondary.Channel().Close(timeout...)
+	done := make(chan struct{})
+	go func() {
+		<-primary
+		<-secondary
+		close(done)
+	}()
+	return done
+}
+
+func (c *failoverClient) Track(item appinsights.Telemetry) {
+	c.active().Track(item)
+}
+
+func (c *failoverClient) TrackEvent(name string) {
+	c.active().TrackEvent(name)
+}
+
+func (c *failoverClient) TrackMetric(name string, value float64) {
+	c.active().TrackMetric(name, value)
+}
+
+func (c *failoverClient) TrackTrace(message string, severity contracts.SeverityLevel) {
+	c.active().TrackTrace(message, severity)
+}
+
+func (c *failoverClient) TrackRequest(method, url string, duration time.Duration, responseCode string) {
+	c.active().TrackRequest(method, url, duration, responseCode)
+}
+
+func (c *failoverClient) TrackRemoteDependency(name, dependencyType, target string, success bool) {
+	c.active().TrackRemoteDependency(name, dependencyType, target, success)
+}
+
+func (c *failoverClient) TrackAvailability(name string, duration time.Duration, success bool) {
+	c.active().TrackAvailability(name, duration, success)
+}
+
+func (c *failoverClient) TrackException(err interface{}) {
+	c.active().TrackException(err)
+}
@@ -0,0 +1,138 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConnectionString(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		connectionString string
+		iKey             string
+		endpointUrl      string
+		expectErr        bool
+	}{
+		{"InstrumentationKey=abc", "abc", "https://dc.services.visualstudio.com/v2/track", false},
+		{"InstrumentationKey=abc;IngestionEndpoint=https://westeurope-1.in.applicationinsights.azure.com/", "abc", "https://westeurope-1.in.applicationinsights.azure.com/v2/track", false},
+		{"instrumentationkey=abc; ingestionendpoint=https://localhost", "abc", "https://localhost/v2/track", false},
+		{"IngestionEndpoint=https://localhost", "", "", true},
+		{"", "", "", true},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		iKey, endpointUrl, err := parseConnectionString(tt.connectionString)
+		if tt.expectErr {
+			assert.Error(err, target)
+			continue
+		}
+		assert.NoError(err, target)
+		assert.Equal(tt.iKey, iKey, target)
+		assert.Equal(tt.endpointUrl, endpointUrl, target)
+	}
+}
+
+func TestFailoverClient(t *testing.T) {
+	assert := assert.New(t)
+
+	primary := appinsights.NewTelemetryClient("primary")
+	secondary := appinsights.NewTelemetryClient("secondary")
+	client := newFailoverClient(primary, secondary, time.Minute, nil)
+
+	healthy := true
+	client.probe = func(string) bool { return healthy }
+
+	start := time.Now()
+	client.check(start)
+	assert.Equal("primary", client.InstrumentationKey())
+
+	healthy = false
+	client.check(start)
+	client.check(start.Add(time.Second * 30))
+	assert.Equal("primary", client.InstrumentationKey())
+
+	client.check(start.Add(time.Minute))
+	assert.Equal("secondary", client.InstrumentationKey())
+
+	healthy = true
+	client.check(start.Add(time.Minute * 2))
+	assert.Equal("primary", client.InstrumentationKey())
+}
+
+func TestNewWithSecondaryConnectionString(t *testing.T) {
+	assert := assert.New(t)
+
+	hook, err := New("test", Config{
+		InstrumentationKey:        "primary",
+		SecondaryConnectionString: "IngestionEndpoint=https://localhost",
+	})
+	assert.Error(err)
+	assert.Nil(hook)
+
+	hook, err = New("test", Config{
+		InstrumentationKey:        "primary",
+		SecondaryConnectionString: "InstrumentationKey=secondary;IngestionEndpoint=https://localhost",
+	})
+	assert.NoError(err)
+	assert.IsType(&failoverClient{}, hook.client.(*rotatingClient).active())
+	assert.Equal("primary", hook.client.InstrumentationKey())
+}
+
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func TestProbeTransport(t *testing.T) {
+	assert := assert.New(t)
+
+	transport := &countingTransport{}
+	assert.True(newProbe(transport)("https://relay.invalid/v2/track"))
+	assert.Equal(int32(1), atomic.LoadInt32(&transport.requests))
+}
+
+func TestFailoverClientClose(t *testing.T) {
+	assert := assert.New(t)
+
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	defer server.Close()
+
+	newClient := func(iKey string) appinsights.TelemetryClient {
+		conf := appinsights.NewTelemetryConfiguration(iKey)
+		conf.EndpointUrl = server.URL
+		conf.MaxBatchInterval = time.Hour
+		return appinsights.NewTelemetryClientFromConfig(conf)
+	}
+	client := newFailoverClient(newClient("primary"), newClient("secondary"), time.Minute, nil)
+	client.probe = func(string) bool { return false }
+	client.Track(appinsights.NewTraceTelemetry("primary", appinsights.Information))
+	client.check(time.Now())
+	client.check(time.Now().Add(time.Hour))
+	client.Track(appinsights.NewTraceTelemetry("secondary", appinsights.Information))
+
+	select {
+	case <-client.Channel().Close(5 * time.Second):
+	case <-time.After(10 * time.Second):
+		t.Fatal("channels not closed")
+	}
+	assert.Equal(int32(2), atomic.LoadInt32(&received))
+}
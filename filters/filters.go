@@ -0,0 +1,66 @@
+// Package filters provides filters for the fields of the Application Insights
+// hook, to pseudonymise or shorten values with AddFilter or SetGlobalFilter.
+// Values are filtered in their string form, errors and fmt.Stringers included,
+// and nil values are left as they are.
+package filters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Filter transforms the value of a field, as taken by AddFilter.
+type Filter func(v interface{}) interface{}
+
+// HashSHA256 returns a filter replacing values with the hex encoded SHA-256
+// hash of salt followed by the value, so identifiers stay joinable across
+// items without being revealed. Keep salt secret and the same across
+// services to join their telemetry.
+func HashSHA256(salt string) Filter {
+	return stringFilter(func(s string) string {
+		sum := sha256.Sum256([]byte(salt + s))
+		return hex.EncodeToString(sum[:])
+	})
+}
+
+// Truncate returns a filter keeping the first n characters of values.
+func Truncate(n int) Filter {
+	return stringFilter(func(s string) string {
+		if utf8.RuneCountInString(s) <= n {
+			return s
+		}
+		return string([]rune(s)[:n])
+	})
+}
+
+// MaskAllButLast returns a filter replacing every character of values but the
+// last n with *, e.g. ************1111 for a card number.
+func MaskAllButLast(n int) Filter {
+	return stringFilter(func(s string) string {
+		runes := []rune(s)
+		if len(runes) <= n {
+			return s
+		}
+		return strings.Repeat("*", len(runes)-n) + string(runes[len(runes)-n:])
+	})
+}
+
+// Global adapts filter to SetGlobalFilter, applying it to every field.
+func Global(filter Filter) func(key string, v interface{}) (interface{}, bool) {
+	return func(key string, v interface{}) (interface{}, bool) {
+		return filter(v), true
+	}
+}
+
+// stringFilter returns a filter applying fn to the string form of values.
+func stringFilter(fn func(string) string) Filter {
+	return func(v interface{}) interface{} {
+		if v == nil {
+			return nil
+		}
+		return fn(fmt.Sprint(v))
+	}
+}
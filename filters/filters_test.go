@@ -0,0 +1,66 @@
+package filters
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jjcollinge/logrus-appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilters(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		filter   Filter
+		value    interface{}
+		expected interface{}
+	}{
+		{HashSHA256(""), "jane", "81f8f6dde88365f3928796ec7aa53f72820b06db8664f5fe76a7eb13e24546a2"},
+		{HashSHA256("pepper"), "jane", "84a23fe0ffe86c894d59a46a5415d6cab25ddc6a6b5fd2ec31d32c7233c779ec"},
+		{HashSHA256("pepper"), nil, nil},
+		{Truncate(3), "abcdef", "abc"},
+		{Truncate(3), "ab", "ab"},
+		{Truncate(2), "ééé", "éé"},
+		{Truncate(4), errors.New("failure"), "fail"},
+		{MaskAllButLast(4), "4111111111111111", "************1111"},
+		{MaskAllButLast(4), 12345, "*2345"},
+		{MaskAllButLast(4), "123", "123"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Equal(tt.expected, tt.filter(tt.value), target)
+	}
+}
+
+func TestFiltersWithHook(t *testing.T) {
+	assert := assert.New(t)
+
+	var payload string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reader, err := gzip.NewReader(r.Body); err == nil {
+			b, _ := ioutil.ReadAll(reader)
+			payload = string(b)
+		}
+	}))
+	defer server.Close()
+
+	hook, err := logrus_appinsights.New("test", logrus_appinsights.Config{InstrumentationKey: "key", EndpointUrl: server.URL})
+	assert.NoError(err)
+	hook.AddFilter("card", MaskAllButLast(4))
+	hook.SetGlobalFilter(Global(Truncate(20)))
+
+	entry := logrus.NewEntry(logrus.New()).WithField("card", "4111111111111111")
+	entry.Message = "a message longer than twelve characters"
+	assert.NoError(hook.FireAndWait(entry))
+	assert.Contains(payload, `"card":"************1111"`)
+	assert.Contains(payload, `"message":"a message longer tha"`)
+}
@@ -0,0 +1,91 @@
+package logrus_appinsights
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SetFlattening sets the fields holding maps or structs, such as
+// logrus.Fields, to be sent as a property per value with the nested keys
+// joined by separator, e.g. request.headers.host, at most depth levels deep.
+// Deeper values are formatted as usual. A depth of zero disables flattening,
+// the default.
+func (hook *AppInsightsHook) SetFlattening(separator string, depth int) {
+	hook.flattening = flattening{separator: separator, depth: depth}
+}
+
+type flattening struct {
+	separator string
+	depth     int
+}
+
+// addField adds the field k to props, flattening nested values.
+func (hook *AppInsightsHook) addField(props map[string]string, k string, v interface{}) {
+	if hook.flattening.depth > 0 {
+		if hook.ignored(k) {
+			return
+		}
+		if _, _, ok := hook.filterOf(k, v); !ok {
+			hook.addFlattened(props, k, v, hook.flattening.depth)
+			return
+		}
+	}
+	hook.addProperty(props, k, v)
+}
+
+func (hook *AppInsightsHook) addFlattened(props map[string]string, k string, v interface{}, depth int) {
+	nested, ok := nestedValues(v)
+	if _, _, filtered := hook.filterOf(k, v); depth == 0 || !ok || len(nested) == 0 || filtered {
+		hook.addProperty(props, k, v)
+		return
+	}
+	for nk, nv := range nested {
+		hook.addFlattened(props, k+hook.flattening.separator+nk, nv, depth-1)
+	}
+}
+
+// nestedValues returns the values of v by key if it is a map with string keys
+// or a struct, unless it formats itself.
+func nestedValues(v interface{}) (map[string]interface{}, bool) {
+	switch v.(type) {
+	case nil, json.Marshaler, error, fmt.Stringer:
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		nested := make(map[string]interface{}, rv.Len())
+		for _, key := range rv.MapKeys() {
+			nested[key.String()] = rv.MapIndex(key).Interface()
+		}
+		return nested, true
+	case reflect.Struct:
+		nested := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			nested[name] = rv.Field(i).Interface()
+		}
+		return nested, true
+	}
+	return nil, false
+}
@@ -0,0 +1,80 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type flattenedRequest struct {
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Secret  string            `json:"-"`
+	Retries int
+	private string
+}
+
+func TestSetFlattening(t *testing.T) {
+	assert := assert.New(t)
+
+	request := flattenedRequest{
+		Method:  "GET",
+		Headers: map[string]string{"host": "example.com"},
+		Secret:  "hunter2",
+		Retries: 1,
+		private: "hidden",
+	}
+
+	tests := []struct {
+		separator string
+		depth     int
+		fields    logrus.Fields
+		expected  map[string]string
+		absent    []string
+	}{
+		{".", 0, logrus.Fields{"request": map[string]interface{}{"method": "GET"}}, map[string]string{"request": "map[method:GET]"}, []string{"request.method"}},
+		{".", 2, logrus.Fields{"request": request}, map[string]string{
+			"request.method":       "GET",
+			"request.headers.host": "example.com",
+			"request.Retries":      "1",
+		}, []string{"request", "request.Secret", "request.private"}},
+		{"_", 1, logrus.Fields{"request": &request}, map[string]string{
+			"request_method":  "GET",
+			"request_headers": "map[host:example.com]",
+		}, []string{"request_headers_host"}},
+		{".", 3, logrus.Fields{"ctx": logrus.Fields{"user": "jane", "at": time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "err": errors.New("failed")}}, map[string]string{
+			"ctx.user": "jane",
+			"ctx.at":   "2020-01-02 00:00:00 +0000 UTC",
+			"ctx.err":  "failed",
+		}, nil},
+		{".", 3, logrus.Fields{"empty": map[string]int{}, "ignored": map[string]int{"a": 1}, "filtered": map[string]int{"a": 1}}, map[string]string{
+			"empty":    "map[]",
+			"filtered": "1",
+		}, []string{"ignored.a", "filtered.a"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{
+			ignoreFields: map[string]struct{}{},
+			filters:      map[string]func(interface{}) interface{}{},
+		}
+		hook.AddIgnore("ignored")
+		hook.AddFilter("filtered", func(v interface{}) interface{} { return len(v.(map[string]int)) })
+		hook.SetFlattening(tt.separator, tt.depth)
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+
+		props := hook.NewPipeline().buildProperties(entry)
+		for k, v := range tt.expected {
+			assert.Equal(v, props[k], target)
+		}
+		for _, k := range tt.absent {
+			assert.NotContains(props, k, target)
+		}
+	}
+}
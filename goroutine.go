@@ -0,0 +1,29 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"runtime"
+)
+
+// GoroutineIDKey is the property holding the ID of the goroutine that fired
+// an entry, when enabled.
+const GoroutineIDKey = "goroutine_id"
+
+// SetGoroutineIDEnabled sets whether entries are sent with the ID of the
+// goroutine firing them, telling apart the interleaved entries of concurrent
+// workers. Reading the ID requires formatting the goroutine's stack header.
+func (hook *AppInsightsHook) SetGoroutineIDEnabled(enabled bool) {
+	hook.goroutineID = enabled
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the header
+// of its stack such as "goroutine 18 [running]:".
+func goroutineID() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		return string(buf[:i])
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package logrus_appinsights
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetGoroutineIDEnabled(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	entry := logrus.NewEntry(logrus.New())
+	assert.NotContains(hook.buildProperties(entry), GoroutineIDKey)
+
+	hook.SetGoroutineIDEnabled(true)
+	pipeline := hook.NewPipeline()
+	ids := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			ids <- pipeline.buildProperties(entry)[GoroutineIDKey]
+		}()
+	}
+	first, second := <-ids, <-ids
+	_, err := strconv.ParseUint(first, 10, 64)
+	assert.NoError(err)
+	assert.NotEqual(first, second)
+}
@@ -1,10 +1,17 @@
 package logrus_appinsights
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
 
-	"github.com/Microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
 	"github.com/sirupsen/logrus"
 )
 
@@ -16,29 +23,132 @@ var defaultLevels = []logrus.Level{
 	logrus.InfoLevel,
 }
 
-var levelMap = map[logrus.Level]appinsights.SeverityLevel{
+// reservedFields are interpreted by the hook and never sent as properties.
+var reservedFields = map[string]struct{}{
+	EventKey:     {},
+	TypeKey:      {},
+	ValueKey:     {},
+	RetentionKey: {},
+	ReceiptKey:   {},
+	SampleKey:    {},
+}
+
+// TimeBucketKey is the property holding the time bucket of an item.
+const TimeBucketKey = "time_bucket"
+
+// OtherValue replaces the values of a field that are not allowed.
+const OtherValue = "other"
+
+var levelMap = map[logrus.Level]contracts.SeverityLevel{
 	logrus.PanicLevel: appinsights.Critical,
 	logrus.FatalLevel: appinsights.Critical,
 	logrus.ErrorLevel: appinsights.Error,
 	logrus.WarnLevel:  appinsights.Warning,
 	logrus.InfoLevel:  appinsights.Information,
+	logrus.DebugLevel: appinsights.Verbose,
+	logrus.TraceLevel: appinsights.Verbose,
 }
 
 // AppInsightsHook is a logrus hook for Application Insights
 type AppInsightsHook struct {
-	client appinsights.TelemetryClient
+	client         appinsights.TelemetryClient
+	httpClient     *http.Client
+	canary         appinsights.TelemetryClient
+	canaryFraction float64
+	deviceTags     DeviceTagPolicy
+	stats          *deliveryStats
 
-	async        bool
+	settings     sync.RWMutex // guards levels, ignoreFields, filters and pipelines
 	levels       []logrus.Level
 	ignoreFields map[string]struct{}
 	filters      map[string]func(interface{}) interface{}
+	pending      pendingBudget
+	closeOnce    sync.Once
+	pipelines    map[logrus.Level]*AppInsightsHook
+
+	hookOptions
+}
+
+// hookOptions are the settings of a hook its pipelines start with a copy of.
+type hookOptions struct {
+	async             bool
+	typeFilters       map[reflect.Type]func(interface{}) interface{}
+	conditionals      []conditionalFilter
+	processors        []Processor
+	postSend          []PostSendHook
+	sampling          map[logrus.Level]float64
+	adaptive          *adaptiveSampler
+	batching          *adaptiveBatcher
+	mirror            *mirrorFile
+	sampleFloor       *logrus.Level
+	exemptFields      []exemptField
+	sampler           func(*logrus.Entry) bool
+	volume            *volumeBudget
+	coarseTimes       bool
+	allowed           map[string]map[string]struct{}
+	valueMappings     map[string]map[string]string
+	timeBucket        time.Duration
+	timeSource        TimeSource
+	retention         string
+	metrics           map[string]string
+	aggregates        *aggregator
+	operations        *operationBudget
+	exceptions        bool
+	events            bool
+	requests          bool
+	downgrades        []downgradeRule
+	levelMapping      map[logrus.Level]contracts.SeverityLevel
+	correlation       CorrelationExtractor
+	integrity         *integrity
+	normalize         bool
+	spans             SpanBridge
+	extractors        []func(context.Context) map[string]string
+	tagFields         map[string]string
+	baggageKeys       map[string]struct{}
+	contextValues     map[string]interface{}
+	providers         []func(*logrus.Entry) map[string]string
+	collisions        *collisions
+	collisionWarnings io.Writer
+	callerKeys        *CallerKeys
+	goroutineID       bool
+	flattening        flattening
+	jsonValues        bool
+	measureFields     map[string]struct{}
+	measureAll        bool
+	internFields      map[string]struct{}
+	interned          *internTable
+	durations         bool
+	durationText      bool
+	keySanitizer      func(string) string
+	keyCollisions     KeyCollisionStrategy
+	maxValueLen       int
+	maxMessageLen     int
+	gzipLevel         *int
+	preSerialize      bool
+	maxProperties     int
+	priorities        []string
+	renames           map[string]string
+	ignoreMatches     []func(string) bool
+	globalFilter      func(string, interface{}) (interface{}, bool)
+	pii               PII
+	secrets           bool
+	redactions        *redactions
+	auditReporter     chan struct{}
+	snapshots         *errorSnapshots
+	reporter          chan struct{}
+	statsServers      []*http.Server
+	deadline          time.Time
 }
 
 // New returns an initialised logrus hook for Application Insights
 func New(name string, conf Config) (*AppInsightsHook, error) {
-	if conf.InstrumentationKey == "" {
+	backend := conf.backend()
+	if conf.InstrumentationKey == "" && backend != OTLPBackend {
 		return nil, fmt.Errorf("InstrumentationKey is required and missing from configuration")
 	}
+	if conf.OTLPEndpoint == "" && backend == OTLPBackend {
+		return nil, fmt.Errorf("OTLPEndpoint is required by the OTLP backend")
+	}
 	telemetryConf := appinsights.NewTelemetryConfiguration(conf.InstrumentationKey)
 	if conf.MaxBatchSize != 0 {
 		telemetryConf.MaxBatchSize = conf.MaxBatchSize
@@ -49,16 +159,156 @@ func New(name string, conf Config) (*AppInsightsHook, error) {
 	if conf.EndpointUrl != "" {
 		telemetryConf.EndpointUrl = conf.EndpointUrl
 	}
-	telemetryClient := appinsights.NewTelemetryClientFromConfig(telemetryConf)
-	if name != "" {
-		telemetryClient.Context().Cloud().SetRoleName(name)
+	var transport http.RoundTripper
+	if conf.ClientCertFile != "" {
+		reloading, err := newReloadingTransport(conf.ClientCertFile, conf.ClientKeyFile, conf.CertReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		transport = reloading
 	}
-	return &AppInsightsHook{
-		client:       telemetryClient,
-		levels:       defaultLevels,
-		ignoreFields: make(map[string]struct{}),
-		filters:      make(map[string]func(interface{}) interface{}),
-	}, nil
+	if conf.AADTokenProvider != nil {
+		transport = newBearerTransport(transport, conf.AADTokenProvider)
+		telemetryConf.EndpointUrl = aadEndpoint(telemetryConf.EndpointUrl)
+	}
+	store, err := newOfflineStore(conf)
+	if err != nil {
+		return nil, err
+	}
+	stats := &deliveryStats{}
+	delivery := newDeliveryTransport(transport, stats)
+	if store != nil {
+		delivery.store = store
+		if err := delivery.resumeReplay(telemetryConf.EndpointUrl); err != nil {
+			return nil, err
+		}
+	}
+	delivery.limitInflight(conf.MaxConcurrentBatches)
+	if conf.IdempotencyKeys {
+		delivery.idempotency = newIdempotencyKeys(idempotencyWindow)
+	}
+	batches := newBatchBytes(telemetryConf.MaxBatchInterval + bufferedWindow)
+	delivery.batches = batches
+	telemetryConf.Client = &http.Client{Transport: delivery}
+	var secondaryConf *appinsights.TelemetryConfiguration
+	if conf.SecondaryConnectionString != "" {
+		iKey, endpointUrl, err := parseConnectionString(conf.SecondaryConnectionString)
+		if err != nil {
+			return nil, err
+		}
+		secondaryConf = appinsights.NewTelemetryConfiguration(iKey)
+		secondaryConf.EndpointUrl = endpointUrl
+		if conf.AADTokenProvider != nil {
+			secondaryConf.EndpointUrl = aadEndpoint(endpointUrl)
+		}
+		secondaryConf.MaxBatchSize = telemetryConf.MaxBatchSize
+		secondaryConf.MaxBatchInterval = telemetryConf.MaxBatchInterval
+		secondaryDelivery := newDeliveryTransport(transport, stats)
+		secondaryDelivery.limitInflight(conf.MaxConcurrentBatches)
+		secondaryDelivery.idempotency = delivery.idempotency
+		secondaryDelivery.chain = delivery.chain
+		secondaryDelivery.batches = delivery.batches
+		secondaryConf.Client = &http.Client{Transport: secondaryDelivery}
+	}
+	// newClient returns the client sending to the resource of primaryConf,
+	// failing over to the secondary resource if any.
+	newClient := func(primaryConf *appinsights.TelemetryConfiguration) appinsights.TelemetryClient {
+		client := appinsights.NewTelemetryClientFromConfig(primaryConf)
+		if name != "" {
+			client.Context().Tags.Cloud().SetRole(name)
+		}
+		if secondaryConf != nil {
+			secondary := appinsights.NewTelemetryClientFromConfig(secondaryConf)
+			if name != "" {
+				secondary.Context().Tags.Cloud().SetRole(name)
+			}
+			failover := newFailoverClient(client, secondary, conf.FailoverAfter, transport)
+			go failover.run(failoverProbeInterval)
+			client = failover
+		}
+		if conf.ChannelBufferSize > 0 {
+			client = newBufferedClient(client, conf.ChannelBufferSize, conf.PrioritizeErrors)
+		}
+		return client
+	}
+	telemetryClient := newRotatingClient(newClient(telemetryConf), func(iKey, endpointUrl string) appinsights.TelemetryClient {
+		rotatedConf := *telemetryConf
+		rotatedConf.InstrumentationKey = iKey
+		rotatedConf.EndpointUrl = endpointUrl
+		client := newClient(&rotatedConf)
+		for _, ctx := range clientContexts(client) {
+			conf.DeviceTags.apply(ctx)
+		}
+		return client
+	})
+	canary, err := newCanaryClient(name, conf)
+	if err != nil {
+		return nil, err
+	}
+	hook := &AppInsightsHook{
+		client:         telemetryClient,
+		httpClient:     telemetryConf.Client,
+		canary:         canary,
+		canaryFraction: conf.CanaryFraction,
+		deviceTags:     conf.DeviceTags,
+		stats:          stats,
+		levels:         defaultLevels,
+		ignoreFields:   make(map[string]struct{}),
+		filters:        make(map[string]func(interface{}) interface{}),
+	}
+	for _, ctx := range hook.contexts() {
+		hook.deviceTags.apply(ctx)
+	}
+	detectors := append(conf.ResourceDetectors[:len(conf.ResourceDetectors):len(conf.ResourceDetectors)], AppServiceDetector)
+	if err := hook.AddResourceDetectors(detectors...); err != nil {
+		return nil, err
+	}
+	if conf.CloudRole != "" {
+		hook.SetCloudRole(conf.CloudRole)
+	}
+	if conf.CloudRoleInstance != "" {
+		hook.SetCloudRoleInstance(conf.CloudRoleInstance)
+	}
+	for k, v := range conf.DefaultProperties {
+		hook.AddGlobalProperty(k, v)
+	}
+	hook.AddEnvironmentProperties(conf.EnvironmentProperties...)
+	if conf.SamplingRates != nil {
+		hook.SetSamplingRates(conf.SamplingRates)
+	}
+	hook.SetAdaptiveSampling(conf.MaxItemsPerSecond)
+	hook.SetVolumeBudget(conf.MaxItemsPerHour, conf.MaxBytesPerHour)
+	hook.pending.priority = conf.PrioritizeErrors
+	batches.watch(&hook.pending)
+	hook.SetMaxPendingBytes(conf.MaxPendingBytes)
+	hook.SetOverflowPolicy(conf.OverflowPolicy)
+	if conf.MinBatchInterval > 0 {
+		hook.batching = newAdaptiveBatcher(conf.MinBatchSize, telemetryConf.MaxBatchSize, conf.MinBatchInterval, telemetryConf.MaxBatchInterval)
+		go hook.batching.run(func() { hook.client.Channel().Flush() })
+	}
+	if conf.OTLPEndpoint != "" {
+		mode := OTLPAlongside
+		if backend == OTLPBackend {
+			mode = OTLPOnly
+		}
+		if err := hook.SetOTLPExport(conf.OTLPEndpoint, mode, conf.OTLPHeaders); err != nil {
+			return nil, err
+		}
+	}
+	if conf.DryRun != nil {
+		hook.SetDryRun(conf.DryRun)
+	}
+	if conf.MirrorFile != "" {
+		if err := hook.SetMirrorFile(conf.MirrorFile); err != nil {
+			return nil, err
+		}
+	}
+	if conf.StatsAddress != "" {
+		if _, err := hook.ServeStats(conf.StatsAddress); err != nil {
+			return nil, err
+		}
+	}
+	return hook, nil
 }
 
 // NewWithAppInsightsConfig returns an initialised logrus hook for Application Insights
@@ -69,26 +319,57 @@ func NewWithAppInsightsConfig(name string, conf *appinsights.TelemetryConfigurat
 	if conf.InstrumentationKey == "" {
 		return nil, fmt.Errorf("InstrumentationKey is required in configuration")
 	}
-	telemetryClient := appinsights.NewTelemetryClientFromConfig(conf)
+	stats := &deliveryStats{}
+	var transport http.RoundTripper
+	if conf.Client != nil {
+		transport = conf.Client.Transport
+	}
+	observed := *conf
+	delivery := newDeliveryTransport(transport, stats)
+	delivery.batches = newBatchBytes(conf.MaxBatchInterval + bufferedWindow)
+	observed.Client = &http.Client{Transport: delivery}
+	if conf.Client != nil {
+		observed.Client.Timeout = conf.Client.Timeout
+		observed.Client.Jar = conf.Client.Jar
+		observed.Client.CheckRedirect = conf.Client.CheckRedirect
+	}
+	telemetryClient := appinsights.NewTelemetryClientFromConfig(&observed)
 	if name != "" {
-		telemetryClient.Context().Cloud().SetRoleName(name)
+		telemetryClient.Context().Tags.Cloud().SetRole(name)
 	}
-	return &AppInsightsHook{
-		client:       telemetryClient,
+	hook := &AppInsightsHook{
+		client: newRotatingClient(telemetryClient, func(iKey, endpointUrl string) appinsights.TelemetryClient {
+			rotatedConf := observed
+			rotatedConf.InstrumentationKey = iKey
+			rotatedConf.EndpointUrl = endpointUrl
+			return appinsights.NewTelemetryClientFromConfig(&rotatedConf)
+		}),
+		httpClient:   observed.Client,
+		stats:        stats,
 		levels:       defaultLevels,
 		ignoreFields: make(map[string]struct{}),
 		filters:      make(map[string]func(interface{}) interface{}),
-	}, nil
+	}
+	delivery.batches.watch(&hook.pending)
+	return hook, nil
 }
 
 // Levels returns logging level to fire this hook.
 func (hook *AppInsightsHook) Levels() []logrus.Level {
+	hook.settings.RLock()
+	defer hook.settings.RUnlock()
+	if hook.snapshots != nil && !containsLevel(hook.levels, logrus.DebugLevel) {
+		return append(append([]logrus.Level{}, hook.levels...), logrus.DebugLevel)
+	}
 	return hook.levels
 }
 
-// SetLevels sets logging level to fire this hook.
+// SetLevels sets logging level to fire this hook. It is safe to call while
+// the hook is firing.
 func (hook *AppInsightsHook) SetLevels(levels []logrus.Level) {
+	hook.settings.Lock()
 	hook.levels = levels
+	hook.settings.Unlock()
 }
 
 // SetAsync sets async flag for sending logs asynchronously.
@@ -97,61 +378,564 @@ func (hook *AppInsightsHook) SetAsync(async bool) {
 	hook.async = async
 }
 
-// AddIgnore adds field name to ignore.
+// SetExceptionsEnabled sets whether Error, Fatal and Panic entries carrying an
+// error under logrus.ErrorKey are sent as exception telemetry instead of
+// traces, so they are grouped in the Failures blade.
+func (hook *AppInsightsHook) SetExceptionsEnabled(enabled bool) {
+	hook.exceptions = enabled
+}
+
+// SetEventsEnabled sets whether entries are sent as event telemetry named
+// after their message instead of traces. Individual entries can be sent as
+// events by setting the EventKey field.
+func (hook *AppInsightsHook) SetEventsEnabled(enabled bool) {
+	hook.events = enabled
+}
+
+// SetMaxPendingBytes sets the approximate number of bytes that traces fired
+// asynchronously may hold until delivered: before they are handed over to the
+// client, then while it batches, submits and retries them. Entries exceeding
+// the budget are handled according to the overflow policy. A value of zero or
+// less disables the budget.
+func (hook *AppInsightsHook) SetMaxPendingBytes(n int64) {
+	hook.pending.mu.Lock()
+	hook.pending.max = n
+	hook.pending.mu.Unlock()
+	if n > 0 {
+		hook.pending.batches.track()
+	}
+}
+
+// SetOverflowPolicy sets how entries exceeding the pending bytes budget are handled.
+func (hook *AppInsightsHook) SetOverflowPolicy(policy OverflowPolicy) {
+	hook.pending.mu.Lock()
+	hook.pending.policy = policy
+	hook.pending.mu.Unlock()
+}
+
+// SetTimeBucket sets the hook to stamp items with the TimeBucketKey
+// property, the start of the size long time slice the entry was logged in.
+// Grouping by a precomputed bucket makes high-volume aggregations cheaper.
+// A size of zero disables the property.
+func (hook *AppInsightsHook) SetTimeBucket(size time.Duration) {
+	hook.timeBucket = size
+}
+
+// AddIgnore adds field name to ignore. It is safe to call while the hook is
+// firing.
 func (hook *AppInsightsHook) AddIgnore(name string) {
+	hook.settings.Lock()
 	hook.ignoreFields[name] = struct{}{}
+	hook.settings.Unlock()
 }
 
-// AddFilter adds a custom filter function.
+// AddFilter adds a custom filter function. It is safe to call while the hook
+// is firing.
 func (hook *AppInsightsHook) AddFilter(name string, fn func(interface{}) interface{}) {
+	hook.redactionCounts()
+	hook.settings.Lock()
 	hook.filters[name] = fn
+	hook.settings.Unlock()
+}
+
+// SetGlobalFilter sets a filter applied to every field, after the filter of
+// the field if any, e.g. to redact or normalise values whatever their field.
+// The field is dropped if fn returns false.
+func (hook *AppInsightsHook) SetGlobalFilter(fn func(key string, v interface{}) (interface{}, bool)) {
+	hook.redactionCounts()
+	hook.globalFilter = fn
+}
+
+// AddAllowedValues constrains field name to the given values, any other value
+// is sent as OtherValue. This keeps the cardinality of dimensions used in
+// alert and autoscale rules bounded.
+func (hook *AppInsightsHook) AddAllowedValues(name string, values ...string) {
+	if hook.allowed == nil {
+		hook.allowed = make(map[string]map[string]struct{})
+	}
+	if hook.allowed[name] == nil {
+		hook.allowed[name] = make(map[string]struct{}, len(values))
+	}
+	for _, v := range values {
+		hook.allowed[name][v] = struct{}{}
+	}
+}
+
+// NewPipeline returns a pipeline that processes entries of the given levels in
+// place of the hook, e.g. to deliver errors synchronously while everything else
+// is sent asynchronously. The pipeline shares the hook's client and starts with
+// a copy of its settings, which can then be changed independently.
+func (hook *AppInsightsHook) NewPipeline(levels ...logrus.Level) *AppInsightsHook {
+	pipeline := &AppInsightsHook{
+		client:         hook.client,
+		httpClient:     hook.httpClient,
+		canary:         hook.canary,
+		canaryFraction: hook.canaryFraction,
+		deviceTags:     hook.deviceTags,
+		stats:          hook.stats,
+		levels:         levels,
+		ignoreFields:   make(map[string]struct{}, len(hook.ignoreFields)),
+		filters:        make(map[string]func(interface{}) interface{}, len(hook.filters)),
+		hookOptions:    hook.hookOptions,
+	}
+	// the hook sends the reports
+	pipeline.reporter, pipeline.auditReporter, pipeline.statsServers = nil, nil, nil
+	// appended to without growing the hook's
+	pipeline.conditionals = hook.conditionals[:len(hook.conditionals):len(hook.conditionals)]
+	pipeline.processors = hook.processors[:len(hook.processors):len(hook.processors)]
+	pipeline.postSend = hook.postSend[:len(hook.postSend):len(hook.postSend)]
+	pipeline.exemptFields = hook.exemptFields[:len(hook.exemptFields):len(hook.exemptFields)]
+	pipeline.downgrades = hook.downgrades[:len(hook.downgrades):len(hook.downgrades)]
+	pipeline.extractors = hook.extractors[:len(hook.extractors):len(hook.extractors)]
+	pipeline.providers = hook.providers[:len(hook.providers):len(hook.providers)]
+	pipeline.priorities = hook.priorities[:len(hook.priorities):len(hook.priorities)]
+	pipeline.ignoreMatches = hook.ignoreMatches[:len(hook.ignoreMatches):len(hook.ignoreMatches)]
+	// filled below, changed without changing the hook's
+	pipeline.typeFilters, pipeline.allowed, pipeline.renames, pipeline.sampling = nil, nil, nil, nil
+	pipeline.measureFields, pipeline.internFields, pipeline.valueMappings, pipeline.metrics = nil, nil, nil, nil
+	pipeline.levelMapping, pipeline.tagFields, pipeline.baggageKeys, pipeline.contextValues = nil, nil, nil, nil
+
+	hook.settings.RLock()
+	for k := range hook.ignoreFields {
+		pipeline.ignoreFields[k] = struct{}{}
+	}
+	for k, fn := range hook.filters {
+		pipeline.filters[k] = fn
+	}
+	hook.settings.RUnlock()
+	for t, fn := range hook.typeFilters {
+		pipeline.AddTypeFilter(t, fn)
+	}
+	for name, values := range hook.allowed {
+		for v := range values {
+			pipeline.AddAllowedValues(name, v)
+		}
+	}
+	for from, to := range hook.renames {
+		pipeline.RenameField(from, to)
+	}
+	if hook.sampling != nil {
+		pipeline.SetSamplingRates(hook.sampling)
+	}
+	for name := range hook.measureFields {
+		pipeline.AddMeasurementFields(name)
+	}
+	for name := range hook.internFields {
+		pipeline.AddInternedFields(name)
+	}
+	pipeline.interned = hook.interned // shared with the hook
+	for name, mapping := range hook.valueMappings {
+		pipeline.AddValueMapping(name, mapping)
+	}
+	for field, name := range hook.metrics {
+		pipeline.AddMetricMapping(field, name)
+	}
+	if hook.levelMapping != nil {
+		pipeline.SetLevelMapping(hook.levelMapping)
+	}
+	for field, tag := range hook.tagFields {
+		pipeline.MapFieldToTag(field, tag)
+	}
+	for key := range hook.baggageKeys {
+		pipeline.AddBaggageKeys(key)
+	}
+	for property, key := range hook.contextValues {
+		pipeline.AddContextValue(property, key)
+	}
+	hook.pending.mu.Lock()
+	pipeline.pending.max = hook.pending.max
+	pipeline.pending.policy = hook.pending.policy
+	pipeline.pending.priority = hook.pending.priority
+	hook.pending.mu.Unlock()
+	if hook.pending.batches != nil {
+		hook.pending.batches.watch(&pipeline.pending)
+	}
+
+	hook.settings.Lock()
+	if hook.pipelines == nil {
+		hook.pipelines = make(map[logrus.Level]*AppInsightsHook)
+	}
+	merged := append([]logrus.Level{}, hook.levels...)
+	for _, level := range levels {
+		hook.pipelines[level] = pipeline
+		if !containsLevel(merged, level) {
+			merged = append(merged, level)
+		}
+	}
+	hook.levels = merged
+	hook.settings.Unlock()
+	return pipeline
+}
+
+// pipeline returns the pipeline responsible for level, or the hook itself.
+func (hook *AppInsightsHook) pipeline(level logrus.Level) *AppInsightsHook {
+	hook.settings.RLock()
+	p, ok := hook.pipelines[level]
+	hook.settings.RUnlock()
+	if ok {
+		return p
+	}
+	return hook
 }
 
 // Fire is invoked by logrus and sends log data to Application Insights.
 func (hook *AppInsightsHook) Fire(entry *logrus.Entry) error {
+	if hook.snapshots != nil && entry.Level == logrus.DebugLevel {
+		hook.pipeline(entry.Level).recordDebug(entry)
+		hook.settings.RLock()
+		debug := containsLevel(hook.levels, logrus.DebugLevel)
+		hook.settings.RUnlock()
+		if !debug {
+			return nil // only fired for the snapshot
+		}
+	}
+	return hook.pipeline(entry.Level).send(entry)
+}
+
+// send sends entry according to the hook's settings.
+func (hook *AppInsightsHook) send(entry *logrus.Entry) error {
+	if hook.aggregates != nil {
+		hook.aggregates.observe(entry)
+	}
+	receipt := receiptOf(entry)
+	if !hook.sampled(entry) {
+		receipt.settle(Dropped, nil) // sampled out
+		return nil
+	}
+	if !hook.withinVolume(entry) {
+		receipt.settle(Dropped, nil) // over the volume budget
+		return nil
+	}
+	if sent, err := hook.sendWithinBudget(entry); sent {
+		return receipt.settleSent(err)
+	}
 	if !hook.async {
-		return hook.fire(entry)
+		return receipt.settleSent(hook.fire(entry))
+	}
+	var size int64
+	if hook.pending.enabled() {
+		size = estimateSize(entry)
+		if !hook.pending.acquire(size, entry.Level <= logrus.ErrorLevel) {
+			receipt.settle(Dropped, nil) // dropped by the overflow policy
+			return nil
+		}
+	}
+	// build on the caller's goroutine, logrus may reuse the entry once Fire
+	// returns and the callstack of exceptions must be the caller's
+	items, err := hook.buildItems(entry)
+	if err != nil {
+		hook.pending.release(size)
+		receipt.settle(Dropped, err)
+		return nil
 	}
 	// async - fire and forget
-	go hook.fire(entry)
+	go func() {
+		hook.track(items...)
+		hook.pending.handOff(size, len(items))
+		receipt.settle(Sent, nil)
+	}()
 	return nil
 }
 
+// FireAndWait sends entry straight to the ingestion endpoint, bypassing the
+// client's batching, and returns once Application Insights has accepted it.
+// It is meant for the few entries that must be confirmed as delivered, such as
+// audit records, and ignores the levels the hook is registered for.
+func (hook *AppInsightsHook) FireAndWait(entry *logrus.Entry) error {
+	receipt := receiptOf(entry)
+	items, err := hook.buildItems(entry)
+	if err != nil {
+		receipt.settle(Dropped, err)
+		return err
+	}
+	return receipt.settleSent(hook.transmit(hook.httpClient, items))
+}
+
+// transmit sends items straight to the ingestion endpoint with client.
+func (hook *AppInsightsHook) transmit(client *http.Client, items []appinsights.Telemetry) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if hook.dryRun() || hook.otlpOnly() {
+		hook.track(items...)
+		return nil
+	}
+	envelopes := make([]*contracts.Envelope, len(items))
+	for i, item := range items {
+		envelopes[i] = envelop(hook.client.Context(), item)
+	}
+	err := transmit(client, hook.client.Channel().EndpointAddress(), envelopes, hook.encoding())
+	hook.mirror.write(envelopes)
+	hook.exportOTLP(items)
+	hook.submitted(items, err)
+	return err
+}
+
+// clients returns the client of the hook followed by the clients it wraps,
+// outermost first.
+func (hook *AppInsightsHook) clients() []appinsights.TelemetryClient {
+	return unwrapClients(hook.client)
+}
+
+// unwrapClients returns client followed by the clients it wraps, outermost
+// first.
+func unwrapClients(client appinsights.TelemetryClient) []appinsights.TelemetryClient {
+	var clients []appinsights.TelemetryClient
+	for client != nil {
+		clients = append(clients, client)
+		switch c := client.(type) {
+		case *otlpClient:
+			client = c.TelemetryClient
+		case *dryRunClient:
+			client = c.TelemetryClient
+		case *rotatingClient:
+			client = c.active()
+		case *bufferedClient:
+			client = c.TelemetryClient
+		default:
+			client = nil
+		}
+	}
+	return clients
+}
+
+// stopClient stops the goroutines of client and of the clients it wraps, once
+// their telemetry is handed over to their channels.
+func stopClient(client appinsights.TelemetryClient) {
+	for _, client := range unwrapClients(client) {
+		switch c := client.(type) {
+		case *bufferedClient:
+			c.stop()
+		case *failoverClient:
+			c.stop()
+		}
+	}
+}
+
 func (hook *AppInsightsHook) fire(entry *logrus.Entry) error {
-	trace, err := hook.buildTrace(entry)
+	items, err := hook.buildItems(entry)
 	if err != nil {
 		return err
 	}
-	hook.client.TrackTraceTelemetry(trace)
+	hook.track(items...)
 	return nil
 }
 
+// buildItems returns the telemetry item for entry followed by the request
+// and metrics derived from its fields.
+func (hook *AppInsightsHook) buildItems(entry *logrus.Entry) ([]appinsights.Telemetry, error) {
+	entry = hook.scrubbedEntry(hook.conditionalEntry(hook.renamedEntry(hook.normalizedEntry(hook.truncatedEntry(entry)))))
+	item, err := hook.buildItem(entry)
+	if err != nil {
+		return nil, err
+	}
+	hook.addMeasurements(entry, item)
+	hook.addDurations(entry, item)
+	hook.correlate(entry, item)
+	hook.addSpanEvent(entry, item)
+	items := []appinsights.Telemetry{item}
+	if hook.requests && isRequestEntry(entry) {
+		items = append(items, hook.buildRequest(entry, item))
+	}
+	items = append(items, hook.buildMetrics(entry)...)
+	for _, item := range items {
+		hook.scrubItem(item)
+	}
+	hook.addFieldTags(entry, items...)
+	hook.addContextTags(entry, items...)
+	items = hook.process(entry, items)
+	if len(items) == 0 || items[0] != item {
+		receiptOf(entry).settle(Discarded, nil)
+	} else if hook.operations != nil && !hook.exempt(entry) && !hook.operations.allow(item, entry.Level) {
+		items = items[1:] // only the entry itself counts against the budget
+		receiptOf(entry).settle(Suppressed, nil)
+	}
+	if hook.integrity != nil {
+		for _, item := range items {
+			// sign the common properties the client adds as well
+			if props := item.GetProperties(); props != nil && hook.client != nil {
+				for k, v := range hook.client.Context().CommonProperties {
+					if _, ok := props[k]; !ok {
+						props[k] = v
+					}
+				}
+			}
+			hook.integrity.sign(item)
+		}
+	}
+	return items, nil
+}
+
+// buildItem returns the telemetry item to send for entry.
+func (hook *AppInsightsHook) buildItem(entry *logrus.Entry) (appinsights.Telemetry, error) {
+	if item, ok, err := hook.buildOverride(entry); ok {
+		return item, err
+	}
+	if isDBEntry(entry) {
+		return hook.buildDBDependency(entry), nil
+	}
+	if isDependencyEntry(entry) {
+		return hook.buildDependency(entry), nil
+	}
+	if isPageViewEntry(entry) {
+		return hook.buildPageView(entry), nil
+	}
+	if err, ok := entryError(entry); ok && hook.exceptions {
+		return hook.buildException(entry, err), nil
+	}
+	if hook.events || isEventEntry(entry) {
+		return hook.buildEvent(entry), nil
+	}
+	return hook.buildTrace(entry)
+}
+
 func (hook *AppInsightsHook) buildTrace(entry *logrus.Entry) (*appinsights.TraceTelemetry, error) {
-	// Add the message as a field if it isn't already
-	if _, ok := entry.Data["message"]; !ok {
-		entry.Data["message"] = entry.Message
+	// built in place rather than with NewTraceTelemetry, whose properties
+	// would be replaced straight away
+	trace := &appinsights.TraceTelemetry{
+		Message:       entry.Message,
+		SeverityLevel: hook.severity(entry),
+		BaseTelemetry: appinsights.BaseTelemetry{
+			Timestamp:  hook.entryTime(entry),
+			Tags:       make(contracts.ContextTags),
+			Properties: hook.buildProperties(entry),
+		},
 	}
+	return trace, nil
+}
+
+// buildProperties returns the trace properties for the entry fields.
+func (hook *AppInsightsHook) buildProperties(entry *logrus.Entry) map[string]string {
+	props := hook.entryProperties(entry)
+	hook.finishProperties(props)
+	return props
+}
 
-	level := levelMap[entry.Level]
-	trace := appinsights.NewTraceTelemetry(entry.Message, level)
-	if trace == nil {
-		return nil, fmt.Errorf("Could not create telemetry trace with entry %+v", entry)
+// entryProperties returns the properties for the entry fields, yet to be
+// finished.
+func (hook *AppInsightsHook) entryProperties(entry *logrus.Entry) map[string]string {
+	props := make(map[string]string, len(entry.Data)+3)
+	plain := hook.plainStrings()
+	hook.addFields(props, entry.Data, plain)
+	hook.addContextProperties(props, entry)
+	hook.addBaggageProperties(props, entry)
+	hook.addProvidedProperties(props, entry)
+	// Add the message as a property without modifying the entry, as its
+	// fields may be shared with other goroutines
+	if plain {
+		if hook.sendsField("message") {
+			hook.addHookProperty(props, "message", entry.Message)
+		}
+	} else if message, ok := hook.propertyValue("message", entry.Message); ok {
+		hook.addHookProperty(props, "message", message)
 	}
-	for k, v := range entry.Data {
-		if _, ok := hook.ignoreFields[k]; ok {
+	if hook.snapshots != nil && hook.snapshots.first(entry) {
+		for k, v := range hook.snapshots.properties(entry.Data[OperationIDKey]) {
+			hook.addHookProperty(props, k, v)
+		}
+	}
+	if retention := hook.entryRetention(entry); retention != "" {
+		props[RetentionKey] = retention
+	}
+	if hook.timeBucket > 0 {
+		hook.addHookProperty(props, TimeBucketKey, hook.entryTime(entry).UTC().Truncate(hook.timeBucket).Format(time.RFC3339))
+	}
+	hook.addCallerProperties(props, entry)
+	if hook.goroutineID {
+		hook.addHookProperty(props, GoroutineIDKey, goroutineID())
+	}
+	hook.addSampleRate(props, entry)
+	hook.addHookProperty(props, "source_level", levelName(entry.Level))
+	hook.addHookProperty(props, "source_timestamp", hook.sourceTimestamp(entry.Time))
+	return props
+}
+
+// addFields adds the fields to props, as they are if plain strings are sent.
+func (hook *AppInsightsHook) addFields(props map[string]string, fields logrus.Fields, plain bool) {
+	for k, v := range fields {
+		if s, ok := v.(string); ok && plain {
+			// sent as is, without boxing or formatting
+			if hook.sendsField(k) {
+				if hook.isInterned(k) {
+					s = hook.interned.string(s)
+				}
+				props[k] = s
+			}
 			continue
 		}
-		if fn, ok := hook.filters[k]; ok {
-			v = fn(v) // apply custom filter
-		} else {
-			v = formatData(v) // use default formatter
+		hook.addField(props, k, v)
+	}
+}
+
+// finishProperties scrubs props and bounds their keys, count and values.
+func (hook *AppInsightsHook) finishProperties(props map[string]string) {
+	hook.scrubProperties(props)
+	hook.sanitizeKeys(props)
+	hook.capProperties(props)
+	hook.truncateValues(props)
+}
+
+// addProperty formats the field k and adds it to props unless ignored.
+func (hook *AppInsightsHook) addProperty(props map[string]string, k string, v interface{}) {
+	if s, ok := hook.propertyValue(k, v); ok {
+		props[k] = s
+	}
+}
+
+// plainStrings reports whether string fields are sent as they are, with no
+// filter, mapping or allowed values to apply.
+func (hook *AppInsightsHook) plainStrings() bool {
+	hook.settings.RLock()
+	filters := len(hook.filters)
+	hook.settings.RUnlock()
+	return filters == 0 && len(hook.typeFilters) == 0 && hook.globalFilter == nil &&
+		len(hook.valueMappings) == 0 && len(hook.allowed) == 0
+}
+
+// sendsField reports whether the field k is sent as a property, unless
+// dropped by a filter.
+func (hook *AppInsightsHook) sendsField(k string) bool {
+	if hook.ignored(k) {
+		return false
+	}
+	if _, ok := reservedFields[k]; ok {
+		return false
+	}
+	_, ok := hook.tagFields[k]
+	return !ok
+}
+
+// propertyValue returns the field k formatted as a property, or false if it
+// is not sent as one.
+func (hook *AppInsightsHook) propertyValue(k string, v interface{}) (string, bool) {
+	if !hook.sendsField(k) {
+		return "", false
+	}
+	if fn, t, ok := hook.filterOf(k, v); ok {
+		filtered := fn(v) // apply custom filter
+		if hook.redactions != nil {
+			hook.redactions.changed(filterRule(k, t), v, filtered)
 		}
-		vStr := fmt.Sprintf("%v", v)
-		trace.SetProperty(k, vStr)
+		v = filtered
+	} else {
+		v = formatData(v) // use default formatter
 	}
-	trace.SetProperty("source_level", entry.Level.String())
-	trace.SetProperty("source_timestamp", entry.Time.String())
-	return trace, nil
+	if hook.globalFilter != nil {
+		filtered, keep := hook.globalFilter(k, v)
+		if !keep {
+			hook.redactions.add("global_filter")
+			return "", false
+		}
+		hook.redactions.changed("global_filter", v, filtered)
+		v = filtered
+	}
+	s := hook.mapValue(k, hook.formatInterned(k, v))
+	if allowed, ok := hook.allowed[k]; ok {
+		if _, ok := allowed[s]; !ok {
+			s = OtherValue
+		}
+	}
+	return s, true
 }
 
 // formatData returns value as a suitable format.
@@ -168,6 +952,23 @@ func formatData(value interface{}) (formatted interface{}) {
 	}
 }
 
+// entryTime returns the timestamp of the items built from entry.
+func (hook *AppInsightsHook) entryTime(entry *logrus.Entry) time.Time {
+	if hook.timeSource != nil {
+		return hook.timeSource(entry)
+	}
+	return EntryTime(entry)
+}
+
+func containsLevel(levels []logrus.Level, level logrus.Level) bool {
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
 func stringPtr(str string) *string {
 	return &str
 }
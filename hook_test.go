@@ -6,14 +6,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
@@ -127,6 +130,139 @@ func TestAddFilter(t *testing.T) {
 	}
 }
 
+func TestSettingsWhileFiring(t *testing.T) {
+	assert := assert.New(t)
+
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty", DryRun: ioutil.Discard})
+	assert.NoError(err)
+	defer hook.Close()
+	hook.SetFirstErrorSnapshot(10)
+
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"user": "jane", "token": "secret"})
+	entry.Message = "message"
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				entry := entry.WithField("level", j)
+				entry.Level = logrus.Level(j % 6)
+				assert.NoError(hook.Fire(entry))
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		hook.AddIgnore(fmt.Sprint("ignored", i))
+		hook.AddFilter(fmt.Sprint("filtered", i), func(v interface{}) interface{} { return v })
+		hook.SetLevels(logrus.AllLevels[:i%len(logrus.AllLevels)])
+		hook.Levels()
+	}
+	hook.AddIgnore("token")
+	hook.AddFilter("user", func(interface{}) interface{} { return "[user]" })
+	wg.Wait()
+
+	props := hook.buildProperties(entry)
+	assert.NotContains(props, "token")
+	assert.Equal("[user]", props["user"])
+}
+
+func TestSetGlobalFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields   logrus.Fields
+		expected map[string]string
+		absent   []string
+	}{
+		{logrus.Fields{"user": "Jane"}, map[string]string{"user": "jane", "message": "signed in"}, nil},
+		{logrus.Fields{"user": "Jane", "debug": true}, map[string]string{"user": "jane"}, []string{"debug"}},
+		{logrus.Fields{"card": "4111"}, map[string]string{"card": "****"}, nil},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{filters: make(map[string]func(interface{}) interface{})}
+		hook.AddFilter("card", func(v interface{}) interface{} { return "****" })
+		hook.SetGlobalFilter(func(key string, v interface{}) (interface{}, bool) {
+			if key == "debug" {
+				return nil, false
+			}
+			if s, ok := v.(string); ok {
+				return strings.ToLower(s), true
+			}
+			return v, true
+		})
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+		entry.Message = "Signed in"
+
+		props := hook.NewPipeline().buildProperties(entry)
+		for k, v := range tt.expected {
+			assert.Equal(v, props[k], target)
+		}
+		for _, k := range tt.absent {
+			assert.NotContains(props, k, target)
+		}
+	}
+}
+
+func TestAddAllowedValues(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"westeurope", "westeurope"},
+		{"eastus", "eastus"},
+		{"centralus", OtherValue},
+		{42, OtherValue},
+	}
+
+	hook := AppInsightsHook{}
+	hook.AddAllowedValues("region", "westeurope")
+	hook.AddAllowedValues("region", "eastus")
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"region": tt.value, "tag": "fieldTag"})
+		props := hook.buildProperties(entry)
+		assert.Equal(tt.expected, props["region"], target)
+		assert.Equal("fieldTag", props["tag"], target)
+	}
+}
+
+func TestSetTimeBucket(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		size     time.Duration
+		time     time.Time
+		expected string
+	}{
+		{0, time.Date(2018, 5, 1, 10, 7, 30, 0, time.UTC), ""},
+		{5 * time.Minute, time.Date(2018, 5, 1, 10, 7, 30, 0, time.UTC), "2018-05-01T10:05:00Z"},
+		{time.Hour, time.Date(2018, 5, 1, 12, 7, 30, 0, time.FixedZone("CEST", 2*60*60)), "2018-05-01T10:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetTimeBucket(tt.size)
+		entry := logrus.NewEntry(logrus.New())
+		entry.Time = tt.time
+		props := hook.buildProperties(entry)
+		if tt.expected == "" {
+			assert.NotContains(props, TimeBucketKey, target)
+		} else {
+			assert.Equal(tt.expected, props[TimeBucketKey], target)
+		}
+	}
+}
+
 func TestFormatData(t *testing.T) {
 	assert := assert.New(t)
 
@@ -211,7 +347,7 @@ func TestFire(t *testing.T) {
 		MaxBatchInterval:   time.Millisecond * 10,
 	})
 	if err != nil || hook == nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 	logrus.AddHook(hook)
 
@@ -269,7 +405,7 @@ func TestHandler(t *testing.T) {
 	gzipWriter := gzip.NewWriter(&postBody)
 	if _, err := gzipWriter.Write([]byte(payload)); err != nil {
 		gzipWriter.Close()
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 
 	gzipWriter.Close()
@@ -277,7 +413,7 @@ func TestHandler(t *testing.T) {
 	reader := bytes.NewReader(postBody.Bytes())
 	req, err := http.NewRequest("POST", "", reader)
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 
 	context := RequestContext{
@@ -429,3 +565,158 @@ func (msg jsonMessage) getPath(path string) (interface{}, error) {
 
 	return obj, nil
 }
+
+func TestNewPipeline(t *testing.T) {
+	assert := assert.New(t)
+
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty"})
+	assert.NoError(err)
+	hook.SetLevels([]logrus.Level{logrus.ErrorLevel, logrus.InfoLevel})
+	hook.SetAsync(true)
+	hook.AddIgnore("private")
+
+	errors := hook.NewPipeline(logrus.ErrorLevel, logrus.PanicLevel)
+	errors.SetAsync(false)
+	errors.AddIgnore("verbose")
+
+	assert.Equal([]logrus.Level{logrus.ErrorLevel, logrus.InfoLevel, logrus.PanicLevel}, hook.Levels())
+	assert.Equal([]logrus.Level{logrus.ErrorLevel, logrus.PanicLevel}, errors.Levels())
+
+	assert.True(hook.pipeline(logrus.ErrorLevel) == errors)
+	assert.True(hook.pipeline(logrus.PanicLevel) == errors)
+	assert.True(hook.pipeline(logrus.InfoLevel) == hook)
+
+	// settings are copied, not shared
+	assert.True(hook.async)
+	assert.False(errors.async)
+	assert.Contains(errors.ignoreFields, "private")
+	assert.Contains(errors.ignoreFields, "verbose")
+	assert.NotContains(hook.ignoreFields, "verbose")
+
+	// appending to the pipeline's processors leaves the hook's unchanged
+	keep := func(appinsights.Telemetry, *logrus.Entry) bool { return true }
+	drop := func(appinsights.Telemetry, *logrus.Entry) bool { return false }
+	hook.processors = make([]Processor, 0, 4)
+	hook.Use(keep)
+	warnings := hook.NewPipeline(logrus.WarnLevel)
+	warnings.Use(drop)
+	hook.Use(keep)
+	assert.True(hook.processors[1](nil, nil))
+	assert.False(warnings.processors[1](nil, nil))
+	// the hook sends the reports
+	assert.Nil(warnings.reporter)
+}
+
+func TestNewPipelineWhileFiring(t *testing.T) {
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty", DryRun: ioutil.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			entry := logrus.NewEntry(logrus.New())
+			entry.Level = logrus.ErrorLevel
+			hook.Fire(entry)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		hook.NewPipeline(logrus.ErrorLevel)
+	}
+	<-done
+}
+
+func TestBuildPropertiesLeavesEntryUnchanged(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	entry := logrus.NewEntry(logrus.New()).WithField("tag", "fieldTag")
+	entry.Message = "my message"
+
+	props := hook.buildProperties(entry)
+	assert.Equal("my message", props["message"])
+	assert.Equal(logrus.Fields{"tag": "fieldTag"}, entry.Data)
+}
+
+func BenchmarkBuildItems(b *testing.B) {
+	hook := AppInsightsHook{
+		filters: map[string]func(interface{}) interface{}{
+			"tag": func(v interface{}) interface{} { return v },
+		},
+	}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"tag": "fieldTag", "count": 42})
+	entry.Level = logrus.ErrorLevel
+	entry.Message = "my message"
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := hook.buildItems(entry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBuildProperties(b *testing.B) {
+	hook := AppInsightsHook{}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"user":     "jane",
+		"status":   200,
+		"duration": 12.5,
+		"cached":   true,
+		"bytes":    int64(2048),
+	})
+	entry.Message = "request completed"
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			hook.buildProperties(entry)
+		}
+	})
+}
+
+func BenchmarkBuildPropertiesStrings(b *testing.B) {
+	hook := AppInsightsHook{}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"user":    "jane",
+		"method":  "GET",
+		"path":    "/orders",
+		"region":  "westeurope",
+		"release": "1.2.3",
+	})
+	entry.Message = "request completed"
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			hook.buildProperties(entry)
+		}
+	})
+}
+
+func TestStringFastPath(t *testing.T) {
+	assert := assert.New(t)
+
+	fields := logrus.Fields{"user": "jane", "ignored": "x", EventKey: "name", "op": "checkout", "count": 3, "\x00bad": "v"}
+	entry := logrus.NewEntry(logrus.New()).WithFields(fields)
+	entry.Message = "done"
+
+	configure := func(hook *AppInsightsHook) {
+		hook.ignoreFields = map[string]struct{}{"ignored": {}}
+		hook.MapFieldToTag("op", "ai.operation.name")
+	}
+	plain := AppInsightsHook{}
+	configure(&plain)
+	assert.True(plain.plainStrings())
+	filtered := AppInsightsHook{}
+	configure(&filtered)
+	filtered.SetGlobalFilter(func(k string, v interface{}) (interface{}, bool) { return v, true })
+	assert.False(filtered.plainStrings())
+
+	assert.Equal(filtered.buildProperties(entry), plain.buildProperties(entry))
+}
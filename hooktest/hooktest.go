@@ -0,0 +1,259 @@
+// Package hooktest provides a contract test suite for configurations of the
+// Application Insights hook, so plugins such as filters can be verified safe
+// under the way logrus uses hooks before going to production.
+package hooktest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jjcollinge/logrus-appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	goroutines = 8
+	entries    = 50
+)
+
+// deliveryTimeout bounds how long the entries fired take to be delivered.
+const deliveryTimeout = 10 * time.Second
+
+// Run exercises a hook set up by configure with concurrent logging, entries
+// shared between goroutines and entries reused by the logger once fired, both
+// synchronously and asynchronously, and checks every entry is delivered once
+// with its message. Run it with the race detector enabled.
+func Run(t *testing.T, configure func(*logrus_appinsights.AppInsightsHook)) {
+	for _, async := range []bool{false, true} {
+		async := async
+		t.Run(fmt.Sprintf("async=%v", async), func(t *testing.T) {
+			run(t, async, configure)
+		})
+	}
+}
+
+func run(t *testing.T, async bool, configure func(*logrus_appinsights.AppInsightsHook)) {
+	hook, endpoint, stop := newHook(t, async, configure)
+	defer stop()
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Level = logrus.DebugLevel
+	logger.AddHook(hook)
+
+	shared := logger.WithFields(logrus.Fields{"shared": "value", "count": 1})
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < entries; i++ {
+				// entries from the pool, reused once fired
+				logger.Infof("pooled %d", i)
+				logger.WithField("goroutine", g).Warn("pooled with field")
+				// an entry shared by every goroutine
+				shared.WithField("i", i).Info("derived from shared")
+				shared.Error("shared")
+				shared.WithError(errors.New("failure")).Error("shared with error")
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	expected := map[string]int{
+		"pooled with field":   goroutines * entries,
+		"derived from shared": goroutines * entries,
+		"shared":              goroutines * entries,
+		"shared with error":   goroutines * entries,
+	}
+	for i := 0; i < entries; i++ {
+		expected[fmt.Sprintf("pooled %d", i)] = goroutines
+	}
+	total := 5 * goroutines * entries
+	deadline := time.Now().Add(deliveryTimeout)
+	for endpoint.delivered() < total && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	received := endpoint.messages()
+	for message, n := range expected {
+		if received[message] != n {
+			t.Errorf("%q delivered %d times, expected %d", message, received[message], n)
+		}
+		delete(received, message)
+	}
+	for message, n := range received {
+		t.Errorf("%q delivered %d times, never fired", message, n)
+	}
+	if keys := endpoint.keys(); len(keys) != 1 || keys[0] != "contract" {
+		t.Errorf("delivered with instrumentation keys %v, expected contract", keys)
+	}
+}
+
+// envelope is the part of the envelopes sent by the hook checked by Run.
+type envelope struct {
+	IKey string `json:"iKey"`
+	Data struct {
+		BaseType string `json:"baseType"`
+		BaseData struct {
+			Properties map[string]string `json:"properties"`
+		} `json:"baseData"`
+	} `json:"data"`
+}
+
+// endpoint records the envelopes delivered to a local ingestion endpoint.
+type endpoint struct {
+	mu        sync.Mutex
+	envelopes []envelope
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = reader
+	}
+	decoder := json.NewDecoder(body)
+	var envelopes []envelope
+	for {
+		var env envelope
+		if err := decoder.Decode(&env); err == io.EOF {
+			break
+		} else if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		envelopes = append(envelopes, env)
+	}
+	e.mu.Lock()
+	e.envelopes = append(e.envelopes, envelopes...)
+	e.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// messages returns how many times each message was delivered, as the item of
+// an entry rather than a metric derived from its fields.
+func (e *endpoint) messages() map[string]int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	messages := make(map[string]int)
+	for _, env := range e.envelopes {
+		if env.Data.BaseType != "MetricData" {
+			messages[env.Data.BaseData.Properties["message"]]++
+		}
+	}
+	return messages
+}
+
+// delivered returns the number of entry items delivered.
+func (e *endpoint) delivered() int {
+	n := 0
+	for _, count := range e.messages() {
+		n += count
+	}
+	return n
+}
+
+// keys returns the instrumentation keys the envelopes were delivered with.
+func (e *endpoint) keys() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	seen := make(map[string]bool)
+	var keys []string
+	for _, env := range e.envelopes {
+		if !seen[env.IKey] {
+			seen[env.IKey] = true
+			keys = append(keys, env.IKey)
+		}
+	}
+	return keys
+}
+
+// newHook returns a hook set up by configure, sending to a local endpoint
+// until stopped, which closes the hook.
+func newHook(t *testing.T, async bool, configure func(*logrus_appinsights.AppInsightsHook)) (*logrus_appinsights.AppInsightsHook, *endpoint, func()) {
+	endpoint := &endpoint{}
+	server := httptest.NewServer(endpoint)
+
+	hook, err := logrus_appinsights.New("contract", logrus_appinsights.Config{
+		InstrumentationKey: "contract",
+		EndpointUrl:        server.URL,
+		MaxBatchInterval:   10 * time.Millisecond,
+	})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	hook.SetAsync(async)
+	if configure != nil {
+		configure(hook)
+	}
+	stop := func() {
+		hook.Close()
+		server.Close()
+	}
+	return hook, endpoint, stop
+}
+
+// Budget bounds the cost of firing an entry. Zero values are not checked.
+type Budget struct {
+	NsPerOp     int64
+	AllocsPerOp int64
+}
+
+// CheckBudget benchmarks firing an entry with a few fields to a hook set up
+// by configure, synchronously, and fails t if it costs more than budget, so
+// services can keep the cost of their logging path in check against new
+// versions of the hook. Latency depends on the machine and is inflated by the
+// race detector, so leave headroom or only bound allocations in CI.
+func CheckBudget(t *testing.T, budget Budget, configure func(*logrus_appinsights.AppInsightsHook)) {
+	hook, _, stop := newHook(t, false, configure)
+	defer stop()
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	entry := logger.WithFields(logrus.Fields{
+		"user":     "jane",
+		"count":    42,
+		"duration": 1500 * time.Millisecond,
+		"err":      errors.New("failure"),
+	})
+	entry.Level = logrus.InfoLevel
+	entry.Message = "request served"
+	entry.Time = time.Now()
+
+	result := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hook.Fire(entry)
+		}
+	})
+	for _, err := range budget.check(result) {
+		t.Error(err)
+	}
+}
+
+// check returns the errors describing how result goes over the budget.
+func (budget Budget) check(result testing.BenchmarkResult) []error {
+	var errs []error
+	if budget.NsPerOp > 0 && result.NsPerOp() > budget.NsPerOp {
+		errs = append(errs, fmt.Errorf("Fire took %d ns/op, over the budget of %d ns/op", result.NsPerOp(), budget.NsPerOp))
+	}
+	if budget.AllocsPerOp > 0 && result.AllocsPerOp() > budget.AllocsPerOp {
+		errs = append(errs, fmt.Errorf("Fire made %d allocs/op, over the budget of %d allocs/op", result.AllocsPerOp(), budget.AllocsPerOp))
+	}
+	return errs
+}
@@ -0,0 +1,48 @@
+package hooktest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jjcollinge/logrus-appinsights"
+)
+
+func TestRun(t *testing.T) {
+	Run(t, nil)
+}
+
+func TestRunConfigured(t *testing.T) {
+	Run(t, func(hook *logrus_appinsights.AppInsightsHook) {
+		hook.AddIgnore("count")
+		hook.AddFilter("shared", func(v interface{}) interface{} {
+			return fmt.Sprintf("filtered %v", v)
+		})
+		hook.SetExceptionsEnabled(true)
+		hook.AddMetricMapping("i", "iteration")
+	})
+}
+
+func TestCheckBudget(t *testing.T) {
+	CheckBudget(t, Budget{NsPerOp: int64(time.Second), AllocsPerOp: 10000}, nil)
+}
+
+func TestBudgetCheck(t *testing.T) {
+	result := testing.BenchmarkResult{N: 10, T: 10 * time.Microsecond, MemAllocs: 200}
+
+	tests := []struct {
+		budget Budget
+		errors int
+	}{
+		{Budget{}, 0},
+		{Budget{NsPerOp: 1000, AllocsPerOp: 20}, 0},
+		{Budget{NsPerOp: 999}, 1},
+		{Budget{NsPerOp: 999, AllocsPerOp: 19}, 2},
+	}
+
+	for _, tt := range tests {
+		if errs := tt.budget.check(result); len(errs) != tt.errors {
+			t.Errorf("%+v: got errors %v", tt, errs)
+		}
+	}
+}
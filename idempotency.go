@@ -0,0 +1,66 @@
+package logrus_appinsights
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the header holding the idempotency key of a batch,
+// the same for every submission of the batch so a relay can deduplicate
+// retries after ambiguous failures, such as a timeout once the batch was
+// sent.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyWindow is how long the key of a batch is kept for retries.
+const idempotencyWindow = 10 * time.Minute
+
+// idempotencyKeys records the keys of the batches recently submitted.
+type idempotencyKeys struct {
+	mu     sync.Mutex
+	keys   map[[sha256.Size]byte]idempotencyKey
+	window time.Duration
+}
+
+type idempotencyKey struct {
+	key     string
+	expires time.Time
+}
+
+func newIdempotencyKeys(window time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{keys: make(map[[sha256.Size]byte]idempotencyKey), window: window}
+}
+
+// key returns the key of the batch payload, a new one unless the batch was
+// submitted within the window.
+func (k *idempotencyKeys) key(payload []byte, now time.Time) string {
+	sum := sha256.Sum256(payload)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for s, key := range k.keys {
+		if now.After(key.expires) {
+			delete(k.keys, s)
+		}
+	}
+	key, ok := k.keys[sum]
+	if !ok {
+		key.key = newID(16)
+	}
+	key.expires = now.Add(k.window)
+	k.keys[sum] = key
+	return key.key
+}
+
+// setIdempotencyKey sets the idempotency key of the batch payload on a copy
+// of req, if keys are attached.
+func (t *deliveryTransport) setIdempotencyKey(req *http.Request, payload []byte) *http.Request {
+	if t.idempotency == nil || payload == nil {
+		return req
+	}
+	keyed := new(http.Request)
+	*keyed = *req
+	keyed.Header = req.Header.Clone()
+	keyed.Header.Set(IdempotencyKeyHeader, t.idempotency.key(payload, time.Now()))
+	return keyed
+}
@@ -0,0 +1,65 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	keys := newIdempotencyKeys(time.Minute)
+	first := keys.key([]byte("batch"), now)
+	assert.Len(first, 32)
+
+	tests := []struct {
+		payload string
+		elapsed time.Duration
+		same    bool
+	}{
+		{"batch", 0, true},
+		{"batch", 50 * time.Second, true},
+		{"other", 50 * time.Second, false},
+		// retries extend the window
+		{"batch", 100 * time.Second, true},
+		{"batch", 200 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		key := keys.key([]byte(tt.payload), now.Add(tt.elapsed))
+		assert.Equal(tt.same, key == first, target)
+	}
+}
+
+func TestDeliveryTransportIdempotencyKey(t *testing.T) {
+	assert := assert.New(t)
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(IdempotencyKeyHeader))
+	}))
+	defer server.Close()
+
+	transport := newDeliveryTransport(nil, &deliveryStats{})
+	transport.idempotency = newIdempotencyKeys(time.Minute)
+	for _, payload := range []string{"batch", "batch", "other"} {
+		req, _ := http.NewRequest("POST", server.URL, strings.NewReader(payload))
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(err)
+		resp.Body.Close()
+		assert.Empty(req.Header.Get(IdempotencyKeyHeader))
+	}
+	assert.Len(received, 3)
+	assert.NotEmpty(received[0])
+	assert.Equal(received[0], received[1])
+	assert.NotEqual(received[0], received[2])
+}
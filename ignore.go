@@ -0,0 +1,41 @@
+package logrus_appinsights
+
+import (
+	"path"
+	"regexp"
+)
+
+// AddIgnorePattern ignores the fields whose name matches the shell pattern,
+// such as secret_* or *_token, with the syntax of path.Match. It returns
+// path.ErrBadPattern if the pattern is malformed.
+func (hook *AppInsightsHook) AddIgnorePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	hook.ignoreMatches = append(hook.ignoreMatches, func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	})
+	return nil
+}
+
+// AddIgnoreRegexp ignores the fields whose name matches re.
+func (hook *AppInsightsHook) AddIgnoreRegexp(re *regexp.Regexp) {
+	hook.ignoreMatches = append(hook.ignoreMatches, re.MatchString)
+}
+
+// ignored reports whether the field name is ignored.
+func (hook *AppInsightsHook) ignored(name string) bool {
+	hook.settings.RLock()
+	_, ok := hook.ignoreFields[name]
+	hook.settings.RUnlock()
+	if ok {
+		return true
+	}
+	for _, match := range hook.ignoreMatches {
+		if match(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddIgnorePattern(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		field   string
+		ignored bool
+	}{
+		{"secret_key", true},
+		{"secret_", true},
+		{"api_token", true},
+		{"password", true},
+		{"user_password_hash", true},
+		{"secretive", false},
+		{"user", false},
+	}
+
+	hook := AppInsightsHook{ignoreFields: map[string]struct{}{}}
+	assert.NoError(hook.AddIgnorePattern("secret_*"))
+	assert.NoError(hook.AddIgnorePattern("*_token"))
+	assert.Error(hook.AddIgnorePattern("[unclosed"))
+	hook.AddIgnoreRegexp(regexp.MustCompile(`(^|_)password(_|$)`))
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		entry := logrus.NewEntry(logrus.New()).WithField(tt.field, "value")
+		for _, h := range []*AppInsightsHook{&hook, hook.NewPipeline()} {
+			props := h.buildProperties(entry)
+			_, sent := props[tt.field]
+			assert.Equal(!tt.ignored, sent, target)
+		}
+	}
+}
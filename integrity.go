@@ -0,0 +1,295 @@
+package logrus_appinsights
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+)
+
+// Properties giving tamper-evidence to the items of a hook with an integrity
+// key. The HMAC covers the item's time, type and content: its message, name,
+// severity, exception details, properties and measurements. The items of
+// every batch submitted to the ingestion endpoint are then numbered and
+// chained: the chain of an item covers the HMAC of the items before it in the
+// batch, and the count the items of the batch, so removed, added or
+// reordered items can be detected with VerifyIntegrityChain.
+const (
+	IntegrityHMACKey  = "integrity.hmac"
+	IntegrityBatchKey = "integrity.batch"
+	IntegritySeqKey   = "integrity.seq"
+	IntegrityCountKey = "integrity.count"
+	IntegrityChainKey = "integrity.chain"
+)
+
+// integrity signs items and chains their signatures per batch.
+type integrity struct {
+	key []byte
+}
+
+// chainKey holds the integrity the batches submitted by delivery transports
+// are chained with, if any.
+type chainKey struct {
+	mu        sync.Mutex
+	integrity *integrity
+}
+
+func (c *chainKey) set(i *integrity) {
+	c.mu.Lock()
+	c.integrity = i
+	c.mu.Unlock()
+}
+
+func (c *chainKey) get() *integrity {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.integrity
+}
+
+// SetIntegrityKey sets the key items are signed and batches chained with,
+// giving tamper-evidence to security-relevant log streams. A nil key disables
+// signing. Pipelines share the batches, and so the chain key, of their hook.
+func (hook *AppInsightsHook) SetIntegrityKey(key []byte) {
+	if key == nil {
+		hook.integrity = nil
+	} else {
+		hook.integrity = &integrity{key: append([]byte{}, key...)}
+	}
+	if delivery := hook.delivery(); delivery != nil {
+		delivery.chain.set(hook.integrity)
+	}
+}
+
+// sign adds the HMAC of item to its properties.
+func (i *integrity) sign(item appinsights.Telemetry) {
+	props := item.GetProperties()
+	if props == nil {
+		return
+	}
+	if item.Time().IsZero() {
+		// timed now, as when enveloped
+		item.SetTime(time.Now())
+	}
+	// sanitized as when enveloped, so the signed content is the one sent
+	data := item.TelemetryData()
+	data.Sanitize()
+	baseData, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	canonical, err := canonicalize(item.Time().UTC().Format(envelopeTimeFormat), data.BaseType(), baseData)
+	if err != nil {
+		return
+	}
+	props[IntegrityHMACKey] = hex.EncodeToString(i.hmac(canonical))
+}
+
+func (i *integrity) hmac(data []byte) []byte {
+	mac := hmac.New(sha256.New, i.key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// canonicalize returns the canonical form of an item signed by its HMAC,
+// with the JSON encoded baseData of its envelope.
+func canonicalize(timestamp, baseType string, baseData []byte) ([]byte, error) {
+	var data map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(baseData))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	if props, ok := data["properties"].(map[string]interface{}); ok {
+		for k := range props {
+			if strings.HasPrefix(k, "integrity.") {
+				delete(props, k)
+			}
+		}
+	}
+	// maps are encoded with sorted keys
+	return json.Marshal(struct {
+		Time     string                 `json:"time"`
+		BaseType string                 `json:"baseType"`
+		BaseData map[string]interface{} `json:"baseData"`
+	}{timestamp, baseType, data})
+}
+
+// signedEnvelope is the part of an envelope covered by the integrity
+// properties.
+type signedEnvelope struct {
+	Time string `json:"time"`
+	Data struct {
+		BaseType string          `json:"baseType"`
+		BaseData json.RawMessage `json:"baseData"`
+	} `json:"data"`
+}
+
+func (e *signedEnvelope) properties() map[string]string {
+	var data struct {
+		Properties map[string]string `json:"properties"`
+	}
+	json.Unmarshal(e.Data.BaseData, &data)
+	return data.Properties
+}
+
+// VerifyIntegrity reports whether envelope, the JSON of an item as sent to
+// the ingestion endpoint, carries a valid HMAC for key.
+func VerifyIntegrity(key []byte, envelope []byte) bool {
+	var e signedEnvelope
+	if err := json.Unmarshal(envelope, &e); err != nil {
+		return false
+	}
+	return (&integrity{key: key}).verify(&e)
+}
+
+func (i *integrity) verify(e *signedEnvelope) bool {
+	signature, err := hex.DecodeString(e.properties()[IntegrityHMACKey])
+	if err != nil {
+		return false
+	}
+	canonical, err := canonicalize(e.Time, e.Data.BaseType, e.Data.BaseData)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, i.hmac(canonical))
+}
+
+// VerifyIntegrityChain returns an error unless envelopes, the JSON of the
+// items of a batch in any order, carry valid HMACs for key and form its
+// complete chain.
+func VerifyIntegrityChain(key []byte, envelopes [][]byte) error {
+	i := &integrity{key: key}
+	type link struct {
+		seq       int
+		signature []byte
+		props     map[string]string
+	}
+	links := make([]link, 0, len(envelopes))
+	for n, envelope := range envelopes {
+		var e signedEnvelope
+		if err := json.Unmarshal(envelope, &e); err != nil {
+			return err
+		}
+		if !i.verify(&e) {
+			return fmt.Errorf("Item %d has an invalid HMAC", n)
+		}
+		props := e.properties()
+		seq, err := strconv.Atoi(props[IntegritySeqKey])
+		if err != nil {
+			return fmt.Errorf("Item %d has no sequence number", n)
+		}
+		signature, _ := hex.DecodeString(props[IntegrityHMACKey])
+		links = append(links, link{seq, signature, props})
+	}
+	sort.Slice(links, func(a, b int) bool { return links[a].seq < links[b].seq })
+
+	var chain []byte
+	for n, l := range links {
+		if l.props[IntegrityBatchKey] != links[0].props[IntegrityBatchKey] {
+			return fmt.Errorf("Item %d belongs to another batch", l.seq)
+		}
+		if l.props[IntegrityCountKey] != strconv.Itoa(len(links)) {
+			return fmt.Errorf("Batch of %s items has %d", l.props[IntegrityCountKey], len(links))
+		}
+		if l.seq != n+1 {
+			return fmt.Errorf("Item %d is missing", n+1)
+		}
+		chain = i.hmac(append(chain, l.signature...))
+		if l.props[IntegrityChainKey] != hex.EncodeToString(chain) {
+			return fmt.Errorf("Item %d breaks the chain", l.seq)
+		}
+	}
+	return nil
+}
+
+// chainBatch returns the payload of a batch, gzipped or not, with its signed
+// items numbered and chained.
+func (i *integrity) chainBatch(payload []byte, gzipped bool) ([]byte, error) {
+	var reader io.Reader = bytes.NewReader(payload)
+	if gzipped {
+		gzipReader, err := getGzipReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer putGzipReader(gzipReader)
+		reader = gzipReader
+	}
+	var envelopes []map[string]interface{}
+	var signed []map[string]interface{}
+	decoder := json.NewDecoder(bufio.NewReader(reader))
+	decoder.UseNumber()
+	for {
+		var envelope map[string]interface{}
+		if err := decoder.Decode(&envelope); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, envelope)
+		if props := envelopeProperties(envelope); props != nil {
+			if _, ok := props[IntegrityHMACKey].(string); ok {
+				signed = append(signed, props)
+			}
+		}
+	}
+	if len(signed) == 0 {
+		return payload, nil
+	}
+
+	// the same batch is chained the same way when retried
+	signatures := make([][]byte, len(signed))
+	var all []byte
+	for n, props := range signed {
+		signatures[n], _ = hex.DecodeString(props[IntegrityHMACKey].(string))
+		all = append(all, signatures[n]...)
+	}
+	id := hex.EncodeToString(i.hmac(all)[:16])
+	var chain []byte
+	for n, props := range signed {
+		chain = i.hmac(append(chain, signatures[n]...))
+		props[IntegrityBatchKey] = id
+		props[IntegritySeqKey] = strconv.Itoa(n + 1)
+		props[IntegrityCountKey] = strconv.Itoa(len(signed))
+		props[IntegrityChainKey] = hex.EncodeToString(chain)
+	}
+
+	chained := new(bytes.Buffer)
+	var w io.Writer = chained
+	if gzipped {
+		gzipWriter := getGzipWriter(chained, gzip.DefaultCompression)
+		defer putGzipWriter(gzipWriter, gzip.DefaultCompression)
+		w = gzipWriter
+	}
+	encoder := json.NewEncoder(w)
+	for _, envelope := range envelopes {
+		if err := encoder.Encode(envelope); err != nil {
+			return nil, err
+		}
+	}
+	if closer, ok := w.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return chained.Bytes(), nil
+}
+
+// envelopeProperties returns the properties of a decoded envelope, if any.
+func envelopeProperties(envelope map[string]interface{}) map[string]interface{} {
+	data, _ := envelope["data"].(map[string]interface{})
+	baseData, _ := data["baseData"].(map[string]interface{})
+	props, _ := baseData["properties"].(map[string]interface{})
+	return props
+}
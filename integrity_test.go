@@ -0,0 +1,166 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedEnvelopes(t *testing.T, hook *AppInsightsHook, messages ...string) [][]byte {
+	var envelopes [][]byte
+	ctx := appinsights.NewTelemetryContext("key")
+	if hook.client != nil {
+		ctx = hook.client.Context()
+	}
+	for _, message := range messages {
+		entry := logrus.NewEntry(logrus.New()).WithField("user", "alice")
+		entry.Message = message
+		entry.Level = logrus.WarnLevel
+		items, err := hook.buildItems(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		envelope, err := json.Marshal(envelop(ctx, items[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		envelopes = append(envelopes, envelope)
+	}
+	return envelopes
+}
+
+func TestIntegrity(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("secret")
+	hook := AppInsightsHook{}
+	hook.SetIntegrityKey(key)
+	envelope := signedEnvelopes(t, &hook, "permission granted")[0]
+	assert.True(VerifyIntegrity(key, envelope))
+	assert.False(VerifyIntegrity([]byte("other"), envelope))
+
+	tests := []struct {
+		original string
+		tampered string
+	}{
+		{`"user":"alice"`, `"user":"mallory"`},
+		{`"message":"permission granted"`, `"message":"permission denied"`},
+		{`"severityLevel":2`, `"severityLevel":1`},
+		{`"time":"`, `"time":"1`},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Contains(string(envelope), tt.original, target)
+		tampered := strings.Replace(string(envelope), tt.original, tt.tampered, 1)
+		assert.False(VerifyIntegrity(key, []byte(tampered)), target)
+	}
+
+	// common properties added by the client are signed
+	hook.client = newRecordingClient()
+	hook.client.Context().CommonProperties["host.name"] = "host"
+	envelope = signedEnvelopes(t, &hook, "permission granted")[0]
+	assert.Contains(string(envelope), `"host.name":"host"`)
+	assert.True(VerifyIntegrity(key, envelope))
+
+	hook.SetIntegrityKey(nil)
+	items, err := hook.buildItems(logrus.NewEntry(logrus.New()))
+	assert.NoError(err)
+	assert.NotContains(items[0].GetProperties(), IntegrityHMACKey)
+}
+
+func TestIntegrityException(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("secret")
+	hook := AppInsightsHook{}
+	hook.SetIntegrityKey(key)
+	entry := logrus.NewEntry(logrus.New()).WithError(fmt.Errorf("disk full"))
+	entry.Level = logrus.ErrorLevel
+	items, err := hook.buildItems(entry)
+	assert.NoError(err)
+	for _, item := range items {
+		envelope, err := json.Marshal(envelop(appinsights.NewTelemetryContext("key"), item))
+		assert.NoError(err)
+		assert.True(VerifyIntegrity(key, envelope))
+		if strings.Contains(string(envelope), "disk full") {
+			tampered := strings.Replace(string(envelope), "disk full", "disk fine", -1)
+			assert.False(VerifyIntegrity(key, []byte(tampered)))
+		}
+	}
+}
+
+func TestIntegrityChain(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("secret")
+	hook := AppInsightsHook{}
+	hook.SetIntegrityKey(key)
+
+	var received [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(int64(len(payload)), r.ContentLength)
+		received = append(received, payload)
+	}))
+	defer server.Close()
+
+	transport := newDeliveryTransport(nil, &deliveryStats{})
+	transport.chain.set(hook.integrity)
+	var envelopes []*contracts.Envelope
+	for _, envelope := range signedEnvelopes(t, &hook, "first", "second", "third") {
+		e := contracts.NewEnvelope()
+		assert.NoError(json.Unmarshal(envelope, e))
+		envelopes = append(envelopes, e)
+	}
+	// retries of a batch are chained the same way
+	for i := 0; i < 2; i++ {
+		payload, err := encodePayload(envelopes, defaultEncoding)
+		assert.NoError(err)
+		req, _ := http.NewRequest("POST", server.URL, payload)
+		req.Header.Set("Content-Encoding", "gzip")
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(err)
+		resp.Body.Close()
+	}
+	assert.Len(received, 2)
+	assert.Equal(received[0], received[1])
+
+	reader, err := getGzipReader(bytes.NewReader(received[0]))
+	assert.NoError(err)
+	payload, _ := ioutil.ReadAll(reader)
+	chained := bytes.Split(bytes.TrimSpace(payload), []byte("\n"))
+	assert.Len(chained, 3)
+	assert.NoError(VerifyIntegrityChain(key, chained))
+	assert.NoError(VerifyIntegrityChain(key, [][]byte{chained[2], chained[0], chained[1]}))
+	assert.Error(VerifyIntegrityChain([]byte("other"), chained))
+
+	tests := []struct {
+		envelopes [][]byte
+	}{
+		// removed
+		{[][]byte{chained[0], chained[2]}},
+		{[][]byte{chained[0], chained[1]}},
+		// duplicated
+		{[][]byte{chained[0], chained[1], chained[1], chained[2]}},
+		// from another batch
+		{append([][]byte{chained[0], chained[1]}, signedEnvelopes(t, &hook, "third")...)},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Error(VerifyIntegrityChain(key, tt.envelopes), target)
+	}
+}
@@ -0,0 +1,103 @@
+package logrus_appinsights
+
+import (
+	"strconv"
+	"sync"
+)
+
+// MaxInternedValues is how many distinct values a hook interns. Once reached,
+// the values held keep being shared and the others are sent as they are.
+const MaxInternedValues = 4096
+
+// AddInternedFields sets the values of the named fields, such as enums, roles
+// or status codes, to be interned: the items sent share a single copy of each
+// value, and numeric values already seen are not formatted again. It cuts
+// memory and allocations when the fields repeat a few values over many
+// entries.
+func (hook *AppInsightsHook) AddInternedFields(names ...string) {
+	if hook.internFields == nil {
+		hook.internFields = make(map[string]struct{}, len(names))
+		hook.interned = &internTable{values: make(map[string]string)}
+	}
+	for _, name := range names {
+		hook.internFields[name] = struct{}{}
+	}
+}
+
+// internTable holds interned values.
+type internTable struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// string returns the interned copy of s.
+func (t *internTable) string(s string) string {
+	t.mu.RLock()
+	interned, ok := t.values[s]
+	t.mu.RUnlock()
+	if ok {
+		return interned
+	}
+	return t.add(s)
+}
+
+// bytes returns the interned copy of b, without allocating if held.
+func (t *internTable) bytes(b []byte) string {
+	t.mu.RLock()
+	interned, ok := t.values[string(b)]
+	t.mu.RUnlock()
+	if ok {
+		return interned
+	}
+	return t.add(string(b))
+}
+
+func (t *internTable) add(s string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if interned, ok := t.values[s]; ok {
+		return interned
+	}
+	if len(t.values) < MaxInternedValues {
+		t.values[s] = s
+	}
+	return s
+}
+
+// isInterned reports whether the values of the field k are interned.
+func (hook *AppInsightsHook) isInterned(k string) bool {
+	if len(hook.internFields) == 0 {
+		return false
+	}
+	_, ok := hook.internFields[k]
+	return ok
+}
+
+// formatInterned returns the property value of the formatted value v of the
+// field k, interned if the field is.
+func (hook *AppInsightsHook) formatInterned(k string, v interface{}) string {
+	if !hook.isInterned(k) {
+		return hook.formatValue(v)
+	}
+	// format numbers on the stack, only allocating for new values
+	var buf [32]byte
+	switch v := v.(type) {
+	case int:
+		return hook.interned.bytes(strconv.AppendInt(buf[:0], int64(v), 10))
+	case int64:
+		return hook.interned.bytes(strconv.AppendInt(buf[:0], v, 10))
+	case int32:
+		return hook.interned.bytes(strconv.AppendInt(buf[:0], int64(v), 10))
+	case uint:
+		return hook.interned.bytes(strconv.AppendUint(buf[:0], uint64(v), 10))
+	case uint64:
+		return hook.interned.bytes(strconv.AppendUint(buf[:0], v, 10))
+	case uint32:
+		return hook.interned.bytes(strconv.AppendUint(buf[:0], uint64(v), 10))
+	case float64:
+		return hook.interned.bytes(strconv.AppendFloat(buf[:0], v, 'g', -1, 64))
+	case float32:
+		return hook.interned.bytes(strconv.AppendFloat(buf[:0], float64(v), 'g', -1, 32))
+	}
+	return hook.interned.string(hook.formatValue(v))
+}
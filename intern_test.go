@@ -0,0 +1,78 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type internedColor int
+
+func TestAddInternedFields(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"westeurope", "westeurope"},
+		{404, "404"},
+		{int64(-12), "-12"},
+		{uint32(7), "7"},
+		{2.5, "2.5"},
+		{float32(0.1), "0.1"},
+		{internedColor(3), "3"},
+		{true, "true"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.AddInternedFields("value")
+		entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"value": tt.value, "other": tt.value})
+
+		props := hook.buildProperties(entry)
+		assert.Equal(tt.expected, props["value"], target)
+		assert.Equal(tt.expected, props["other"], target)
+		assert.Equal(map[string]string{tt.expected: tt.expected}, hook.interned.values, target)
+
+		allocs := testing.AllocsPerRun(1000, func() {
+			hook.formatInterned("value", tt.value)
+		})
+		assert.Zero(allocs, target)
+	}
+}
+
+func TestInternTableBound(t *testing.T) {
+	assert := assert.New(t)
+
+	table := internTable{values: make(map[string]string)}
+	for i := 0; i < MaxInternedValues+10; i++ {
+		assert.Equal(fmt.Sprint(i), table.string(fmt.Sprint(i)))
+	}
+	assert.Len(table.values, MaxInternedValues)
+	assert.Equal("12", table.bytes([]byte("12")))
+}
+
+func BenchmarkBuildPropertiesInterned(b *testing.B) {
+	hook := AppInsightsHook{}
+	hook.AddInternedFields("status", "bytes", "duration")
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"user":     "jane",
+		"status":   200,
+		"duration": 12.5,
+		"cached":   true,
+		"bytes":    int64(2048),
+	})
+	entry.Message = "request completed"
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			hook.buildProperties(entry)
+		}
+	})
+}
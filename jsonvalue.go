@@ -0,0 +1,69 @@
+package logrus_appinsights
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// SetJSONValues sets whether fields holding maps, slices, structs or values
+// implementing json.Marshaler are sent as compact JSON, which parse_json can
+// read in queries, instead of in the %v format. Values marshaling to a JSON
+// string, such as times, are sent as the string.
+func (hook *AppInsightsHook) SetJSONValues(enabled bool) {
+	hook.jsonValues = enabled
+}
+
+// formatValue returns the property value of the formatted field value v.
+func (hook *AppInsightsHook) formatValue(v interface{}) string {
+	// format the common types without fmt, formatting them alike
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	if hook.jsonValues && isComplex(v) {
+		if b, err := json.Marshal(v); err == nil {
+			var s string
+			if json.Unmarshal(b, &s) == nil {
+				return s
+			}
+			return string(b)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// isComplex reports whether v is a composite value or marshals itself.
+func isComplex(v interface{}) bool {
+	if _, ok := v.(json.Marshaler); ok {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonValue struct{}
+
+func (jsonValue) MarshalJSON() ([]byte, error) {
+	return []byte(`{"custom": true}`), nil
+}
+
+func TestSetJSONValues(t *testing.T) {
+	assert := assert.New(t)
+
+	type order struct {
+		ID    int      `json:"id"`
+		Items []string `json:"items"`
+	}
+
+	tests := []struct {
+		value    interface{}
+		enabled  bool
+		expected string
+	}{
+		{map[string]int{"a": 1}, false, "map[a:1]"},
+		{map[string]int{"a": 1}, true, `{"a":1}`},
+		{[]string{"x", "y"}, true, `["x","y"]`},
+		{order{1, []string{"book"}}, true, `{"id":1,"items":["book"]}`},
+		{&order{2, nil}, true, `{"id":2,"items":null}`},
+		{jsonValue{}, true, `{"custom":true}`},
+		{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), true, "2020-01-02T03:04:05Z"},
+		{errors.New("failed"), true, "failed"},
+		{42, true, "42"},
+		{"plain", true, "plain"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetJSONValues(tt.enabled)
+		entry := logrus.NewEntry(logrus.New()).WithField("value", tt.value)
+
+		props := hook.NewPipeline().buildProperties(entry)
+		assert.Equal(tt.expected, props["value"], target)
+	}
+}
+
+func TestSetJSONValuesUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.SetJSONValues(true)
+	entry := logrus.NewEntry(logrus.New()).WithField("value", []func(){nil})
+
+	// values that cannot be marshaled fall back to %v
+	assert.Equal("[<nil>]", hook.buildProperties(entry)["value"])
+}
+
+func TestFormatValue(t *testing.T) {
+	assert := assert.New(t)
+
+	type status int
+	tests := []interface{}{
+		"text", true, false, 0, -42, int64(1) << 40, int32(-7), uint(7), uint64(1) << 63, uint32(9),
+		0.0, 1.5, -2.25, 1e6, 1e21, 1e-7, 123456789.125, float32(0.1), float32(3.4e38), status(3), nil,
+	}
+
+	hook := AppInsightsHook{}
+	for _, v := range tests {
+		target := fmt.Sprintf("%T %v", v, v)
+		assert.Equal(fmt.Sprintf("%v", v), hook.formatValue(v), target)
+	}
+}
@@ -0,0 +1,121 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// numbers grouped by thousands, with an optional decimal part using the
+	// other separator: 1,234,567.89 1.234.567,89 1 234 567,89 1'234'567.89
+	groupedNumber = regexp.MustCompile(`\b\d{1,3}(?:([,.'\x{00a0}\x{202f}])\d{3})(?:[,.'\x{00a0}\x{202f}]\d{3})*(?:[.,]\d+)?\b`)
+	// dates: 31.12.2024 31/12/2024 12/31/2024 2024/12/31
+	dottedDate  = regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(\d{4})\b`)
+	slashedDate = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4})\b`)
+	yearFirst   = regexp.MustCompile(`\b(\d{4})/(\d{1,2})/(\d{1,2})\b`)
+)
+
+// SetLocaleNormalization sets whether locale-specific number and date
+// formats in messages are normalized to canonical forms, so messages emitted
+// in different locales group together.
+func (hook *AppInsightsHook) SetLocaleNormalization(enabled bool) {
+	hook.normalize = enabled
+}
+
+// NormalizeLocale rewrites numbers grouped by thousands as plain numbers with
+// a decimal point, and dates as YYYY-MM-DD. Numbers with a single separator,
+// such as 1.500 or 12,345, which could be decimals as well, parts of longer
+// dotted numbers such as versions and IP addresses, and dates that could be
+// either day-first or month-first are left unchanged.
+func NormalizeLocale(message string) string {
+	message = dottedDate.ReplaceAllStringFunc(message, func(s string) string {
+		m := dottedDate.FindStringSubmatch(s)
+		return isoDate(s, m[3], m[2], m[1])
+	})
+	message = slashedDate.ReplaceAllStringFunc(message, func(s string) string {
+		m := slashedDate.FindStringSubmatch(s)
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		switch {
+		case first > 12 && second <= 12:
+			return isoDate(s, m[3], m[2], m[1])
+		case second > 12 && first <= 12:
+			return isoDate(s, m[3], m[1], m[2])
+		default:
+			return s // ambiguous
+		}
+	})
+	message = yearFirst.ReplaceAllStringFunc(message, func(s string) string {
+		m := yearFirst.FindStringSubmatch(s)
+		return isoDate(s, m[1], m[2], m[3])
+	})
+	var normalized strings.Builder
+	last := 0
+	for _, m := range groupedNumber.FindAllStringIndex(message, -1) {
+		if partOfNumber(message, m[0], m[1]) {
+			continue
+		}
+		normalized.WriteString(message[last:m[0]])
+		normalized.WriteString(normalizeNumber(message[m[0]:m[1]]))
+		last = m[1]
+	}
+	normalized.WriteString(message[last:])
+	return normalized.String()
+}
+
+// partOfNumber reports whether message[start:end] is joined to more digits by
+// a separator, e.g. in 10.20.30.400.
+func partOfNumber(message string, start, end int) bool {
+	isDigit := func(i int) bool { return i >= 0 && i < len(message) && message[i] >= '0' && message[i] <= '9' }
+	isSeparator := func(i int) bool { return i >= 0 && i < len(message) && strings.IndexByte(".,'", message[i]) >= 0 }
+	return (isSeparator(start-1) && isDigit(start-2)) || (isSeparator(end) && isDigit(end+1))
+}
+
+func normalizeNumber(number string) string {
+	group := groupedNumber.FindStringSubmatch(number)[1]
+	integer, decimal := number, ""
+	// the decimal part uses the separator that is not the group one
+	if i := strings.LastIndexAny(number, ".,"); i >= 0 && number[i:i+1] != group {
+		integer, decimal = number[:i], "."+number[i+1:]
+	}
+	separators := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return -1
+		}
+		return r
+	}, integer)
+	if strings.Replace(separators, group, "", -1) != "" {
+		return number // mixed group separators
+	}
+	if decimal == "" && strings.Count(integer, group) < 2 {
+		return number // a single separator, grouping or decimal
+	}
+	if group == "." && decimal == "" && strings.Count(integer, ".") == 3 {
+		return number // dotted quad
+	}
+	return strings.Replace(integer, group, "", -1) + decimal
+}
+
+// isoDate returns the YYYY-MM-DD date, or s if not a valid date.
+func isoDate(s, year, month, day string) string {
+	m, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	if m < 1 || m > 12 || d < 1 || d > 31 {
+		return s
+	}
+	return fmt.Sprintf("%s-%02d-%02d", year, m, d)
+}
+
+// normalizedEntry returns entry with its message normalized, if enabled.
+func (hook *AppInsightsHook) normalizedEntry(entry *logrus.Entry) *logrus.Entry {
+	if !hook.normalize {
+		return entry
+	}
+	normalized := *entry
+	normalized.Message = NormalizeLocale(entry.Message)
+	return &normalized
+}
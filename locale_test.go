@@ -0,0 +1,74 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{"total 1,234,567.89 EUR", "total 1234567.89 EUR"},
+		{"total 1.234.567,89 EUR", "total 1234567.89 EUR"},
+		{"total 1\u00a0234\u00a0567,89 EUR", "total 1234567.89 EUR"},
+		{"total 1'234'567.89 CHF", "total 1234567.89 CHF"},
+		{"read 12,345 rows", "read 12,345 rows"},
+		{"ratio 1.500", "ratio 1.500"},
+		{"paid 1,234.5", "paid 1234.5"},
+		{"version 10.20.30.400", "version 10.20.30.400"},
+		{"version 1.2.345.678", "version 1.2.345.678"},
+		{"read 42 rows in 0.5s", "read 42 rows in 0.5s"},
+		{"mixed 1,234.567,890", "mixed 1,234.567,890"},
+		{"from 192.168.100.200", "from 192.168.100.200"},
+		{"due 31.12.2024", "due 2024-12-31"},
+		{"due 31/12/2024", "due 2024-12-31"},
+		{"due 12/31/2024", "due 2024-12-31"},
+		{"due 2024/1/5", "due 2024-01-05"},
+		{"due 01/02/2024", "due 01/02/2024"},
+		{"due 32.13.2024", "due 32.13.2024"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Equal(tt.expected, NormalizeLocale(tt.message), target)
+	}
+}
+
+func TestSetLocaleNormalization(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		enabled  bool
+		expected string
+	}{
+		{false, "charged 1.234,50 on 31.12.2024"},
+		{true, "charged 1234.50 on 2024-12-31"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetLocaleNormalization(tt.enabled)
+		entry := logrus.NewEntry(logrus.New())
+		entry.Level = logrus.InfoLevel
+		entry.Message = "charged 1.234,50 on 31.12.2024"
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		if trace, ok := items[0].(*appinsights.TraceTelemetry); assert.True(ok, target) {
+			assert.Equal(tt.expected, trace.Message, target)
+			assert.Equal(tt.expected, trace.Properties["message"], target)
+		}
+		assert.Equal("charged 1.234,50 on 31.12.2024", entry.Message, target)
+	}
+}
@@ -0,0 +1,110 @@
+package logrus_appinsights
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// AddMeasurementFields sets the numeric fields names to be sent in the
+// measurements of items instead of as properties, so they can be aggregated
+// without conversions in queries. Traces have no measurements and keep
+// sending them as properties; events, requests, dependencies, page views and
+// availability results support them.
+func (hook *AppInsightsHook) AddMeasurementFields(names ...string) {
+	if hook.measureFields == nil {
+		hook.measureFields = make(map[string]struct{}, len(names))
+	}
+	for _, name := range names {
+		hook.measureFields[name] = struct{}{}
+	}
+}
+
+// SetNumericMeasurements sets whether every numeric field is sent in the
+// measurements of items, as with AddMeasurementFields.
+func (hook *AppInsightsHook) SetNumericMeasurements(enabled bool) {
+	hook.measureAll = enabled
+}
+
+// addMeasurements moves the numeric fields of entry sent as measurements from
+// the properties of item to its measurements.
+func (hook *AppInsightsHook) addMeasurements(entry *logrus.Entry, item appinsights.Telemetry) {
+	if !hook.measureAll && len(hook.measureFields) == 0 {
+		return
+	}
+	measurements := item.GetMeasurements()
+	props := item.GetProperties()
+	if measurements == nil || props == nil {
+		return
+	}
+	for k, v := range entry.Data {
+		if _, ok := hook.measureFields[k]; !ok && !hook.measureAll {
+			continue
+		}
+		if _, ok := props[k]; !ok {
+			continue // ignored, reserved or a tag
+		}
+		if _, _, ok := hook.filterOf(k, v); ok {
+			continue
+		}
+		if f, ok := toFloat(v); ok {
+			measurements[k] = f
+			delete(props, k)
+		}
+	}
+}
+
+// DurationTextSuffix is appended to the name of a time.Duration field for the
+// property holding its human readable form, such as 1.5s.
+const DurationTextSuffix = "_text"
+
+// SetDurationMeasurements sets whether time.Duration fields are sent as a
+// number of milliseconds instead of in their human readable form, in the
+// measurements of items supporting them and as properties of the others.
+func (hook *AppInsightsHook) SetDurationMeasurements(enabled bool) {
+	hook.durations = enabled
+}
+
+// SetDurationText sets whether time.Duration fields sent as milliseconds are
+// also sent in their human readable form, as a property named after the field
+// followed by DurationTextSuffix.
+func (hook *AppInsightsHook) SetDurationText(enabled bool) {
+	hook.durationText = enabled
+}
+
+// addDurations sends the time.Duration fields of entry in the properties of
+// item as milliseconds.
+func (hook *AppInsightsHook) addDurations(entry *logrus.Entry, item appinsights.Telemetry) {
+	if !hook.durations {
+		return
+	}
+	measurements := item.GetMeasurements()
+	props := item.GetProperties()
+	if props == nil {
+		return
+	}
+	for k, v := range entry.Data {
+		d, ok := v.(time.Duration)
+		if !ok {
+			continue
+		}
+		if _, ok := props[k]; !ok {
+			continue // ignored, reserved or a tag
+		}
+		if _, _, ok := hook.filterOf(k, v); ok {
+			continue
+		}
+		ms := float64(d) / float64(time.Millisecond)
+		if measurements != nil {
+			measurements[k] = ms
+			delete(props, k)
+		} else {
+			props[k] = strconv.FormatFloat(ms, 'f', -1, 64)
+		}
+		if hook.durationText {
+			props[k+DurationTextSuffix] = d.String()
+		}
+	}
+}
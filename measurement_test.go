@@ -0,0 +1,102 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasurements(t *testing.T) {
+	assert := assert.New(t)
+
+	fields := logrus.Fields{"items": 3, "total": 12.5, "sku": "book", "ignored": 1, "filtered": 2}
+
+	tests := []struct {
+		event        bool
+		all          bool
+		names        []string
+		measurements map[string]float64
+		properties   []string
+	}{
+		{true, false, nil, map[string]float64{}, []string{"items", "total", "sku"}},
+		{true, false, []string{"total", "sku"}, map[string]float64{"total": 12.5}, []string{"items", "sku"}},
+		{true, true, nil, map[string]float64{"items": 3, "total": 12.5}, []string{"sku", "filtered"}},
+		// traces have no measurements
+		{false, true, nil, nil, []string{"items", "total", "sku"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{
+			ignoreFields: map[string]struct{}{},
+			filters:      map[string]func(interface{}) interface{}{},
+		}
+		hook.AddIgnore("ignored")
+		hook.AddFilter("filtered", func(v interface{}) interface{} { return v })
+		hook.SetEventsEnabled(tt.event)
+		hook.AddMeasurementFields(tt.names...)
+		hook.SetNumericMeasurements(tt.all)
+		entry := logrus.NewEntry(logrus.New()).WithFields(fields)
+
+		items, err := hook.NewPipeline().buildItems(entry)
+		assert.NoError(err, target)
+		if tt.event {
+			event := items[0].(*appinsights.EventTelemetry)
+			assert.Equal(tt.measurements, event.Measurements, target)
+		}
+		props := items[0].GetProperties()
+		for k := range tt.measurements {
+			assert.NotContains(props, k, target)
+		}
+		for _, k := range tt.properties {
+			assert.Contains(props, k, target)
+		}
+		assert.NotContains(props, "ignored", target)
+	}
+}
+
+func TestDurationMeasurements(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		event        bool
+		enabled      bool
+		text         bool
+		measurements map[string]float64
+		properties   map[string]string
+	}{
+		{true, false, false, map[string]float64{}, map[string]string{"elapsed": "1.5s"}},
+		{true, true, false, map[string]float64{"elapsed": 1500}, map[string]string{}},
+		{true, true, true, map[string]float64{"elapsed": 1500}, map[string]string{"elapsed_text": "1.5s"}},
+		{false, true, false, nil, map[string]string{"elapsed": "1500"}},
+		{false, true, true, nil, map[string]string{"elapsed": "1500", "elapsed_text": "1.5s"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetEventsEnabled(tt.event)
+		hook.SetDurationMeasurements(tt.enabled)
+		hook.SetDurationText(tt.text)
+		entry := logrus.NewEntry(logrus.New()).WithField("elapsed", 1500*time.Millisecond)
+
+		items, err := hook.NewPipeline().buildItems(entry)
+		assert.NoError(err, target)
+		if tt.event {
+			assert.Equal(tt.measurements, items[0].(*appinsights.EventTelemetry).Measurements, target)
+		}
+		props := items[0].GetProperties()
+		for k, v := range tt.properties {
+			assert.Equal(v, props[k], target)
+		}
+		if _, ok := tt.measurements["elapsed"]; ok {
+			assert.NotContains(props, "elapsed", target)
+		}
+	}
+}
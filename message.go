@@ -0,0 +1,78 @@
+package logrus_appinsights
+
+import (
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxMessageLength is the longest trace message Application Insights
+// accepts.
+const MaxMessageLength = 32768
+
+// MessageSizeKey is the property holding the size in bytes of a truncated
+// message.
+const MessageSizeKey = "message_size"
+
+// TruncationMarker joins the head and tail kept of a truncated message.
+const TruncationMarker = "\n...\n"
+
+// SetMaxMessageLength sets the length in bytes messages are truncated to,
+// MaxMessageLength by default. Longer messages, such as stack dumps, keep
+// their head and tail joined by TruncationMarker and send their size in the
+// MessageSizeKey property. The message property is cut the same way to the
+// length of property values. Zero or less restores the default.
+func (hook *AppInsightsHook) SetMaxMessageLength(n int) {
+	hook.maxMessageLen = n
+}
+
+// truncatedEntry returns entry with its message truncated if too long. It
+// runs before any other processing, so long messages are neither scanned nor
+// copied whole.
+func (hook *AppInsightsHook) truncatedEntry(entry *logrus.Entry) *logrus.Entry {
+	max := hook.maxMessageLen
+	if max <= 0 {
+		max = MaxMessageLength
+	}
+	if len(entry.Message) <= max {
+		return entry
+	}
+	data := make(logrus.Fields, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	data[MessageSizeKey] = len(entry.Message)
+	truncated := *entry
+	truncated.Data = data
+	truncated.Message = headTail(entry.Message, max)
+	return &truncated
+}
+
+// headTail returns s cut to max bytes, keeping its head and tail joined by
+// TruncationMarker and cutting at the start of characters.
+func headTail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	if max <= len(TruncationMarker) {
+		return truncateBytes(s, max)
+	}
+	keep := max - len(TruncationMarker)
+	head := truncateBytes(s, keep-keep/2)
+	tail := len(s) - keep/2
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+	return head + TruncationMarker + s[tail:]
+}
+
+// truncateBytes returns s cut to n bytes at the start of a character.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
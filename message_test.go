@@ -0,0 +1,76 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadTail(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		s        string
+		max      int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"abcdefghijklmnopqrst", 15, "abcde" + TruncationMarker + "pqrst"},
+		{"abcdefghijklmnopqrst", 16, "abcdef" + TruncationMarker + "pqrst"},
+		{"éééééééééé", 13, "éé" + TruncationMarker + "éé"},
+		{"abcdefghijklmnopqrst", 4, "abcd"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		truncated := headTail(tt.s, tt.max)
+		assert.Equal(tt.expected, truncated, target)
+		assert.True(len(truncated) <= tt.max, target)
+	}
+}
+
+func TestSetMaxMessageLength(t *testing.T) {
+	assert := assert.New(t)
+
+	dump := "goroutine 1 [running]:\n" + strings.Repeat("main.handler()\n", 1<<16) + "created by main.main"
+
+	tests := []struct {
+		max      int
+		message  string
+		length   int
+		property int
+	}{
+		{0, "panic", len("panic"), len("panic")},
+		{0, dump, MaxMessageLength, MaxPropertyValueLength},
+		{1024, dump, 1024, 1024},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt.max)
+
+		hook := AppInsightsHook{}
+		hook.SetMaxMessageLength(tt.max)
+		entry := logrus.NewEntry(logrus.New())
+		entry.Message = tt.message
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		trace := items[0].(*appinsights.TraceTelemetry)
+		assert.Len(trace.Message, tt.length, target)
+		assert.Len(trace.Properties["message"], tt.property, target)
+		if tt.message == dump {
+			assert.True(strings.HasPrefix(trace.Message, "goroutine 1 [running]:"), target)
+			assert.True(strings.HasSuffix(trace.Message, "created by main.main"), target)
+			assert.True(strings.HasSuffix(trace.Properties["message"], "created by main.main"), target)
+			assert.Equal(fmt.Sprint(len(dump)), trace.Properties[MessageSizeKey], target)
+		} else {
+			assert.NotContains(trace.Properties, MessageSizeKey, target)
+		}
+		assert.Equal(tt.message, entry.Message, target)
+	}
+}
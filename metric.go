@@ -0,0 +1,42 @@
+package logrus_appinsights
+
+import (
+	"sort"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// AddMetricMapping sets the numeric field to be sent as metric telemetry named
+// metricName alongside the entry. To send the value only as a metric, also
+// ignore the field with AddIgnore.
+func (hook *AppInsightsHook) AddMetricMapping(field, metricName string) {
+	if hook.metrics == nil {
+		hook.metrics = make(map[string]string)
+	}
+	hook.metrics[field] = metricName
+}
+
+// buildMetrics returns the metric telemetry for the mapped numeric fields of
+// entry, in field order.
+func (hook *AppInsightsHook) buildMetrics(entry *logrus.Entry) []appinsights.Telemetry {
+	var fields []string
+	for field := range hook.metrics {
+		if _, ok := entry.Data[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var metrics []appinsights.Telemetry
+	for _, field := range fields {
+		value, ok := toFloat(entry.Data[field])
+		if !ok {
+			continue
+		}
+		metric := appinsights.NewMetricTelemetry(hook.metrics[field], value)
+		metric.Timestamp = hook.entryTime(entry)
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
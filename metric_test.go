@@ -0,0 +1,68 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields   logrus.Fields
+		expected map[string]float64
+	}{
+		{logrus.Fields{}, map[string]float64{}},
+		{logrus.Fields{"queue_depth": 12}, map[string]float64{"QueueDepth": 12}},
+		{logrus.Fields{"queue_depth": "12"}, map[string]float64{}},
+		{logrus.Fields{"queue_depth": uint8(3), "latency_ms": 4.5, "other": 1}, map[string]float64{"QueueDepth": 3, "Latency": 4.5}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.AddMetricMapping("queue_depth", "QueueDepth")
+		hook.AddMetricMapping("latency_ms", "Latency")
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+		entry.Time = time.Now()
+
+		metrics := map[string]float64{}
+		for _, item := range hook.buildMetrics(entry) {
+			metric := item.(*appinsights.MetricTelemetry)
+			assert.Equal(entry.Time, metric.Timestamp, target)
+			metrics[metric.Name] = metric.Value
+		}
+		assert.Equal(tt.expected, metrics, target)
+	}
+}
+
+func TestBuildItemsWithMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.AddMetricMapping("queue_depth", "QueueDepth")
+	entry := logrus.NewEntry(logrus.New()).WithField("queue_depth", 7)
+	entry.Message = "queue drained"
+
+	items, err := hook.buildItems(entry)
+	assert.NoError(err)
+	if assert.Len(items, 2) {
+		trace := items[0].(*appinsights.TraceTelemetry)
+		assert.Equal("7", trace.Properties["queue_depth"])
+		assert.IsType(&appinsights.MetricTelemetry{}, items[1])
+	}
+
+	// instead of the property
+	hook.ignoreFields = map[string]struct{}{"queue_depth": {}}
+	items, err = hook.buildItems(entry)
+	assert.NoError(err)
+	if assert.Len(items, 2) {
+		assert.NotContains(items[0].(*appinsights.TraceTelemetry).Properties, "queue_depth")
+	}
+}
@@ -0,0 +1,139 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+const (
+	// MaxMirrorFileSize is the size in bytes past which the mirror file is
+	// rotated.
+	MaxMirrorFileSize = 100 << 20
+	// MirrorFileBackups is how many rotated mirror files are kept.
+	MirrorFileBackups = 5
+)
+
+// mirrorFile appends envelopes to a file, one JSON object per line, rotating
+// it once it grows past maxSize.
+type mirrorFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openMirrorFile(path string, maxSize int64, backups int) (*mirrorFile, error) {
+	m := &mirrorFile{path: path, maxSize: maxSize, backups: backups}
+	if err := m.open(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *mirrorFile) open() error {
+	file, err := os.OpenFile(m.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	m.file, m.size = file, info.Size()
+	return nil
+}
+
+// SetMirrorFile sets the hook to also write the envelope of every item it
+// sends to the file at path, one JSON object per line, e.g. for debugging
+// without access to Application Insights or to retain telemetry locally.
+// Items are written whether or not they are delivered. The file is rotated
+// once it grows past MaxMirrorFileSize, keeping MirrorFileBackups rotated
+// files suffixed .1 (the newest) and up. It must be set before creating
+// pipelines.
+func (hook *AppInsightsHook) SetMirrorFile(path string) error {
+	mirror, err := openMirrorFile(path, MaxMirrorFileSize, MirrorFileBackups)
+	if err != nil {
+		return err
+	}
+	hook.mirror.close()
+	hook.mirror = mirror
+	return nil
+}
+
+// mirrorItems writes the envelopes of items to the mirror file.
+func (hook *AppInsightsHook) mirrorItems(items []appinsights.Telemetry) {
+	if hook.mirror == nil {
+		return
+	}
+	envelopes := make([]*contracts.Envelope, len(items))
+	for i, item := range items {
+		envelopes[i] = envelop(hook.client.Context(), item)
+	}
+	hook.mirror.write(envelopes)
+}
+
+// write appends envelopes to the file, rotating it first if they would grow
+// it past its maximum size. Envelopes that cannot be written are dropped.
+func (m *mirrorFile) write(envelopes []*contracts.Envelope) {
+	if m == nil {
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, envelope := range envelopes {
+		enc.Encode(envelope)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.file == nil {
+		return
+	}
+	if m.size > 0 && m.size+int64(buf.Len()) > m.maxSize {
+		if err := m.rotate(); err != nil {
+			return
+		}
+	}
+	n, _ := m.file.Write(buf.Bytes())
+	m.size += int64(n)
+}
+
+// rotate shifts the file and its backups by one suffix, dropping the oldest,
+// and reopens an empty file.
+func (m *mirrorFile) rotate() error {
+	m.file.Close()
+	m.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", m.path, m.backups))
+	for i := m.backups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", m.path, i), fmt.Sprintf("%s.%d", m.path, i+1))
+	}
+	if m.backups > 0 {
+		os.Rename(m.path, m.path+".1")
+	} else {
+		os.Remove(m.path)
+	}
+	return m.open()
+}
+
+// close closes the file; later envelopes are dropped.
+func (m *mirrorFile) close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.file != nil {
+		m.file.Close()
+		m.file = nil
+	}
+}
@@ -0,0 +1,139 @@
+package logrus_appinsights
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func mirroredMessages(t *testing.T, path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var messages []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var envelope struct {
+			Data struct {
+				BaseData struct {
+					Message string `json:"message"`
+				} `json:"baseData"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &envelope); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, envelope.Data.BaseData.Message)
+	}
+	return messages
+}
+
+func TestSetMirrorFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "telemetry.jsonl")
+
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty", DryRun: ioutil.Discard, MirrorFile: path})
+	assert.NoError(err)
+	entry := logrus.NewEntry(logrus.New())
+	for _, message := range []string{"first", "second"} {
+		entry.Message = message
+		assert.NoError(hook.Fire(entry))
+	}
+	hook.Close()
+
+	assert.Equal([]string{"first", "second"}, mirroredMessages(t, path))
+
+	hook, err = New("test", Config{InstrumentationKey: "NotEmpty", MirrorFile: filepath.Join(dir, "missing", "telemetry.jsonl")})
+	assert.Error(err)
+	assert.Nil(hook)
+}
+
+func TestMirrorReports(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "mirror")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "telemetry.jsonl")
+
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty", DryRun: ioutil.Discard, MirrorFile: path})
+	assert.NoError(err)
+	var submitted []string
+	hook.AddPostSendHooks(func(item appinsights.Telemetry, disposition Disposition, err error) {
+		submitted = append(submitted, fmt.Sprintf("%T", item))
+	})
+	hook.SetVolumeBudget(1, 0)
+	hook.AddAggregation("latency", "latency", Counter)
+	for i := 0; i < 2; i++ {
+		entry := logrus.NewEntry(logrus.New()).WithField("latency", 10)
+		entry.Level = logrus.InfoLevel
+		entry.Message = "served"
+		assert.NoError(hook.Fire(entry))
+	}
+	hook.FlushMetrics()
+	hook.Close()
+
+	// the entry, the budget exceeded trace and the aggregated metric
+	assert.Equal([]string{"*appinsights.TraceTelemetry", "*appinsights.TraceTelemetry", "*appinsights.MetricTelemetry"}, submitted)
+	assert.Len(mirroredMessages(t, path), 3)
+}
+
+func TestMirrorFileRotation(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		backups  int
+		writes   int
+		expected []string
+	}{
+		{2, 1, []string{"0"}},
+		{2, 2, []string{"1", "0"}},
+		{2, 4, []string{"3", "2", "1"}},
+		{0, 3, []string{"2"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		dir, err := ioutil.TempDir("", "mirror")
+		assert.NoError(err, target)
+		path := filepath.Join(dir, "telemetry.jsonl")
+
+		// each envelope fills a file
+		m, err := openMirrorFile(path, 10, tt.backups)
+		assert.NoError(err, target)
+		ctx := appinsights.NewTelemetryContext("key")
+		for i := 0; i < tt.writes; i++ {
+			m.write([]*contracts.Envelope{envelop(ctx, appinsights.NewTraceTelemetry(fmt.Sprint(i), appinsights.Information))})
+		}
+		m.close()
+		m.write([]*contracts.Envelope{envelop(ctx, appinsights.NewTraceTelemetry("closed", appinsights.Information))})
+
+		for i, expected := range tt.expected {
+			name := path
+			if i > 0 {
+				name = fmt.Sprintf("%s.%d", path, i)
+			}
+			assert.Equal([]string{expected}, mirroredMessages(t, name), target)
+		}
+		_, err = os.Stat(fmt.Sprintf("%s.%d", path, len(tt.expected)))
+		assert.True(os.IsNotExist(err), target)
+
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,294 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// offlineReplayInterval is how often batches kept offline are resubmitted.
+var offlineReplayInterval = 30 * time.Second
+
+// Store keeps the batches of telemetry that could not be delivered while the
+// ingestion endpoint was unreachable, until they are resubmitted. Stores must
+// be safe for concurrent use. Alternatives to the disk and memory stores,
+// e.g. backed by an embedded database, can be used through Config.OfflineStore.
+type Store interface {
+	// Put keeps a batch.
+	Put(batch []byte) error
+	// Next returns the oldest batch and its key, or a nil batch if the
+	// store is empty.
+	Next() (key string, batch []byte, err error)
+	// Delete removes the batch with key.
+	Delete(key string) error
+}
+
+// DiskStore is a Store keeping each batch in a file of a directory.
+type DiskStore struct {
+	dir string
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+const batchFileExt = ".batch"
+
+// NewDiskStore returns a Store keeping batches in dir, created if missing.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+// Put writes batch to a new file, named so files sort in insertion order.
+func (s *DiskStore) Put(batch []byte) error {
+	s.mu.Lock()
+	s.seq++
+	name := fmt.Sprintf("%020d-%08d%s", time.Now().UnixNano(), s.seq, batchFileExt)
+	s.mu.Unlock()
+
+	// write aside and rename so Next never reads a partial batch
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := ioutil.WriteFile(tmp, batch, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, name))
+}
+
+// Next returns the oldest batch file.
+func (s *DiskStore) Next() (string, []byte, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return "", nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), batchFileExt) {
+			names = append(names, f.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", nil, nil
+	}
+	sort.Strings(names)
+	batch, err := ioutil.ReadFile(filepath.Join(s.dir, names[0]))
+	return names[0], batch, err
+}
+
+// Delete removes the batch file key.
+func (s *DiskStore) Delete(key string) error {
+	return os.Remove(filepath.Join(s.dir, filepath.Base(key)))
+}
+
+// MemoryStore is a Store keeping batches in memory up to a number of bytes,
+// dropping the oldest batches beyond it.
+type MemoryStore struct {
+	max int64
+
+	mu      sync.Mutex
+	batches [][]byte
+	keys    []string
+	bytes   int64
+	seq     uint64
+}
+
+// NewMemoryStore returns a Store keeping at most maxBytes of batches in memory.
+func NewMemoryStore(maxBytes int64) *MemoryStore {
+	return &MemoryStore{max: maxBytes}
+}
+
+// Put keeps batch, dropping the oldest batches if over capacity.
+func (s *MemoryStore) Put(batch []byte) error {
+	if int64(len(batch)) > s.max {
+		return fmt.Errorf("Batch of %d bytes exceeds the store capacity of %d bytes", len(batch), s.max)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.bytes+int64(len(batch)) > s.max {
+		s.bytes -= int64(len(s.batches[0]))
+		s.batches, s.keys = s.batches[1:], s.keys[1:]
+	}
+	s.seq++
+	s.batches = append(s.batches, batch)
+	s.keys = append(s.keys, fmt.Sprint(s.seq))
+	s.bytes += int64(len(batch))
+	return nil
+}
+
+// Next returns the oldest batch.
+func (s *MemoryStore) Next() (string, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.batches) == 0 {
+		return "", nil, nil
+	}
+	return s.keys[0], s.batches[0], nil
+}
+
+// Delete removes the batch with key.
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.keys {
+		if k == key {
+			s.bytes -= int64(len(s.batches[i]))
+			s.batches = append(s.batches[:i], s.batches[i+1:]...)
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// newOfflineStore returns the store configured by conf, if any.
+func newOfflineStore(conf Config) (Store, error) {
+	if conf.OfflineStore != nil {
+		return conf.OfflineStore, nil
+	}
+	if conf.OfflineDir == "" {
+		return nil, nil
+	}
+	store, err := NewDiskStore(conf.OfflineDir)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// retryable reports whether a batch refused with status should be retried.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, 439:
+		return true
+	}
+	return status >= http.StatusInternalServerError
+}
+
+// StatusStoredOffline is the status the client is told when a batch that
+// could not be delivered was kept offline. It is a failure the client does not
+// retry, since the batch is resubmitted in the background.
+const StatusStoredOffline = http.StatusAccepted
+
+// ErrStoredOffline is returned when delivering items straight away, e.g. with
+// FireAndWait, fails but they were kept offline to be resubmitted later.
+var ErrStoredOffline = errors.New("Telemetry could not be delivered and was kept offline")
+
+// storeAndForward sends req, keeping its payload in the store if it could not
+// be delivered, to be resubmitted in the background.
+func (t *deliveryTransport) storeAndForward(req *http.Request, payload []byte) (*http.Response, error) {
+	t.mu.Lock()
+	t.endpoint = req.URL
+	t.mu.Unlock()
+
+	resp, err := t.observe(req, payload)
+	if err == nil && !retryable(resp.StatusCode) {
+		return resp, nil
+	}
+	if storeErr := t.store.Put(payload); storeErr != nil {
+		return resp, err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	t.startReplay()
+	return &http.Response{
+		Status:     fmt.Sprintf("%d Stored Offline", StatusStoredOffline),
+		StatusCode: StatusStoredOffline,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}, nil
+}
+
+// resumeReplay starts resubmitting the batches kept by an earlier run to
+// endpoint, if any.
+func (t *deliveryTransport) resumeReplay(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.endpoint = u
+	t.mu.Unlock()
+	if _, batch, err := t.store.Next(); err == nil && batch != nil {
+		t.startReplay()
+	}
+	return nil
+}
+
+func (t *deliveryTransport) startReplay() {
+	t.replayOnce.Do(func() {
+		go t.replay(offlineReplayInterval)
+	})
+}
+
+// replay resubmits the stored batches every interval until closed.
+func (t *deliveryTransport) replay(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.drain()
+		}
+	}
+}
+
+// close stops resubmitting stored batches, and closes the base transport if
+// it can be.
+func (t *deliveryTransport) close() {
+	t.stopOnce.Do(func() { close(t.stop) })
+	if base, ok := t.base.(interface{ close() }); ok {
+		base.close()
+	}
+}
+
+// drain resubmits the stored batches, oldest first, until the store is empty
+// or the endpoint fails again.
+func (t *deliveryTransport) drain() {
+	t.mu.Lock()
+	endpoint := t.endpoint
+	t.mu.Unlock()
+	if endpoint == nil {
+		return
+	}
+	for {
+		key, batch, err := t.store.Next()
+		if err != nil || batch == nil {
+			return
+		}
+		req, err := http.NewRequest("POST", endpoint.String(), bytes.NewReader(batch))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Content-Type", "application/x-json-stream")
+		resp, err := t.observe(req, batch)
+		if err != nil {
+			return
+		}
+		resp.Body.Close()
+		if retryable(resp.StatusCode) {
+			return
+		}
+		// delivered, or refused for good
+		if err := t.store.Delete(key); err != nil {
+			return
+		}
+	}
+}
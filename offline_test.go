@@ -0,0 +1,166 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStores(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	disk, err := NewDiskStore(dir)
+	assert.NoError(err)
+
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{"disk", disk},
+		{"memory", NewMemoryStore(1024)},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt.name)
+
+		_, batch, err := tt.store.Next()
+		assert.NoError(err, target)
+		assert.Nil(batch, target)
+
+		for _, b := range []string{"first", "second", "third"} {
+			assert.NoError(tt.store.Put([]byte(b)), target)
+		}
+		for _, expected := range []string{"first", "second", "third"} {
+			key, batch, err := tt.store.Next()
+			assert.NoError(err, target)
+			assert.Equal(expected, string(batch), target)
+			assert.NoError(tt.store.Delete(key), target)
+		}
+		_, batch, err = tt.store.Next()
+		assert.NoError(err, target)
+		assert.Nil(batch, target)
+	}
+}
+
+func TestMemoryStoreCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	store := NewMemoryStore(10)
+	assert.Error(store.Put(make([]byte, 11)))
+	assert.NoError(store.Put([]byte("aaaa")))
+	assert.NoError(store.Put([]byte("bbbb")))
+	assert.NoError(store.Put([]byte("cccc")))
+
+	// the oldest batch was dropped
+	_, batch, _ := store.Next()
+	assert.Equal("bbbb", string(batch))
+}
+
+func TestStoreAndForward(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	status := http.StatusServiceUnavailable
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(body))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore(1024)
+	transport := newDeliveryTransport(nil, &deliveryStats{})
+	transport.store = store
+	client := &http.Client{Transport: transport}
+
+	// the client is told the batch was kept offline
+	resp, err := client.Post(server.URL, "application/x-json-stream", bytes.NewReader([]byte("batch")))
+	assert.NoError(err)
+	assert.Equal(StatusStoredOffline, resp.StatusCode)
+	_, batch, _ := store.Next()
+	assert.Equal("batch", string(batch))
+
+	// still unavailable
+	transport.drain()
+	_, batch, _ = store.Next()
+	assert.Equal("batch", string(batch))
+
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	transport.drain()
+	_, batch, _ = store.Next()
+	assert.Nil(batch)
+	assert.Equal([]string{"batch", "batch", "batch"}, received)
+}
+
+func TestStoredOfflineTransmit(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore(1 << 20)
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty", EndpointUrl: server.URL, OfflineStore: store})
+	assert.NoError(err)
+	defer hook.Close()
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "kept"
+	assert.Equal(ErrStoredOffline, hook.FireAndWait(entry))
+	_, batch, _ := store.Next()
+	assert.NotNil(batch)
+}
+
+func TestResumeReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(interval time.Duration) { offlineReplayInterval = interval }(offlineReplayInterval)
+	offlineReplayInterval = 10 * time.Millisecond
+
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	defer server.Close()
+
+	// kept by an earlier run
+	dir, err := ioutil.TempDir("", "offline")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	store, err := NewDiskStore(dir)
+	assert.NoError(err)
+	assert.NoError(store.Put([]byte("batch")))
+
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty", EndpointUrl: server.URL, OfflineDir: dir})
+	assert.NoError(err)
+	defer hook.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&received) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&received))
+	_, batch, _ := store.Next()
+	assert.Nil(batch)
+}
@@ -0,0 +1,164 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+)
+
+// SuppressedKeyPrefix prefixes the properties of suppression summaries
+// counting the entries suppressed per level, e.g. suppressed.error.
+const SuppressedKeyPrefix = "suppressed."
+
+// maxBudgetOperations bounds the operations whose entries are counted, the
+// oldest being forgotten first.
+const maxBudgetOperations = 10000
+
+type operationCount struct {
+	sent       int
+	suppressed map[logrus.Level]int
+	active     bool
+}
+
+// operationBudget caps the entries sent per operation, counting the others
+// until they are summarized.
+type operationBudget struct {
+	mu    sync.Mutex
+	limit int
+	ops   map[string]*operationCount
+	order []string
+	stop  chan struct{}
+}
+
+// SetOperationBudget caps the entries sent per operation to limit, e.g. 500,
+// protecting against request-scoped log floods. Every interval, the entries
+// suppressed since are sent as a single trace summarizing how many were
+// suppressed per level. A limit of zero or less disables the budget.
+func (hook *AppInsightsHook) SetOperationBudget(limit int, interval time.Duration) {
+	if b := hook.operations; b != nil {
+		b.mu.Lock()
+		if b.stop != nil {
+			close(b.stop)
+		}
+		b.mu.Unlock()
+	}
+	hook.operations = nil
+	if limit <= 0 {
+		return
+	}
+	b := &operationBudget{
+		limit: limit,
+		ops:   make(map[string]*operationCount),
+	}
+	if interval > 0 {
+		b.stop = make(chan struct{})
+		go hook.runSummaries(interval, b.stop)
+	}
+	hook.operations = b
+}
+
+func (hook *AppInsightsHook) runSummaries(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			hook.flushSummaries()
+		}
+	}
+}
+
+// flushSummaries sends the suppression summaries of the operations.
+func (hook *AppInsightsHook) flushSummaries() {
+	if hook.operations == nil {
+		return
+	}
+	hook.track(hook.operations.flush(func(level logrus.Level) contracts.SeverityLevel {
+		return hook.severity(&logrus.Entry{Level: level})
+	})...)
+}
+
+// allow reports whether an entry of the operation of item is within budget,
+// counting it as suppressed otherwise.
+func (b *operationBudget) allow(item appinsights.Telemetry, level logrus.Level) bool {
+	id := contracts.ContextTags(item.ContextTags()).Operation().GetId()
+	if id == "" {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	op, ok := b.ops[id]
+	if !ok {
+		if len(b.order) == maxBudgetOperations {
+			delete(b.ops, b.order[0])
+			b.order = b.order[1:]
+		}
+		op = &operationCount{}
+		b.ops[id] = op
+		b.order = append(b.order, id)
+	}
+	op.active = true
+	if op.sent < b.limit {
+		op.sent++
+		return true
+	}
+	if op.suppressed == nil {
+		op.suppressed = make(map[logrus.Level]int)
+	}
+	op.suppressed[level]++
+	return false
+}
+
+// flush returns the summaries of the entries suppressed since the last
+// flush, sorted by operation and of the severity of their highest level, and
+// forgets the operations idle since.
+func (b *operationBudget) flush(severity func(logrus.Level) contracts.SeverityLevel) []appinsights.Telemetry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ids := make([]string, 0, len(b.ops))
+	order := b.order[:0]
+	for _, id := range b.order {
+		op := b.ops[id]
+		if !op.active {
+			delete(b.ops, id)
+			continue
+		}
+		order = append(order, id)
+		op.active = false
+		if len(op.suppressed) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	b.order = order
+	sort.Strings(ids)
+
+	summaries := make([]appinsights.Telemetry, 0, len(ids))
+	for _, id := range ids {
+		op := b.ops[id]
+		total := 0
+		highest := logrus.TraceLevel
+		for level, n := range op.suppressed {
+			total += n
+			if level < highest {
+				highest = level
+			}
+		}
+		summary := appinsights.NewTraceTelemetry(fmt.Sprintf("%d additional entries suppressed", total), severity(highest))
+		summary.Tags.Operation().SetId(id)
+		for level, n := range op.suppressed {
+			summary.Properties[SuppressedKeyPrefix+level.String()] = strconv.Itoa(n)
+		}
+		summaries = append(summaries, summary)
+		op.suppressed = nil
+	}
+	return summaries
+}
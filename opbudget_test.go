@@ -0,0 +1,116 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOperationBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		limit    int
+		infos    int
+		errors   int
+		sent     int
+		summary  string
+		severity contracts.SeverityLevel
+	}{
+		{0, 10, 0, 10, "", 0},
+		{10, 10, 0, 10, "", 0},
+		{5, 10, 0, 5, "5 additional entries suppressed", appinsights.Information},
+		{5, 6, 3, 5, "4 additional entries suppressed", appinsights.Error},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		recording := newRecordingClient()
+		hook := AppInsightsHook{client: recording}
+		hook.SetOperationBudget(tt.limit, 0)
+		ctx := WithOperation(context.Background(), "op", "")
+		for i := 0; i < tt.infos+tt.errors; i++ {
+			entry := logrus.NewEntry(logrus.New()).WithContext(ctx)
+			entry.Level = logrus.InfoLevel
+			if i >= tt.infos {
+				entry.Level = logrus.ErrorLevel
+			}
+			assert.NoError(hook.fire(entry), target)
+		}
+		// entries outside of operations are not capped
+		assert.NoError(hook.fire(logrus.NewEntry(logrus.New())), target)
+		assert.Len(recording.tracked(), tt.sent+1, target)
+
+		hook.flushSummaries()
+		tracked := recording.tracked()[tt.sent+1:]
+		if tt.summary == "" {
+			assert.Empty(tracked, target)
+			continue
+		}
+		if assert.Len(tracked, 1, target) {
+			summary := tracked[0].(*appinsights.TraceTelemetry)
+			assert.Equal(tt.summary, summary.Message, target)
+			assert.Equal(tt.severity, summary.SeverityLevel, target)
+			assert.Equal("op", summary.Tags.Operation().GetId(), target)
+			if tt.errors > 0 {
+				assert.Equal(fmt.Sprint(tt.errors), summary.Properties[SuppressedKeyPrefix+"error"], target)
+			}
+		}
+
+		// summarized once, and forgotten once idle
+		hook.flushSummaries()
+		hook.flushSummaries()
+		assert.Len(recording.tracked(), tt.sent+2, target)
+		assert.Empty(hook.operations.ops, target)
+	}
+}
+
+func TestOperationBudgetLevelMapping(t *testing.T) {
+	assert := assert.New(t)
+
+	recording := newRecordingClient()
+	hook := AppInsightsHook{client: recording}
+	hook.SetLevelMapping(map[logrus.Level]contracts.SeverityLevel{logrus.WarnLevel: appinsights.Error})
+	hook.SetOperationBudget(1, 0)
+	ctx := WithOperation(context.Background(), "op", "")
+	for i := 0; i < 3; i++ {
+		entry := logrus.NewEntry(logrus.New()).WithContext(ctx)
+		entry.Level = logrus.WarnLevel
+		assert.NoError(hook.fire(entry))
+	}
+
+	hook.flushSummaries()
+	tracked := recording.tracked()
+	if assert.Len(tracked, 2) {
+		assert.Equal(appinsights.Error, tracked[1].(*appinsights.TraceTelemetry).SeverityLevel)
+	}
+}
+
+func TestOperationBudgetForgetsOldest(t *testing.T) {
+	assert := assert.New(t)
+
+	b := &operationBudget{limit: 1, ops: make(map[string]*operationCount)}
+	item := func(id string) appinsights.Telemetry {
+		trace := appinsights.NewTraceTelemetry("", appinsights.Information)
+		trace.Tags.Operation().SetId(id)
+		return trace
+	}
+	for i := 0; i < maxBudgetOperations+1; i++ {
+		assert.True(b.allow(item(fmt.Sprint(i)), logrus.InfoLevel))
+	}
+	assert.Len(b.ops, maxBudgetOperations)
+	assert.NotContains(b.ops, "0")
+	// new operations are still capped without any flush
+	assert.False(b.allow(item(fmt.Sprint(maxBudgetOperations)), logrus.InfoLevel))
+
+	b.flush(func(logrus.Level) contracts.SeverityLevel { return appinsights.Information })
+	b.flush(func(logrus.Level) contracts.SeverityLevel { return appinsights.Information })
+	assert.Empty(b.ops)
+	assert.Empty(b.order)
+}
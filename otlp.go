@@ -0,0 +1,405 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+// OTLPMode decides whether telemetry exported as OTLP logs is sent to
+// Application Insights as well.
+type OTLPMode int
+
+const (
+	// OTLPAlongside sends telemetry to Application Insights and exports it
+	// as OTLP logs, e.g. while migrating to an OpenTelemetry Collector.
+	OTLPAlongside OTLPMode = iota
+	// OTLPOnly exports telemetry as OTLP logs only, e.g. to reach Azure
+	// Monitor through the azuremonitor exporter of an OpenTelemetry
+	// Collector rather than with the Application Insights SDK.
+	OTLPOnly
+)
+
+const (
+	// otlpBatchSize is the most log records exported in a request.
+	otlpBatchSize = 512
+	// otlpInterval is how often pending log records are exported.
+	otlpInterval = time.Second
+	// otlpScope is the instrumentation scope of the exported log records.
+	otlpScope = "github.com/jjcollinge/logrus-appinsights"
+)
+
+// SetOTLPExport sets the hook to export the traces, exceptions and events it
+// sends as OTLP logs to endpoint, the logs URL of an OTLP/HTTP receiver such
+// as http://localhost:4318/v1/logs, with the JSON encoding. Headers are set
+// on every request, e.g. for authentication. Other items, such as requests
+// and metrics, are not logs and are only sent to Application Insights. The
+// cloud role and role instance are exported as the service.name and
+// service.instance.id resource attributes, and the operation and parent IDs
+// as the trace and span IDs of records. Batches the receiver fails to accept
+// are dropped. It must be set before creating pipelines.
+func (hook *AppInsightsHook) SetOTLPExport(endpoint string, mode OTLPMode, headers map[string]string) error {
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+	exporter := newOTLPExporter(endpoint, headers)
+	go exporter.run(otlpInterval)
+	if otlp := hook.otlpClient(); otlp != nil {
+		otlp.exporter.close()
+		otlp.exporter, otlp.forward = exporter, mode == OTLPAlongside
+	} else {
+		hook.client = &otlpClient{TelemetryClient: hook.client, exporter: exporter, forward: mode == OTLPAlongside}
+	}
+	if mode == OTLPOnly {
+		hook.canary = nil
+	}
+	return nil
+}
+
+// otlpClient exports the items it tracks as OTLP logs, and tracks them with
+// the wrapped client if forwarding.
+type otlpClient struct {
+	appinsights.TelemetryClient
+
+	exporter *otlpExporter
+	forward  bool
+}
+
+// Track exports item, and tracks it if forwarding.
+func (c *otlpClient) Track(item appinsights.Telemetry) {
+	if record, ok := otlpRecord(c.Context(), item); ok {
+		c.exporter.add(c.Context(), record)
+	}
+	if c.forward {
+		c.TelemetryClient.Track(item)
+	}
+}
+
+// Channel returns the channel of the wrapped client, exporting the pending
+// log records before flushing or closing it.
+func (c *otlpClient) Channel() appinsights.TelemetryChannel {
+	return &otlpChannel{c.TelemetryClient.Channel(), c.exporter}
+}
+
+type otlpChannel struct {
+	appinsights.TelemetryChannel
+	exporter *otlpExporter
+}
+
+func (ch *otlpChannel) Flush() {
+	ch.exporter.flush()
+	ch.TelemetryChannel.Flush()
+}
+
+func (ch *otlpChannel) Close(timeout ...time.Duration) <-chan struct{} {
+	ch.exporter.close()
+	return ch.TelemetryChannel.Close(timeout...)
+}
+
+// otlpClient returns the OTLP client of the hook, if any.
+func (hook *AppInsightsHook) otlpClient() *otlpClient {
+	for _, client := range hook.clients() {
+		if otlp, ok := client.(*otlpClient); ok {
+			return otlp
+		}
+	}
+	return nil
+}
+
+// otlpOnly reports whether the hook exports telemetry as OTLP logs only.
+func (hook *AppInsightsHook) otlpOnly() bool {
+	otlp := hook.otlpClient()
+	return otlp != nil && !otlp.forward
+}
+
+// exportOTLP exports items sent straight to Application Insights as OTLP
+// logs as well.
+func (hook *AppInsightsHook) exportOTLP(items []appinsights.Telemetry) {
+	otlp := hook.otlpClient()
+	if otlp == nil {
+		return
+	}
+	for _, item := range items {
+		if record, ok := otlpRecord(otlp.Context(), item); ok {
+			otlp.exporter.add(otlp.Context(), record)
+		}
+	}
+}
+
+// otlpExporter exports log records in batches.
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	mu       sync.Mutex
+	records  []otlpLogRecord
+	resource otlpResource
+	sending  sync.Mutex // orders the requests
+
+	kick      chan struct{}
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func newOTLPExporter(endpoint string, headers map[string]string) *otlpExporter {
+	return &otlpExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// add queues record, logged by the client of ctx.
+func (e *otlpExporter) add(ctx *appinsights.TelemetryContext, record otlpLogRecord) {
+	e.mu.Lock()
+	e.resource = otlpResourceOf(ctx)
+	e.records = append(e.records, record)
+	full := len(e.records) >= otlpBatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run exports the pending records every interval and whenever a batch is
+// full, until closed.
+func (e *otlpExporter) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		case <-e.kick:
+		}
+		e.flush()
+	}
+}
+
+// flush exports the pending records.
+func (e *otlpExporter) flush() {
+	e.sending.Lock()
+	defer e.sending.Unlock()
+	for {
+		e.mu.Lock()
+		records := e.records
+		if len(records) > otlpBatchSize {
+			records = records[:otlpBatchSize]
+		}
+		e.records = e.records[len(records):]
+		resource := e.resource
+		e.mu.Unlock()
+		if len(records) == 0 {
+			return
+		}
+		e.export(resource, records)
+	}
+}
+
+// close exports the pending records and stops exporting.
+func (e *otlpExporter) close() {
+	e.closeOnce.Do(func() {
+		close(e.stop)
+		e.flush()
+	})
+}
+
+// export posts records to the endpoint, returning an error unless accepted.
+func (e *otlpExporter) export(resource otlpResource, records []otlpLogRecord) error {
+	body, err := json.Marshal(otlpLogsData{ResourceLogs: []otlpResourceLogs{{
+		Resource:  resource,
+		ScopeLogs: []otlpScopeLogs{{Scope: otlpScopeInfo{Name: otlpScope}, LogRecords: records}},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP receiver rejected logs with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP/HTTP JSON encoding of the logs data model.
+type (
+	otlpLogsData struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScopeInfo   `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScopeInfo struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano         string         `json:"timeUnixNano"`
+		ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+		SeverityNumber       int            `json:"severityNumber"`
+		SeverityText         string         `json:"severityText"`
+		Body                 otlpAnyValue   `json:"body"`
+		Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+		TraceID              string         `json:"traceId,omitempty"`
+		SpanID               string         `json:"spanId,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpSeverities are the OTLP severity numbers and texts of the severity
+// levels of Application Insights.
+var otlpSeverities = map[contracts.SeverityLevel]struct {
+	number int
+	text   string
+}{
+	contracts.Verbose:     {5, "DEBUG"},
+	contracts.Information: {9, "INFO"},
+	contracts.Warning:     {13, "WARN"},
+	contracts.Error:       {17, "ERROR"},
+	contracts.Critical:    {21, "FATAL"},
+}
+
+// otlpRecord returns the log record of item, tracked by the client of ctx,
+// or false if it is not a log.
+func otlpRecord(ctx *appinsights.TelemetryContext, item appinsights.Telemetry) (otlpLogRecord, bool) {
+	var (
+		body       string
+		severity   = contracts.Information
+		attributes []otlpKeyValue
+	)
+	switch item := item.(type) {
+	case *appinsights.TraceTelemetry:
+		body, severity = item.Message, item.SeverityLevel
+	case *appinsights.ExceptionTelemetry:
+		body, severity = fmt.Sprint(item.Error), item.SeverityLevel
+		attributes = append(attributes,
+			otlpKeyValue{"exception.type", otlpString(fmt.Sprintf("%T", item.Error))},
+			otlpKeyValue{"exception.message", otlpString(body)})
+	case *typedException:
+		body, severity = item.message, item.SeverityLevel
+		attributes = append(attributes,
+			otlpKeyValue{"exception.type", otlpString(item.typeName)},
+			otlpKeyValue{"exception.message", otlpString(body)})
+	case *appinsights.EventTelemetry:
+		body = item.Name
+		attributes = append(attributes, otlpKeyValue{"event.name", otlpString(item.Name)})
+	default:
+		return otlpLogRecord{}, false
+	}
+
+	props := make(map[string]string, len(item.GetProperties())+len(ctx.CommonProperties))
+	for k, v := range ctx.CommonProperties {
+		props[k] = v
+	}
+	for k, v := range item.GetProperties() {
+		props[k] = v
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attributes = append(attributes, otlpKeyValue{k, otlpString(props[k])})
+	}
+	measurements := item.GetMeasurements()
+	keys = keys[:0]
+	for k := range measurements {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := measurements[k]
+		attributes = append(attributes, otlpKeyValue{k, otlpAnyValue{DoubleValue: &v}})
+	}
+
+	timestamp := item.Time()
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	level := otlpSeverities[severity]
+	tags := contracts.ContextTags(item.ContextTags())
+	return otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(timestamp.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       level.number,
+		SeverityText:         level.text,
+		Body:                 otlpString(body),
+		Attributes:           attributes,
+		TraceID:              otlpID(tags.Operation().GetId(), 16),
+		SpanID:               otlpID(tags.Operation().GetParentId(), 8),
+	}, true
+}
+
+// otlpResourceOf returns the resource of the telemetry of the client of ctx.
+func otlpResourceOf(ctx *appinsights.TelemetryContext) otlpResource {
+	var resource otlpResource
+	if role := ctx.Tags.Cloud().GetRole(); role != "" {
+		resource.Attributes = append(resource.Attributes, otlpKeyValue{"service.name", otlpString(role)})
+	}
+	if instance := ctx.Tags.Cloud().GetRoleInstance(); instance != "" {
+		resource.Attributes = append(resource.Attributes, otlpKeyValue{"service.instance.id", otlpString(instance)})
+	}
+	return resource
+}
+
+// otlpID returns id if it is the hex encoding of n bytes, as the IDs of W3C
+// trace contexts are.
+func otlpID(id string, n int) string {
+	if len(id) != 2*n {
+		return ""
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return ""
+	}
+	return id
+}
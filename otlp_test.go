@@ -0,0 +1,186 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// otlpReceiver records the log records posted to it.
+type otlpReceiver struct {
+	mu      sync.Mutex
+	logs    []otlpLogsData
+	headers []http.Header
+}
+
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	var logs otlpLogsData
+	if err := json.Unmarshal(body, &logs); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.logs = append(r.logs, logs)
+	r.headers = append(r.headers, req.Header)
+	r.mu.Unlock()
+}
+
+func (r *otlpReceiver) records() []otlpLogRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var records []otlpLogRecord
+	for _, logs := range r.logs {
+		for _, resourceLogs := range logs.ResourceLogs {
+			for _, scopeLogs := range resourceLogs.ScopeLogs {
+				records = append(records, scopeLogs.LogRecords...)
+			}
+		}
+	}
+	return records
+}
+
+func otlpAttribute(attributes []otlpKeyValue, key string) (otlpAnyValue, bool) {
+	for _, kv := range attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return otlpAnyValue{}, false
+}
+
+func TestOTLPRecord(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		item     appinsights.Telemetry
+		ok       bool
+		body     string
+		severity int
+	}{
+		{appinsights.NewTraceTelemetry("started", appinsights.Information), true, "started", 9},
+		{appinsights.NewTraceTelemetry("failed", appinsights.Critical), true, "failed", 21},
+		{appinsights.NewExceptionTelemetry(errors.New("boom")), true, "boom", 17},
+		{appinsights.NewEventTelemetry("signed_in"), true, "signed_in", 9},
+		{appinsights.NewMetricTelemetry("queue_depth", 3), false, "", 0},
+		{appinsights.NewRequestTelemetry("GET", "/", 0, "200"), false, "", 0},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		ctx := appinsights.NewTelemetryContext("key")
+		ctx.CommonProperties["env"] = "test"
+		if props := tt.item.GetProperties(); props != nil {
+			props["user"] = "jane"
+		}
+		record, ok := otlpRecord(ctx, tt.item)
+		assert.Equal(tt.ok, ok, target)
+		if !ok {
+			continue
+		}
+		assert.Equal(tt.body, *record.Body.StringValue, target)
+		assert.Equal(tt.severity, record.SeverityNumber, target)
+		user, _ := otlpAttribute(record.Attributes, "user")
+		assert.Equal("jane", *user.StringValue, target)
+		env, _ := otlpAttribute(record.Attributes, "env")
+		assert.Equal("test", *env.StringValue, target)
+	}
+}
+
+func TestOTLPID(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		id       string
+		n        int
+		expected string
+	}{
+		{"4bf92f3577b34da6a3ce929d0e0e4736", 16, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"00f067aa0ba902b7", 8, "00f067aa0ba902b7"},
+		{"op", 16, ""},
+		{"zzf067aa0ba902b7", 8, ""},
+		{"", 8, ""},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Equal(tt.expected, otlpID(tt.id, tt.n), target)
+	}
+}
+
+func TestSetOTLPExport(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		only     bool
+		received int32
+	}{
+		{false, 2},
+		{true, 0},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		var received int32
+		ingestion := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&received, 1)
+		}))
+		receiver := &otlpReceiver{}
+		collector := httptest.NewServer(receiver)
+
+		hook, err := New("test", Config{
+			InstrumentationKey: "NotEmpty",
+			EndpointUrl:        ingestion.URL,
+			OTLPEndpoint:       collector.URL + "/v1/logs",
+			OTLPHeaders:        map[string]string{"Api-Key": "secret"},
+			OTLPOnly:           tt.only,
+		})
+		assert.NoError(err, target)
+		ctx := WithOperation(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+		entry := logrus.NewEntry(logrus.New()).WithContext(ctx).WithField("user", "jane")
+		entry.Message = "sent"
+		entry.Level = logrus.WarnLevel
+		assert.NoError(hook.Fire(entry), target)
+		entry.Message = "confirmed"
+		hook.FireAndWait(entry)
+		hook.Close()
+
+		records := receiver.records()
+		if assert.Len(records, 2, target) {
+			assert.Equal("sent", *records[0].Body.StringValue, target)
+			assert.Equal("confirmed", *records[1].Body.StringValue, target)
+			assert.Equal(13, records[0].SeverityNumber, target)
+			assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", records[0].TraceID, target)
+			assert.Equal("00f067aa0ba902b7", records[0].SpanID, target)
+			user, _ := otlpAttribute(records[0].Attributes, "user")
+			assert.Equal("jane", *user.StringValue, target)
+		}
+		receiver.mu.Lock()
+		service, _ := otlpAttribute(receiver.logs[0].ResourceLogs[0].Resource.Attributes, "service.name")
+		assert.Equal("test", *service.StringValue, target)
+		assert.Equal("secret", receiver.headers[0].Get("Api-Key"), target)
+		receiver.mu.Unlock()
+		assert.Equal(tt.received, atomic.LoadInt32(&received), target)
+
+		collector.Close()
+		ingestion.Close()
+	}
+
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty", OTLPEndpoint: "localhost:4318"})
+	assert.Error(err)
+	assert.Nil(hook)
+}
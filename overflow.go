@@ -0,0 +1,66 @@
+package logrus_appinsights
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// OverflowKey is the property holding, as a JSON object, the properties of
+// an item beyond the maximum set with SetMaxProperties.
+const OverflowKey = "overflow_json"
+
+// builtinProperties are kept before other properties when capping them,
+// after the priority fields.
+var builtinProperties = []string{"message", MessageSizeKey, "source_level", "source_timestamp", TimeBucketKey, RetentionKey, SampleRateKey}
+
+// SetMaxProperties caps the properties sent with an item to n, the priority
+// fields and the properties the hook adds itself being kept first and then
+// the others in key order, while the remaining properties are sent in a
+// single OverflowKey property counted in n. Zero or less removes the cap,
+// the default.
+func (hook *AppInsightsHook) SetMaxProperties(n int) {
+	hook.maxProperties = n
+}
+
+// AddPriorityFields sets the fields names to be kept in order of priority
+// when capping properties with SetMaxProperties.
+func (hook *AppInsightsHook) AddPriorityFields(names ...string) {
+	hook.priorities = append(hook.priorities, names...)
+}
+
+// capProperties moves the properties of props beyond the maximum into the
+// overflow property.
+func (hook *AppInsightsHook) capProperties(props map[string]string) {
+	if hook.maxProperties <= 0 || len(props) <= hook.maxProperties {
+		return
+	}
+	ranked := make(map[string]int, len(hook.priorities)+len(builtinProperties))
+	for _, k := range append(append([]string{}, hook.priorities...), builtinProperties...) {
+		if _, ok := ranked[k]; !ok {
+			ranked[k] = len(ranked)
+		}
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, iRanked := ranked[keys[i]]
+		rj, jRanked := ranked[keys[j]]
+		switch {
+		case iRanked && jRanked:
+			return ri < rj
+		case iRanked != jRanked:
+			return iRanked
+		}
+		return keys[i] < keys[j]
+	})
+
+	overflow := make(map[string]string, len(keys)-hook.maxProperties+1)
+	for _, k := range keys[hook.maxProperties-1:] {
+		overflow[k] = props[k]
+		delete(props, k)
+	}
+	b, _ := json.Marshal(overflow)
+	props[OverflowKey] = string(b)
+}
@@ -0,0 +1,54 @@
+package logrus_appinsights
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMaxProperties(t *testing.T) {
+	assert := assert.New(t)
+
+	fields := logrus.Fields{"a": 1, "b": 2, "c": 3, "user": "jane"}
+
+	tests := []struct {
+		max        int
+		priorities []string
+		kept       []string
+		overflow   map[string]string
+	}{
+		{0, nil, []string{"a", "b", "c", "user", "message", "source_level", "source_timestamp"}, nil},
+		{7, nil, []string{"a", "b", "c", "user", "message", "source_level", "source_timestamp"}, nil},
+		{6, nil, []string{"message", "source_level", "source_timestamp", "a", "b"}, map[string]string{"c": "3", "user": "jane"}},
+		{4, []string{"user", "c"}, []string{"user", "c", "message"}, map[string]string{"a": "1", "b": "2", "source_level": "info"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetMaxProperties(tt.max)
+		hook.AddPriorityFields(tt.priorities...)
+		entry := logrus.NewEntry(logrus.New()).WithFields(fields)
+		entry.Level = logrus.InfoLevel
+
+		props := hook.NewPipeline().buildProperties(entry)
+		for _, k := range tt.kept {
+			assert.Contains(props, k, target)
+		}
+		if tt.overflow == nil {
+			assert.NotContains(props, OverflowKey, target)
+			continue
+		}
+		assert.Len(props, tt.max, target)
+		overflow := map[string]string{}
+		assert.NoError(json.Unmarshal([]byte(props[OverflowKey]), &overflow), target)
+		for k, v := range tt.overflow {
+			assert.Equal(v, overflow[k], target)
+			assert.NotContains(props, k, target)
+		}
+	}
+}
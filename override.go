@@ -0,0 +1,53 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// Reserved fields overriding the telemetry type of an individual entry, e.g.
+// log.WithFields(logrus.Fields{TypeKey: TypeMetric, ValueKey: 12}).Info("queue_depth").
+const (
+	TypeKey  = "ai_type"
+	ValueKey = "ai_value"
+)
+
+// Telemetry types an entry can be routed to with TypeKey.
+const (
+	TypeTrace     = "trace"
+	TypeEvent     = "event"
+	TypeException = "exception"
+	TypeMetric    = "metric"
+)
+
+// buildOverride returns the telemetry item of the type requested by the
+// entry's TypeKey field, if any.
+func (hook *AppInsightsHook) buildOverride(entry *logrus.Entry) (appinsights.Telemetry, bool, error) {
+	switch entry.Data[TypeKey] {
+	case TypeTrace:
+		trace, err := hook.buildTrace(entry)
+		return trace, true, err
+	case TypeEvent:
+		return hook.buildEvent(entry), true, nil
+	case TypeException:
+		err, ok := entry.Data[logrus.ErrorKey].(error)
+		if !ok || err == nil {
+			err = errors.New(entry.Message)
+		}
+		return hook.buildException(entry, err), true, nil
+	case TypeMetric:
+		value, ok := toFloat(entry.Data[ValueKey])
+		if !ok {
+			return nil, true, fmt.Errorf("Could not create metric telemetry, %s is not numeric in entry %+v", ValueKey, entry)
+		}
+		metric := appinsights.NewMetricTelemetry(entry.Message, value)
+		metric.Timestamp = hook.entryTime(entry)
+		metric.Properties = hook.buildProperties(entry)
+		return metric, true, nil
+	default:
+		return nil, false, nil
+	}
+}
@@ -0,0 +1,64 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		events    bool
+		fields    logrus.Fields
+		expected  appinsights.Telemetry
+		expectErr bool
+	}{
+		{false, logrus.Fields{}, &appinsights.TraceTelemetry{}, false},
+		{false, logrus.Fields{TypeKey: "unknown"}, &appinsights.TraceTelemetry{}, false},
+		{true, logrus.Fields{TypeKey: TypeTrace}, &appinsights.TraceTelemetry{}, false},
+		{false, logrus.Fields{TypeKey: TypeEvent}, &appinsights.EventTelemetry{}, false},
+		{false, logrus.Fields{TypeKey: TypeException}, &appinsights.ExceptionTelemetry{}, false},
+		{false, logrus.Fields{TypeKey: TypeException, logrus.ErrorKey: errors.New("boom")}, &appinsights.ExceptionTelemetry{}, false},
+		{false, logrus.Fields{TypeKey: TypeMetric, ValueKey: 12}, &appinsights.MetricTelemetry{}, false},
+		{false, logrus.Fields{TypeKey: TypeMetric, ValueKey: "twelve"}, nil, true},
+		{false, logrus.Fields{TypeKey: TypeMetric}, nil, true},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetEventsEnabled(tt.events)
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+		entry.Level = logrus.InfoLevel
+		entry.Message = "queue_depth"
+
+		item, err := hook.buildItem(entry)
+		if tt.expectErr {
+			assert.Error(err, target)
+			continue
+		}
+		assert.NoError(err, target)
+		assert.IsType(tt.expected, item, target)
+		assert.NotContains(item.GetProperties(), TypeKey, target)
+		assert.NotContains(item.GetProperties(), ValueKey, target)
+
+		switch item := item.(type) {
+		case *appinsights.MetricTelemetry:
+			assert.Equal("queue_depth", item.Name, target)
+			assert.Equal(12.0, item.Value, target)
+		case *appinsights.ExceptionTelemetry:
+			if err, ok := tt.fields[logrus.ErrorKey]; ok {
+				assert.Equal(err, item.Error, target)
+			} else {
+				assert.Equal(errors.New("queue_depth"), item.Error, target)
+			}
+		}
+	}
+}
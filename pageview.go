@@ -0,0 +1,47 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+)
+
+// Reserved fields describing a page rendered by the application. Entries
+// carrying a page name are sent as page view telemetry instead of traces.
+const (
+	PageNameKey = "page.name"
+	PageURLKey  = "page.url"
+)
+
+func isPageViewEntry(entry *logrus.Entry) bool {
+	_, ok := entry.Data[PageNameKey]
+	return ok
+}
+
+// buildPageView returns the page view telemetry for a page rendering log,
+// part of the operation identified by OperationIDKey if any.
+func (hook *AppInsightsHook) buildPageView(entry *logrus.Entry) *appinsights.PageViewTelemetry {
+	name := fmt.Sprintf("%v", entry.Data[PageNameKey])
+	url := ""
+	if v, ok := entry.Data[PageURLKey]; ok {
+		url = fmt.Sprintf("%v", v)
+	}
+
+	pageView := appinsights.NewPageViewTelemetry(name, url)
+	duration, _ := toDuration(entry.Data[DurationKey])
+	pageView.MarkTime(hook.entryTime(entry).Add(-duration), hook.entryTime(entry))
+	if id, ok := entry.Data[OperationIDKey]; ok {
+		contracts.ContextTags(pageView.Tags).Operation().SetId(fmt.Sprintf("%v", id))
+	}
+
+	for k, v := range hook.buildProperties(entry) {
+		switch k {
+		case PageNameKey, PageURLKey, DurationKey:
+		default:
+			pageView.Properties[k] = v
+		}
+	}
+	return pageView
+}
@@ -0,0 +1,50 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPageView(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields    logrus.Fields
+		url       string
+		duration  time.Duration
+		operation string
+	}{
+		{logrus.Fields{PageNameKey: "Home"}, "", 0, ""},
+		{logrus.Fields{PageNameKey: "Home", PageURLKey: "https://example.com/", DurationKey: 80}, "https://example.com/", 80 * time.Millisecond, ""},
+		{logrus.Fields{PageNameKey: "Home", OperationIDKey: "op"}, "", 0, "op"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields).WithField("tag", "fieldTag")
+		entry.Message = "page rendered"
+		entry.Time = time.Now()
+
+		item, err := hook.buildItem(entry)
+		assert.NoError(err, target)
+		pageView, ok := item.(*appinsights.PageViewTelemetry)
+		if !assert.True(ok, target) {
+			continue
+		}
+		assert.Equal("Home", pageView.Name, target)
+		assert.Equal(tt.url, pageView.Url, target)
+		assert.Equal(tt.duration, pageView.Duration, target)
+		assert.Equal(entry.Time.Add(-tt.duration), pageView.Timestamp, target)
+		assert.Equal(tt.operation, contracts.ContextTags(pageView.Tags).Operation().GetId(), target)
+		assert.Equal("fieldTag", pageView.Properties["tag"], target)
+		assert.NotContains(pageView.Properties, PageNameKey, target)
+	}
+}
@@ -0,0 +1,119 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+)
+
+// Properties describing the value of a recovered panic.
+const (
+	PanicTypeKey  = "panic.type"
+	PanicValueKey = "panic.value"
+)
+
+// TrackPanic recovers from an active panic and sends it as exception
+// telemetry, part of the operation of ctx if any. If rethrow is true, the
+// exception is delivered before panicking again. Should be deferred in the
+// functions to monitor.
+//
+// Errors and structs are serialized structurally: the exception is typed
+// after the panic value, and its JSON body sent as a property, so
+// panic-driven failures group by type.
+func (hook *AppInsightsHook) TrackPanic(ctx context.Context, rethrow bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	item := hook.buildPanic(ctx, r)
+	if rethrow {
+		hook.transmit(hook.httpClient, []appinsights.Telemetry{item})
+		panic(r)
+	}
+	hook.track(item)
+}
+
+// typedException is exception telemetry with the given type name and
+// message, e.g. typed after a panic value or with its message scrubbed.
+type typedException struct {
+	*appinsights.ExceptionTelemetry
+	typeName string
+	message  string
+}
+
+func (e *typedException) TelemetryData() appinsights.TelemetryData {
+	data := e.ExceptionTelemetry.TelemetryData().(*contracts.ExceptionData)
+	data.Exceptions[0].TypeName = e.typeName
+	data.Exceptions[0].Message = e.message
+	return data
+}
+
+// buildPanic returns the exception telemetry for the panic value r.
+func (hook *AppInsightsHook) buildPanic(ctx context.Context, r interface{}) appinsights.Telemetry {
+	typeName, message, body := describePanic(r)
+	entry := &logrus.Entry{
+		Data:    logrus.Fields{},
+		Level:   logrus.PanicLevel,
+		Message: message,
+		Context: ctx,
+	}
+
+	exception := appinsights.NewExceptionTelemetry(r)
+	exception.Frames = panicStack()
+	exception.SeverityLevel = hook.severity(entry)
+	exception.Timestamp = hook.entryTime(entry)
+	exception.Properties = hook.entryProperties(entry)
+	hook.addHookProperty(exception.Properties, PanicTypeKey, typeName)
+	if body != "" {
+		hook.addHookProperty(exception.Properties, PanicValueKey, body)
+	}
+	hook.finishProperties(exception.Properties)
+	item := &typedException{exception, hook.scrub(typeName), hook.scrub(message)}
+	hook.correlate(entry, item)
+	return item
+}
+
+// describePanic returns the type, message and JSON body, for errors and
+// structs, of a panic value.
+func describePanic(r interface{}) (typeName, message, body string) {
+	typeName = reflect.TypeOf(r).String()
+	v := reflect.ValueOf(r)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if b, err := json.Marshal(r); err == nil && string(b) != "{}" {
+			body = string(b)
+		}
+	}
+	switch value := r.(type) {
+	case error:
+		message = value.Error()
+	case fmt.Stringer:
+		message = value.String()
+	default:
+		if body != "" {
+			message = body
+		} else {
+			message = fmt.Sprintf("%v", r)
+		}
+	}
+	return typeName, message, body
+}
+
+// panicStack returns the current callstack from the function that panicked.
+func panicStack() []*contracts.StackFrame {
+	frames := appinsights.GetCallstack(1)
+	for len(frames) > 0 && (frames[0].Assembly == hookPackage || frames[0].Assembly == "runtime") {
+		frames = frames[1:]
+	}
+	for i, frame := range frames {
+		frame.Level = i
+	}
+	return frames
+}
@@ -0,0 +1,175 @@
+package logrus_appinsights
+
+import (
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// PII is a set of kinds of personal data scrubbed from messages and
+// properties.
+type PII int
+
+const (
+	// EmailAddresses are replaced with [email].
+	EmailAddresses PII = 1 << iota
+	// PhoneNumbers, written with a leading + or separators between groups
+	// of digits, are replaced with [phone].
+	PhoneNumbers
+	// CardNumbers passing the Luhn check are replaced with [card].
+	CardNumbers
+	// IPAddresses, v4 or v6, are replaced with [ip].
+	IPAddresses
+
+	// AllPII scrubs every kind of personal data known.
+	AllPII = EmailAddresses | PhoneNumbers | CardNumbers | IPAddresses
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\(?\d[\d ().-]{6,}\d`)
+	datePattern  = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+	cardPattern  = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	ipv4Pattern  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Pattern  = regexp.MustCompile(`[0-9A-Fa-f]*:[0-9A-Fa-f:.]*:[0-9A-Fa-f.]*`)
+	digitsOnly   = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "+", "")
+)
+
+// SetPIIScrubbing sets the kinds of personal data masked in the message,
+// properties, URLs, names and other fields of items, none by default. Detection is heuristic and meant as
+// a safety net rather than a replacement for keeping personal data out of
+// logs.
+func (hook *AppInsightsHook) SetPIIScrubbing(kinds PII) {
+	hook.redactionCounts()
+	hook.pii = kinds
+}
+
+// ScrubPII returns s with the personal data of kinds masked.
+func ScrubPII(s string, kinds PII) string {
+	return scrubPII(s, kinds, nil)
+}
+
+// scrubPII returns s with the personal data of kinds masked, counting the
+// values masked in r.
+func scrubPII(s string, kinds PII, r *redactions) string {
+	if kinds&CardNumbers != 0 {
+		s = cardPattern.ReplaceAllStringFunc(s, func(m string) string {
+			if luhn(digitsOnly.Replace(m)) {
+				r.add("pii.card")
+				return "[card]"
+			}
+			return m
+		})
+	}
+	if kinds&EmailAddresses != 0 {
+		s = emailPattern.ReplaceAllStringFunc(s, func(string) string {
+			r.add("pii.email")
+			return "[email]"
+		})
+	}
+	if kinds&IPAddresses != 0 {
+		maskIP := func(m string) string {
+			if net.ParseIP(m) == nil {
+				return m
+			}
+			r.add("pii.ip")
+			return "[ip]"
+		}
+		s = ipv4Pattern.ReplaceAllStringFunc(s, maskIP)
+		s = ipv6Pattern.ReplaceAllStringFunc(s, maskIP)
+	}
+	if kinds&PhoneNumbers != 0 {
+		s = phonePattern.ReplaceAllStringFunc(s, func(m string) string {
+			digits := len(digitsOnly.Replace(m))
+			if digits < 9 || digits > 15 {
+				return m
+			}
+			if !strings.HasPrefix(m, "+") && !strings.ContainsAny(m, " ().-") {
+				return m // a bare number, more likely an ID or a timestamp
+			}
+			if datePattern.MatchString(m) {
+				return m
+			}
+			r.add("pii.phone")
+			return "[phone]"
+		})
+	}
+	return s
+}
+
+// luhn reports whether digits pass the Luhn check of card numbers.
+func luhn(digits string) bool {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// scrub returns s with its secrets redacted and personal data masked.
+func (hook *AppInsightsHook) scrub(s string) string {
+	if hook.secrets {
+		s = hook.redactSecrets(s)
+	}
+	return scrubPII(s, hook.pii, hook.redactions)
+}
+
+// scrubbedEntry returns entry with its message scrubbed.
+func (hook *AppInsightsHook) scrubbedEntry(entry *logrus.Entry) *logrus.Entry {
+	if hook.pii == 0 && !hook.secrets {
+		return entry
+	}
+	scrubbed := *entry
+	scrubbed.Message = hook.scrub(entry.Message)
+	return &scrubbed
+}
+
+// scrubItem scrubs the fields of item copied from the fields of its entry
+// rather than from its scrubbed message.
+func (hook *AppInsightsHook) scrubItem(item appinsights.Telemetry) {
+	if hook.pii == 0 && !hook.secrets {
+		return
+	}
+	switch t := item.(type) {
+	case *appinsights.RequestTelemetry:
+		t.Name = hook.scrub(t.Name)
+		t.Url = hook.scrub(t.Url)
+		t.Source = hook.scrub(t.Source)
+	case *appinsights.PageViewTelemetry:
+		t.Name = hook.scrub(t.Name)
+		t.Url = hook.scrub(t.Url)
+	case *appinsights.RemoteDependencyTelemetry:
+		t.Name = hook.scrub(t.Name)
+		t.Data = hook.scrub(t.Data)
+		t.Target = hook.scrub(t.Target)
+	case *appinsights.AvailabilityTelemetry:
+		t.Name = hook.scrub(t.Name)
+		t.Message = hook.scrub(t.Message)
+		t.RunLocation = hook.scrub(t.RunLocation)
+	}
+}
+
+// scrubProperties scrubs the values of props, except the level and times the
+// hook adds.
+func (hook *AppInsightsHook) scrubProperties(props map[string]string) {
+	if hook.pii == 0 && !hook.secrets {
+		return
+	}
+	for k, v := range props {
+		switch k {
+		case "source_level", "source_timestamp", TimeBucketKey:
+			continue
+		}
+		props[k] = hook.scrub(v)
+	}
+}
@@ -0,0 +1,132 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubPII(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		input    string
+		kinds    PII
+		expected string
+	}{
+		{"contact jane.doe+test@example.co.uk now", AllPII, "contact [email] now"},
+		{"contact jane@example.com", PhoneNumbers, "contact jane@example.com"},
+		{"card 4111 1111 1111 1111 declined", AllPII, "card [card] declined"},
+		{"card 4111-1111-1111-1111", CardNumbers, "card [card]"},
+		{"order 4111111111111112 failed", CardNumbers, "order 4111111111111112 failed"},
+		{"from 192.168.0.1 and ::1", AllPII, "from [ip] and [ip]"},
+		{"from 2001:db8::ff00:42:8329", IPAddresses, "from [ip]"},
+		{"version 1.2.3.4567", IPAddresses, "version 1.2.3.4567"},
+		{"at 03:04:05", IPAddresses, "at 03:04:05"},
+		{"call +44 20 7946 0958 or (555) 123-4567", AllPII, "call [phone] or [phone]"},
+		{"id 1600000000123", PhoneNumbers, "id 1600000000123"},
+		{"on 2020-01-02 03:04:05", AllPII, "on 2020-01-02 03:04:05"},
+		{"nothing here", AllPII, "nothing here"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Equal(tt.expected, ScrubPII(tt.input, tt.kinds), target)
+	}
+}
+
+func TestSetPIIScrubbing(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.SetPIIScrubbing(AllPII)
+	entry := logrus.NewEntry(logrus.New()).WithField("user", "jane@example.com")
+	entry.Message = "signed in from 10.0.0.1"
+	entry.Time = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	items, err := hook.NewPipeline().buildItems(entry)
+	assert.NoError(err)
+	props := items[0].GetProperties()
+	assert.Equal("[email]", props["user"])
+	assert.Equal("signed in from [ip]", props["message"])
+	assert.Equal(entry.Time.String(), props["source_timestamp"])
+	assert.Equal("signed in from 10.0.0.1", entry.Message)
+}
+
+func TestPIIScrubbingException(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.SetExceptionsEnabled(true)
+	hook.SetPIIScrubbing(AllPII)
+	entry := logrus.NewEntry(logrus.New()).WithError(errors.New("login failed for jane@example.com"))
+	entry.Level = logrus.ErrorLevel
+
+	item, err := hook.buildItem(entry)
+	assert.NoError(err)
+	data := item.TelemetryData().(*contracts.ExceptionData)
+	assert.Equal("login failed for [email]", data.Exceptions[0].Message)
+	assert.Equal("*errors.errorString", data.Exceptions[0].TypeName)
+	assert.Equal("login failed for [email]", item.GetProperties()[logrus.ErrorKey])
+	assert.True(isUrgent(item))
+}
+
+func TestPIIScrubbingItemFields(t *testing.T) {
+	assert := assert.New(t)
+
+	const url = "https://contoso.com/reset?email=jane@example.com&sig=abcdefghijklmnop1234"
+	const scrubbed = "https://contoso.com/reset?email=[email]&[REDACTED]"
+
+	tests := []struct {
+		fields   logrus.Fields
+		field    func(items []appinsights.Telemetry) string
+		expected string
+	}{
+		{
+			logrus.Fields{HTTPMethodKey: "GET", HTTPURLKey: url},
+			func(items []appinsights.Telemetry) string { return items[1].(*appinsights.RequestTelemetry).Url },
+			scrubbed,
+		},
+		{
+			logrus.Fields{PageNameKey: "reset", PageURLKey: url},
+			func(items []appinsights.Telemetry) string { return items[0].(*appinsights.PageViewTelemetry).Url },
+			scrubbed,
+		},
+		{
+			logrus.Fields{DependencyTypeKey: "HTTP", DependencyTargetKey: "jane@example.com"},
+			func(items []appinsights.Telemetry) string {
+				return items[0].(*appinsights.RemoteDependencyTelemetry).Target
+			},
+			"[email]",
+		},
+		{
+			logrus.Fields{"user": "jane@example.com"},
+			func(items []appinsights.Telemetry) string {
+				return contracts.ContextTags(items[0].ContextTags()).User().GetId()
+			},
+			"[email]",
+		},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetRequestsEnabled(true)
+		hook.SetPIIScrubbing(AllPII)
+		hook.SetSecretScanning(true)
+		hook.MapFieldToTag("user", contracts.UserId)
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		assert.Equal(tt.expected, tt.field(items), target)
+	}
+}
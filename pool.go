@@ -0,0 +1,66 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// Compressors and buffers are pooled across batches, each allocating
+// hundreds of kilobytes.
+var (
+	// gzipWriters holds a pool per compression level, from gzip.HuffmanOnly.
+	gzipWriters   [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+	gzipReaders   sync.Pool
+	scanBuffers   = sync.Pool{New: func() interface{} { b := make([]byte, 64*1024); return &b }}
+	streamBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+)
+
+// getGzipWriter returns a pooled gzip writer compressing at level to w.
+func getGzipWriter(w io.Writer, level int) *gzip.Writer {
+	if gzipWriter, ok := gzipWriters[level-gzip.HuffmanOnly].Get().(*gzip.Writer); ok {
+		gzipWriter.Reset(w)
+		return gzipWriter
+	}
+	gzipWriter, _ := gzip.NewWriterLevel(w, level) // level is validated by SetCompressionLevel
+	return gzipWriter
+}
+
+// putGzipWriter returns a closed gzip writer compressing at level to the pool.
+func putGzipWriter(gzipWriter *gzip.Writer, level int) {
+	gzipWriter.Reset(nil)
+	gzipWriters[level-gzip.HuffmanOnly].Put(gzipWriter)
+}
+
+// getGzipReader returns a pooled gzip reader reading from r.
+func getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	if gzipReader, ok := gzipReaders.Get().(*gzip.Reader); ok {
+		if err := gzipReader.Reset(r); err != nil {
+			gzipReaders.Put(gzipReader)
+			return nil, err
+		}
+		return gzipReader, nil
+	}
+	return gzip.NewReader(r)
+}
+
+// putGzipReader returns a gzip reader to the pool.
+func putGzipReader(gzipReader *gzip.Reader) {
+	gzipReaders.Put(gzipReader)
+}
+
+// getStreamBuffer returns an empty pooled buffer for an uncompressed payload.
+func getStreamBuffer() *bytes.Buffer {
+	stream := streamBuffers.Get().(*bytes.Buffer)
+	stream.Reset()
+	return stream
+}
+
+// putStreamBuffer returns a buffer to the pool, unless it grew too large to
+// keep around.
+func putStreamBuffer(stream *bytes.Buffer) {
+	if stream.Cap() <= 4*1024*1024 {
+		streamBuffers.Put(stream)
+	}
+}
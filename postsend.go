@@ -0,0 +1,26 @@
+package logrus_appinsights
+
+import "github.com/microsoft/ApplicationInsights-Go/appinsights"
+
+// PostSendHook is called with every item the hook submits and its
+// disposition: Sent once handed over to the client, or delivered when sent
+// straight to the ingestion endpoint, Spilled if kept offline instead, or
+// Dropped with the error that prevented its delivery.
+type PostSendHook func(item appinsights.Telemetry, disposition Disposition, err error)
+
+// AddPostSendHooks adds hooks called in order after items are submitted, e.g.
+// to keep audit trails or count items per tenant. Hooks may be called
+// concurrently when the hook is asynchronous, and must not modify the item.
+func (hook *AppInsightsHook) AddPostSendHooks(hooks ...PostSendHook) {
+	hook.postSend = append(hook.postSend, hooks...)
+}
+
+// submitted calls the post-send hooks with items sent with err.
+func (hook *AppInsightsHook) submitted(items []appinsights.Telemetry, err error) {
+	disposition := sentDisposition(err)
+	for _, item := range items {
+		for _, fn := range hook.postSend {
+			fn(item, disposition, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type submission struct {
+	item        appinsights.Telemetry
+	disposition Disposition
+	err         error
+}
+
+func TestAddPostSendHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingClient()
+	hook := AppInsightsHook{client: client}
+	hook.AddMetricMapping("queue_depth", "QueueDepth")
+	var submitted []submission
+	hook.AddPostSendHooks(func(item appinsights.Telemetry, disposition Disposition, err error) {
+		submitted = append(submitted, submission{item, disposition, err})
+	})
+	assert.NoError(hook.NewPipeline().Fire(logrus.NewEntry(logrus.New()).WithField("queue_depth", 1)))
+	assert.Len(submitted, 2)
+	for i, s := range submitted {
+		assert.Equal(client.tracked()[i], s.item)
+		assert.Equal(Sent, s.disposition)
+		assert.NoError(s.err)
+	}
+
+	tests := []struct {
+		statusCode  int
+		disposition Disposition
+	}{
+		{http.StatusOK, Sent},
+		{http.StatusInternalServerError, Dropped},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.statusCode)
+		}))
+		hook, err := New("test", Config{InstrumentationKey: "NotEmpty", EndpointUrl: server.URL})
+		assert.NoError(err, target)
+		var submitted []submission
+		hook.AddPostSendHooks(func(item appinsights.Telemetry, disposition Disposition, err error) {
+			submitted = append(submitted, submission{item, disposition, err})
+		})
+		err = hook.FireAndWait(logrus.NewEntry(logrus.New()))
+		server.Close()
+
+		if assert.Len(submitted, 1, target) {
+			assert.Equal(tt.disposition, submitted[0].disposition, target)
+			assert.Equal(err, submitted[0].err, target)
+		}
+	}
+}
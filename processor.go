@@ -0,0 +1,41 @@
+package logrus_appinsights
+
+import (
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// Processor processes an item built for entry before it is tracked, such as
+// a *appinsights.TraceTelemetry. It may modify the item, and returns false to
+// drop it.
+type Processor func(item appinsights.Telemetry, entry *logrus.Entry) bool
+
+// Use adds processors run in order on every item the hook builds, including
+// the requests and metrics derived from entries, e.g. to sample, enrich or
+// redact items. Items dropped by a processor are not passed to the next ones.
+func (hook *AppInsightsHook) Use(processors ...Processor) {
+	hook.processors = append(hook.processors, processors...)
+}
+
+// process returns the items kept by the processors of the hook.
+func (hook *AppInsightsHook) process(entry *logrus.Entry, items []appinsights.Telemetry) []appinsights.Telemetry {
+	if len(hook.processors) == 0 {
+		return items
+	}
+	kept := items[:0]
+	for _, item := range items {
+		if hook.keep(item, entry) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func (hook *AppInsightsHook) keep(item appinsights.Telemetry, entry *logrus.Entry) bool {
+	for _, processor := range hook.processors {
+		if !processor(item, entry) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUse(t *testing.T) {
+	assert := assert.New(t)
+
+	enrich := func(item appinsights.Telemetry, entry *logrus.Entry) bool {
+		if trace, ok := item.(*appinsights.TraceTelemetry); ok {
+			trace.Properties["enriched"] = "true"
+		}
+		return true
+	}
+	dropNoise := func(item appinsights.Telemetry, entry *logrus.Entry) bool {
+		trace, ok := item.(*appinsights.TraceTelemetry)
+		return !ok || trace.Message != "noise"
+	}
+	dropMetrics := func(item appinsights.Telemetry, entry *logrus.Entry) bool {
+		_, ok := item.(*appinsights.MetricTelemetry)
+		return !ok
+	}
+	tests := []struct {
+		processors []Processor
+		message    string
+		expected   int
+		enriched   bool
+	}{
+		{nil, "noise", 2, false},
+		{[]Processor{enrich}, "hello", 2, true},
+		{[]Processor{dropNoise, enrich}, "noise", 1, false},
+		{[]Processor{enrich, dropMetrics}, "hello", 1, true},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		client := newRecordingClient()
+		hook := AppInsightsHook{client: client}
+		hook.AddMetricMapping("queue_depth", "QueueDepth")
+		hook.Use(tt.processors...)
+		entry := logrus.NewEntry(logrus.New()).WithField("queue_depth", 1)
+		entry.Message = tt.message
+		assert.NoError(hook.NewPipeline().Fire(entry), target)
+
+		tracked := client.tracked()
+		assert.Len(tracked, tt.expected, target)
+		trace, ok := tracked[0].(*appinsights.TraceTelemetry)
+		assert.Equal(tt.enriched, ok && trace.Properties["enriched"] == "true", target)
+	}
+}
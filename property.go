@@ -0,0 +1,46 @@
+package logrus_appinsights
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AddGlobalProperty sends the property with every item, e.g. the environment,
+// region or version of a deployment. Fields of the same name take precedence.
+func (hook *AppInsightsHook) AddGlobalProperty(key, value string) {
+	for _, ctx := range hook.contexts() {
+		ctx.CommonProperties[key] = value
+	}
+}
+
+// AddEnvironmentProperties sends the current values of the environment
+// variables names with every item, under their name, e.g. the region or ring
+// of a deployment. Unset variables are not sent.
+func (hook *AppInsightsHook) AddEnvironmentProperties(names ...string) {
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok {
+			hook.AddGlobalProperty(name, v)
+		}
+	}
+}
+
+// AddPropertyProvider registers a function returning properties of entries
+// that are not in their fields, such as the goroutine count or memory stats,
+// evaluated for every entry as it is fired. Fields take precedence over
+// provided properties, and earlier providers over later ones.
+func (hook *AppInsightsHook) AddPropertyProvider(provider func(entry *logrus.Entry) map[string]string) {
+	hook.providers = append(hook.providers, provider)
+}
+
+// addProvidedProperties adds the properties provided for entry that are not
+// already set.
+func (hook *AppInsightsHook) addProvidedProperties(props map[string]string, entry *logrus.Entry) {
+	for _, provider := range hook.providers {
+		for k, v := range provider(entry) {
+			if _, ok := props[k]; !ok {
+				hook.addProperty(props, k, v)
+			}
+		}
+	}
+}
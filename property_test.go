@@ -0,0 +1,100 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddGlobalProperty(t *testing.T) {
+	assert := assert.New(t)
+
+	var received jsonPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := gzip.NewReader(r.Body)
+		if err == nil {
+			buffer := new(bytes.Buffer)
+			buffer.ReadFrom(reader)
+			received, _ = parsePayload(buffer.Bytes())
+		}
+	}))
+	defer server.Close()
+
+	hook, err := New("test", Config{
+		InstrumentationKey: "NotEmpty",
+		EndpointUrl:        server.URL,
+		DefaultProperties:  map[string]string{"environment": "production", "region": "westeurope"},
+	})
+	assert.NoError(err)
+	hook.AddGlobalProperty("version", "1.2.3")
+
+	entry := logrus.NewEntry(logrus.New()).WithField("region", "eastus")
+	entry.Level = logrus.InfoLevel
+	assert.NoError(hook.FireAndWait(entry))
+	if assert.Len(received, 1) {
+		assert.NoError(received[0].assertPath("data.baseData.properties.environment", "production"))
+		assert.NoError(received[0].assertPath("data.baseData.properties.version", "1.2.3"))
+		assert.NoError(received[0].assertPath("data.baseData.properties.region", "eastus"))
+	}
+}
+
+func TestAddEnvironmentProperties(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Setenv("TEST_DEPLOY_RING", "canary")
+	defer os.Unsetenv("TEST_DEPLOY_RING")
+	os.Unsetenv("TEST_UNSET")
+
+	hook, err := New("test", Config{
+		InstrumentationKey:    "NotEmpty",
+		EnvironmentProperties: []string{"TEST_DEPLOY_RING", "TEST_UNSET"},
+	})
+	assert.NoError(err)
+	// values are snapshotted
+	os.Setenv("TEST_DEPLOY_RING", "stable")
+	props := hook.client.Context().CommonProperties
+	assert.Equal("canary", props["TEST_DEPLOY_RING"])
+	assert.NotContains(props, "TEST_UNSET")
+
+	hook.AddEnvironmentProperties("TEST_DEPLOY_RING")
+	assert.Equal("stable", props["TEST_DEPLOY_RING"])
+}
+
+func TestAddPropertyProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields   logrus.Fields
+		expected map[string]string
+	}{
+		{nil, map[string]string{"goroutines": "12", "level_upper": "INFO", "region": "westeurope"}},
+		{logrus.Fields{"goroutines": 3}, map[string]string{"goroutines": "3", "level_upper": "INFO", "region": "westeurope"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.AddPropertyProvider(func(entry *logrus.Entry) map[string]string {
+			return map[string]string{"goroutines": "12", "level_upper": strings.ToUpper(entry.Level.String())}
+		})
+		hook.AddPropertyProvider(func(entry *logrus.Entry) map[string]string {
+			return map[string]string{"goroutines": "0", "region": "westeurope"}
+		})
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+		entry.Level = logrus.InfoLevel
+
+		props := hook.buildProperties(entry)
+		for k, v := range tt.expected {
+			assert.Equal(v, props[k], target)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReceiptKey is the reserved field holding the *Receipt settled with the
+// disposition of an entry, for the few asynchronously fired entries whose
+// fate the caller must know, e.g.
+//
+//	receipt := NewReceipt()
+//	log.WithField(ReceiptKey, receipt).Error("payment failed")
+//	disposition, err := receipt.Wait(ctx)
+const ReceiptKey = "ai_receipt"
+
+// Disposition is what became of a fired entry.
+type Disposition int
+
+const (
+	// Pending entries have not been handed over to the client yet.
+	Pending Disposition = iota
+	// Sent entries were handed over to the client, which batches them to
+	// Application Insights, or delivered straight away when sent
+	// synchronously within the time budget of NewCLI.
+	Sent
+	// Dropped entries were discarded by the overflow policy, sampling or the
+	// volume budget, or could not be sent.
+	Dropped
+	// Spilled entries could not be delivered straight away and were kept
+	// offline, to be resubmitted once the endpoint is reachable again.
+	Spilled
+	// Discarded entries were dropped by a processor.
+	Discarded
+	// Suppressed entries were over the budget of their operation, and are
+	// only counted in its summary.
+	Suppressed
+)
+
+// Receipt is settled once with the disposition of the entry carrying it
+// under ReceiptKey.
+type Receipt struct {
+	once        sync.Once
+	done        chan struct{}
+	disposition Disposition
+	err         error
+}
+
+// NewReceipt returns a pending receipt.
+func NewReceipt() *Receipt {
+	return &Receipt{done: make(chan struct{})}
+}
+
+// Done returns a channel closed once the receipt is settled.
+func (r *Receipt) Done() <-chan struct{} {
+	return r.done
+}
+
+// Disposition returns the disposition of the entry, Pending until the
+// receipt is settled, and the error that made it be dropped, if any.
+func (r *Receipt) Disposition() (Disposition, error) {
+	select {
+	case <-r.done:
+		return r.disposition, r.err
+	default:
+		return Pending, nil
+	}
+}
+
+// Wait waits until the receipt is settled or ctx is done, returning the
+// disposition of the entry or the error of ctx.
+func (r *Receipt) Wait(ctx context.Context) (Disposition, error) {
+	select {
+	case <-r.done:
+		return r.disposition, r.err
+	case <-ctx.Done():
+		return Pending, ctx.Err()
+	}
+}
+
+// receiptOf returns the receipt entry carries, if any.
+func receiptOf(entry *logrus.Entry) *Receipt {
+	r, _ := entry.Data[ReceiptKey].(*Receipt)
+	return r
+}
+
+// settle settles r, if any, keeping the first disposition when several hooks
+// fire the entry carrying it.
+func (r *Receipt) settle(disposition Disposition, err error) {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		r.disposition, r.err = disposition, err
+		close(r.done)
+	})
+}
+
+// settleSent settles r with the disposition of an entry sent with err, and
+// returns err.
+func (r *Receipt) settleSent(err error) error {
+	r.settle(sentDisposition(err), err)
+	return err
+}
+
+// sentDisposition returns the disposition of items sent with err: sent,
+// spilled if kept offline, or dropped.
+func sentDisposition(err error) Disposition {
+	switch err {
+	case nil:
+		return Sent
+	case ErrStoredOffline:
+		return Spilled
+	default:
+		return Dropped
+	}
+}
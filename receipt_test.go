@@ -0,0 +1,130 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		async       bool
+		exhausted   bool
+		disposition Disposition
+	}{
+		{false, false, Sent},
+		{true, false, Sent},
+		{true, true, Dropped},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook, err := New("test", Config{InstrumentationKey: "key"})
+		assert.NoError(err, target)
+		hook.SetAsync(tt.async)
+		if tt.exhausted {
+			hook.SetMaxPendingBytes(1)
+			hook.pending.acquire(1, false)
+		}
+		receipt := NewReceipt()
+		d, err := receipt.Disposition()
+		assert.Equal(Pending, d, target)
+		assert.NoError(err, target)
+
+		entry := logrus.NewEntry(logrus.New()).WithField(ReceiptKey, receipt)
+		entry.Message = "payment failed"
+		entry.Level = logrus.ErrorLevel
+		assert.NoError(hook.Fire(entry), target)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		d, err = receipt.Wait(ctx)
+		cancel()
+		assert.NoError(err, target)
+		assert.Equal(tt.disposition, d, target)
+		d, _ = receipt.Disposition()
+		assert.Equal(tt.disposition, d, target)
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		assert.NotContains(items[0].GetProperties(), ReceiptKey, target)
+	}
+}
+
+func TestReceiptWaitCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	receipt := NewReceipt()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d, err := receipt.Wait(ctx)
+	assert.Equal(Pending, d)
+	assert.Equal(context.Canceled, err)
+
+	// the first disposition is kept
+	receipt.settle(Dropped, nil)
+	receipt.settle(Sent, nil)
+	d, _ = receipt.Disposition()
+	assert.Equal(Dropped, d)
+	<-receipt.Done()
+}
+
+func TestReceiptDispositions(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		disposition Disposition
+	}{
+		{"discarded", Discarded},
+		{"suppressed", Suppressed},
+		{"spilled", Spilled},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook, err := New("test", Config{InstrumentationKey: "key", EndpointUrl: server.URL, OfflineStore: NewMemoryStore(1 << 20)})
+		assert.NoError(err, target)
+		var disposition Disposition
+		hook.AddPostSendHooks(func(item appinsights.Telemetry, d Disposition, err error) {
+			disposition = d
+		})
+		ctx := WithOperation(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "")
+		entry := logrus.NewEntry(logrus.New()).WithContext(ctx)
+		entry.Level = logrus.InfoLevel
+		switch tt.disposition {
+		case Discarded:
+			hook.Use(func(appinsights.Telemetry, *logrus.Entry) bool { return false })
+		case Suppressed:
+			hook.SetOperationBudget(1, 0)
+			assert.NoError(hook.Fire(entry), target)
+		}
+
+		receipt := NewReceipt()
+		entry = entry.WithField(ReceiptKey, receipt)
+		if tt.disposition == Spilled {
+			assert.Equal(ErrStoredOffline, hook.FireAndWait(entry), target)
+			assert.Equal(Spilled, disposition, target)
+		} else {
+			assert.NoError(hook.Fire(entry), target)
+		}
+		d, _ := receipt.Disposition()
+		assert.Equal(tt.disposition, d, target)
+		hook.Close()
+	}
+}
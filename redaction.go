@@ -0,0 +1,130 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+)
+
+// RedactionSummaryMessage is the message of the trace periodically reporting
+// the values redacted by each rule.
+const RedactionSummaryMessage = "logrus_appinsights.redactions"
+
+// redactions counts the values modified or dropped by each filtering or
+// scrubbing rule, named filter.<field>, global_filter, pii.<kind> or
+// secret.<kind>.
+type redactions struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// redactionCounts returns the counter of the hook, shared with its pipelines.
+func (hook *AppInsightsHook) redactionCounts() *redactions {
+	if hook.redactions == nil {
+		hook.redactions = &redactions{counts: make(map[string]uint64)}
+	}
+	return hook.redactions
+}
+
+func (r *redactions) add(rule string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.counts[rule]++
+	r.mu.Unlock()
+}
+
+// changed counts rule if filtering changed the value before into after.
+func (r *redactions) changed(rule string, before, after interface{}) {
+	if r != nil && fmt.Sprint(formatData(before)) != fmt.Sprint(formatData(after)) {
+		r.add(rule)
+	}
+}
+
+// snapshot returns the counts by rule, or nil if none.
+func (r *redactions) snapshot() map[string]uint64 {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.counts) == 0 {
+		return nil
+	}
+	counts := make(map[string]uint64, len(r.counts))
+	for rule, n := range r.counts {
+		counts[rule] = n
+	}
+	return counts
+}
+
+// SetRedactionReportInterval sets the hook to send a trace with the message
+// RedactionSummaryMessage at every interval, holding the number of values
+// each rule redacted during the interval, if any, so redaction can be
+// verified in production. A zero interval stops reporting.
+func (hook *AppInsightsHook) SetRedactionReportInterval(interval time.Duration) {
+	if hook.auditReporter != nil {
+		close(hook.auditReporter)
+		hook.auditReporter = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	hook.auditReporter = make(chan struct{})
+	go hook.reportRedactions(interval, hook.redactionCounts(), hook.auditReporter)
+}
+
+func (hook *AppInsightsHook) reportRedactions(interval time.Duration, r *redactions, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var reported map[string]uint64
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			counts := r.snapshot()
+			if trace := redactionSummary(counts, reported); trace != nil {
+				hook.track(trace)
+			}
+			reported = counts
+		}
+	}
+}
+
+// redactionSummary returns the trace reporting the redactions counted since
+// the previous counts, or nil if none.
+func redactionSummary(counts, previous map[string]uint64) *appinsights.TraceTelemetry {
+	var rules []string
+	for rule, n := range counts {
+		if n > previous[rule] {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	sort.Strings(rules)
+	trace := appinsights.NewTraceTelemetry(RedactionSummaryMessage, appinsights.Information)
+	for _, rule := range rules {
+		trace.Properties[rule] = strconv.FormatUint(counts[rule]-previous[rule], 10)
+	}
+	return trace
+}
+
+// secretsRedacted returns the number of secrets redacted so far.
+func secretsRedacted(counts map[string]uint64) uint64 {
+	var n uint64
+	for rule, count := range counts {
+		if strings.HasPrefix(rule, "secret.") {
+			n += count
+		}
+	}
+	return n
+}
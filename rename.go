@@ -0,0 +1,41 @@
+package logrus_appinsights
+
+import "github.com/sirupsen/logrus"
+
+// RenameField sends the field from as to, e.g. the err, msg or lvl fields of
+// shared libraries under the names of an organisation's schema. Settings of
+// the hook apply to the field under its new name, and a field already named
+// to takes precedence.
+func (hook *AppInsightsHook) RenameField(from, to string) {
+	if hook.renames == nil {
+		hook.renames = make(map[string]string)
+	}
+	hook.renames[from] = to
+}
+
+// renamedEntry returns entry with its fields renamed, copying it if any is.
+func (hook *AppInsightsHook) renamedEntry(entry *logrus.Entry) *logrus.Entry {
+	var data logrus.Fields
+	for from, to := range hook.renames {
+		v, ok := entry.Data[from]
+		if !ok {
+			continue
+		}
+		if data == nil {
+			data = make(logrus.Fields, len(entry.Data))
+			for k, v := range entry.Data {
+				data[k] = v
+			}
+		}
+		delete(data, from)
+		if _, ok := entry.Data[to]; !ok {
+			data[to] = v
+		}
+	}
+	if data == nil {
+		return entry
+	}
+	renamed := *entry
+	renamed.Data = data
+	return &renamed
+}
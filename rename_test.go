@@ -0,0 +1,61 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameField(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields   logrus.Fields
+		expected map[string]string
+		absent   []string
+	}{
+		{logrus.Fields{"lvl": "warn", "user": "jane"}, map[string]string{"severity": "warn", "user": "jane"}, []string{"lvl"}},
+		{logrus.Fields{"lvl": "warn", "severity": "error"}, map[string]string{"severity": "error"}, []string{"lvl"}},
+		{logrus.Fields{"msg": "hello"}, map[string]string{"text": "HELLO"}, []string{"msg"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{filters: map[string]func(interface{}) interface{}{}}
+		hook.RenameField("lvl", "severity")
+		hook.RenameField("msg", "text")
+		hook.AddFilter("text", func(v interface{}) interface{} { return "HELLO" })
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+
+		items, err := hook.NewPipeline().buildItems(entry)
+		assert.NoError(err, target)
+		props := items[0].GetProperties()
+		for k, v := range tt.expected {
+			assert.Equal(v, props[k], target)
+		}
+		for _, k := range tt.absent {
+			assert.NotContains(props, k, target)
+		}
+		// the entry is left unchanged
+		assert.Equal(tt.fields, entry.Data, target)
+	}
+}
+
+func TestRenameFieldToErrorKey(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.SetExceptionsEnabled(true)
+	hook.RenameField("err", logrus.ErrorKey)
+	entry := logrus.NewEntry(logrus.New()).WithField("err", errors.New("failed"))
+	entry.Level = logrus.ErrorLevel
+
+	items, err := hook.buildItems(entry)
+	assert.NoError(err)
+	assert.IsType(&appinsights.ExceptionTelemetry{}, items[0])
+}
@@ -0,0 +1,76 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+)
+
+// Conventional fields describing an HTTP request served by the application.
+const (
+	HTTPMethodKey     = "http.method"
+	HTTPURLKey        = "http.url"
+	HTTPStatusCodeKey = "http.status_code"
+	DurationKey       = "duration"
+)
+
+// SetRequestsEnabled sets whether entries carrying the HTTPMethodKey and
+// HTTPURLKey fields are also sent as request telemetry, correlated with the
+// entry's own item, so they show in the Performance and Failures blades.
+func (hook *AppInsightsHook) SetRequestsEnabled(enabled bool) {
+	hook.requests = enabled
+}
+
+func isRequestEntry(entry *logrus.Entry) bool {
+	_, hasMethod := entry.Data[HTTPMethodKey]
+	_, hasURL := entry.Data[HTTPURLKey]
+	return hasMethod && hasURL
+}
+
+// buildRequest returns the request telemetry for entry and makes item part of
+// the request's operation.
+func (hook *AppInsightsHook) buildRequest(entry *logrus.Entry, item appinsights.Telemetry) *appinsights.RequestTelemetry {
+	method := fmt.Sprintf("%v", entry.Data[HTTPMethodKey])
+	url := fmt.Sprintf("%v", entry.Data[HTTPURLKey])
+	code := ""
+	if v, ok := entry.Data[HTTPStatusCodeKey]; ok {
+		code = fmt.Sprintf("%v", v)
+	}
+	duration, _ := toDuration(entry.Data[DurationKey])
+
+	request := appinsights.NewRequestTelemetry(method, url, duration, code)
+	request.Timestamp = hook.entryTime(entry).Add(-duration)
+	if id, ok := entry.Data[RequestIDKey]; ok {
+		request.Id = fmt.Sprintf("%v", id)
+	}
+	for k, v := range item.GetProperties() {
+		switch k {
+		case HTTPMethodKey, HTTPURLKey, HTTPStatusCodeKey, DurationKey, RequestIDKey, RequestParentIDKey:
+		default:
+			request.Properties[k] = v
+		}
+	}
+
+	// the item may already be part of an operation from the entry's context
+	tags := contracts.ContextTags(item.ContextTags()).Operation()
+	operation := tags.GetId()
+	if id, ok := entry.Data[OperationIDKey]; ok {
+		operation = fmt.Sprintf("%v", id)
+	}
+	if operation == "" {
+		operation = request.Id
+	}
+	request.Tags.Operation().SetId(operation)
+	parent := tags.GetParentId()
+	if id, ok := entry.Data[RequestParentIDKey]; ok {
+		parent = fmt.Sprintf("%v", id)
+	}
+	if parent != "" {
+		request.Tags.Operation().SetParentId(parent)
+	}
+	tags.SetId(operation)
+	tags.SetParentId(request.Id)
+	return request
+}
@@ -0,0 +1,73 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields   logrus.Fields
+		requests bool
+		expected *appinsights.RequestTelemetry
+	}{
+		{logrus.Fields{HTTPMethodKey: "GET", HTTPURLKey: "/orders"}, false, nil},
+		{logrus.Fields{HTTPMethodKey: "GET"}, true, nil},
+		{
+			logrus.Fields{HTTPMethodKey: "GET", HTTPURLKey: "/orders?page=2", HTTPStatusCodeKey: 200, DurationKey: 150},
+			true,
+			&appinsights.RequestTelemetry{Name: "GET /orders", ResponseCode: "200", Duration: 150 * time.Millisecond, Success: true},
+		},
+		{
+			logrus.Fields{HTTPMethodKey: "POST", HTTPURLKey: "/orders", HTTPStatusCodeKey: "503", DurationKey: time.Second, OperationIDKey: "op"},
+			true,
+			&appinsights.RequestTelemetry{Name: "POST /orders", ResponseCode: "503", Duration: time.Second, Success: false},
+		},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetRequestsEnabled(tt.requests)
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+		entry.Time = time.Now()
+		entry.Message = "request served"
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		if tt.expected == nil {
+			assert.Len(items, 1, target)
+			continue
+		}
+		if !assert.Len(items, 2, target) {
+			continue
+		}
+		request := items[1].(*appinsights.RequestTelemetry)
+		assert.Equal(tt.expected.Name, request.Name, target)
+		assert.Equal(tt.expected.ResponseCode, request.ResponseCode, target)
+		assert.Equal(tt.expected.Duration, request.Duration, target)
+		assert.Equal(tt.expected.Success, request.Success, target)
+		assert.Equal(entry.Time.Add(-request.Duration), request.Timestamp, target)
+		assert.Equal("request served", request.Properties["message"], target)
+		assert.NotContains(request.Properties, HTTPMethodKey, target)
+
+		// correlated with the trace
+		operation := request.Tags.Operation().GetId()
+		if id, ok := tt.fields[OperationIDKey]; ok {
+			assert.Equal(id, operation, target)
+		} else {
+			assert.Equal(request.Id, operation, target)
+		}
+		assert.Equal(operation, contracts.ContextTags(items[0].ContextTags()).Operation().GetId(), target)
+		assert.Equal(request.Id, contracts.ContextTags(items[0].ContextTags()).Operation().GetParentId(), target)
+	}
+}
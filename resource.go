@@ -0,0 +1,223 @@
+package logrus_appinsights
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+)
+
+// RoleKey and RoleInstanceKey are the resource attributes sent as the cloud
+// role and role instance tags rather than as properties.
+const (
+	RoleKey         = "cloud.role"
+	RoleInstanceKey = "cloud.role_instance"
+)
+
+// ResourceDetector detects attributes of the environment the application runs
+// in, such as the host or the platform hosting it.
+type ResourceDetector interface {
+	// Detect returns the attributes of the environment, or none if the
+	// application does not run in the environment the detector knows.
+	Detect() (map[string]string, error)
+}
+
+// ResourceDetectorFunc adapts a function to a ResourceDetector.
+type ResourceDetectorFunc func() (map[string]string, error)
+
+// Detect calls f.
+func (f ResourceDetectorFunc) Detect() (map[string]string, error) {
+	return f()
+}
+
+// AddResourceDetectors runs detectors in order and sends the attributes they
+// detect with every item, attributes of earlier detectors taking precedence.
+// The RoleKey and RoleInstanceKey attributes set the cloud role, replacing
+// the name of the hook, and role instance. The role instance and attributes
+// naming the device follow Config.DeviceTags.
+func (hook *AppInsightsHook) AddResourceDetectors(detectors ...ResourceDetector) error {
+	attributes := make(map[string]string)
+	for _, detector := range detectors {
+		detected, err := detector.Detect()
+		if err != nil {
+			return err
+		}
+		for k, v := range detected {
+			if _, ok := attributes[k]; !ok {
+				attributes[k] = v
+			}
+		}
+	}
+	for _, ctx := range hook.contexts() {
+		for k, v := range attributes {
+			if k == RoleKey {
+				ctx.Tags.Cloud().SetRole(v)
+				continue
+			}
+			if k == RoleInstanceKey {
+				if v, ok := hook.deviceTags.anonymize(v); ok {
+					ctx.Tags.Cloud().SetRoleInstance(v)
+				}
+				continue
+			}
+			if _, ok := deviceAttributes[k]; ok {
+				if v, ok = hook.deviceTags.anonymize(v); !ok {
+					continue
+				}
+			}
+			ctx.CommonProperties[k] = v
+		}
+	}
+	return nil
+}
+
+// contexts returns the contexts of every client the hook sends to.
+func (hook *AppInsightsHook) contexts() []*appinsights.TelemetryContext {
+	contexts := clientContexts(hook.client)
+	if hook.canary != nil {
+		contexts = append(contexts, hook.canary.Context())
+	}
+	return contexts
+}
+
+// clientContexts returns the contexts of client and of the secondary client
+// it fails over to, if any.
+func clientContexts(client appinsights.TelemetryClient) []*appinsights.TelemetryContext {
+	contexts := []*appinsights.TelemetryContext{client.Context()}
+	for _, client := range unwrapClients(client) {
+		if failover, ok := client.(*failoverClient); ok {
+			contexts = append(contexts, failover.secondary.Context())
+		}
+	}
+	return contexts
+}
+
+// serviceAccountNamespace holds the namespace of a Kubernetes pod.
+var serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// KubernetesDetector detects the pod, namespace and node of applications
+// running in Kubernetes. The node is only known if exposed as the NODE_NAME
+// environment variable through the downward API.
+var KubernetesDetector = ResourceDetectorFunc(func() (map[string]string, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil, nil
+	}
+	attributes := map[string]string{}
+	if pod, err := os.Hostname(); err == nil {
+		attributes["k8s.pod.name"] = pod
+		attributes[RoleInstanceKey] = pod
+	}
+	if namespace, err := ioutil.ReadFile(serviceAccountNamespace); err == nil {
+		attributes["k8s.namespace.name"] = strings.TrimSpace(string(namespace))
+	}
+	if node := os.Getenv("NODE_NAME"); node != "" {
+		attributes["k8s.node.name"] = node
+	}
+	return attributes, nil
+})
+
+// AppServiceDetector detects the site, instance, slot and region of
+// applications running in Azure App Service or Azure Functions, naming the
+// cloud role after the site as the App Service integrations of Application
+// Insights do.
+var AppServiceDetector = ResourceDetectorFunc(func() (map[string]string, error) {
+	site := os.Getenv("WEBSITE_SITE_NAME")
+	if site == "" {
+		return nil, nil
+	}
+	attributes := map[string]string{"appservice.site.name": site, RoleKey: site}
+	for key, env := range map[string]string{
+		RoleInstanceKey:               "WEBSITE_INSTANCE_ID",
+		"appservice.region":           "REGION_NAME",
+		"appservice.sku":              "WEBSITE_SKU",
+		"appservice.slot.name":        "WEBSITE_SLOT_NAME",
+		"functions.worker_runtime":    "FUNCTIONS_WORKER_RUNTIME",
+		"functions.extension_version": "FUNCTIONS_EXTENSION_VERSION",
+	} {
+		if v := os.Getenv(env); v != "" {
+			attributes[key] = v
+		}
+	}
+	return attributes, nil
+})
+
+// instanceMetadataEndpoint is the Azure Instance Metadata Service endpoint
+// describing the virtual machine.
+var instanceMetadataEndpoint = "http://169.254.169.254/metadata/instance/compute?api-version=2021-02-01"
+
+// VMDetector detects the name, location and size of applications running on
+// an Azure virtual machine, along with its scale set, subscription and
+// resource group, querying the Instance Metadata Service.
+var VMDetector = ResourceDetectorFunc(func() (map[string]string, error) {
+	req, err := http.NewRequest("GET", instanceMetadataEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		// not running on Azure
+		return nil, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	var compute struct {
+		VMID              string `json:"vmId"`
+		Name              string `json:"name"`
+		Location          string `json:"location"`
+		VMSize            string `json:"vmSize"`
+		VMScaleSetName    string `json:"vmScaleSetName"`
+		SubscriptionID    string `json:"subscriptionId"`
+		ResourceGroupName string `json:"resourceGroupName"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&compute); err != nil {
+		return nil, err
+	}
+	attributes := map[string]string{
+		RoleInstanceKey: compute.Name,
+		"vm.id":         compute.VMID,
+		"vm.name":       compute.Name,
+		"vm.location":   compute.Location,
+		"vm.size":       compute.VMSize,
+	}
+	for key, v := range map[string]string{
+		"vm.scale_set.name":     compute.VMScaleSetName,
+		"azure.subscription_id": compute.SubscriptionID,
+		"azure.resource_group":  compute.ResourceGroupName,
+	} {
+		if v != "" {
+			attributes[key] = v
+		}
+	}
+	return attributes, nil
+})
+
+// processStart approximates the time the process started, when the package
+// was initialised.
+var processStart = time.Now()
+
+// LocalDetector detects the host and process of the application, telling
+// apart instances of the same binary running on many hosts.
+var LocalDetector = ResourceDetectorFunc(func() (map[string]string, error) {
+	attributes := map[string]string{
+		"os.type":                 runtime.GOOS,
+		"host.arch":               runtime.GOARCH,
+		"process.pid":             strconv.Itoa(os.Getpid()),
+		"process.runtime.version": runtime.Version(),
+		"process.start_time":      processStart.UTC().Format(time.RFC3339),
+	}
+	if host, err := os.Hostname(); err == nil {
+		attributes["host.name"] = host
+		attributes[RoleInstanceKey] = host
+	}
+	return attributes, nil
+})
@@ -0,0 +1,140 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddResourceDetectors(t *testing.T) {
+	assert := assert.New(t)
+
+	nomad := ResourceDetectorFunc(func() (map[string]string, error) {
+		return map[string]string{"nomad.alloc.id": "alloc", RoleKey: "web", RoleInstanceKey: "alloc", "host.name": "nomad"}, nil
+	})
+	failing := ResourceDetectorFunc(func() (map[string]string, error) {
+		return nil, errors.New("detection failed")
+	})
+
+	hook, err := New("test", Config{InstrumentationKey: "NotEmpty"})
+	assert.NoError(err)
+	assert.Error(hook.AddResourceDetectors(failing))
+
+	assert.NoError(hook.AddResourceDetectors(nomad, LocalDetector))
+	ctx := hook.client.Context()
+	assert.Equal("web", ctx.Tags.Cloud().GetRole())
+	assert.Equal("alloc", ctx.Tags.Cloud().GetRoleInstance())
+	assert.Equal("alloc", ctx.CommonProperties["nomad.alloc.id"])
+	assert.Equal("nomad", ctx.CommonProperties["host.name"])
+	assert.Equal(fmt.Sprint(os.Getpid()), ctx.CommonProperties["process.pid"])
+	assert.Equal(runtime.GOARCH, ctx.CommonProperties["host.arch"])
+	assert.Equal(runtime.Version(), ctx.CommonProperties["process.runtime.version"])
+	assert.NotEmpty(ctx.CommonProperties["process.start_time"])
+	assert.NotContains(ctx.CommonProperties, RoleKey)
+	assert.NotContains(ctx.CommonProperties, RoleInstanceKey)
+}
+
+func TestBuiltinDetectors(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name     string
+		detector ResourceDetector
+		env      map[string]string
+		expected map[string]string
+	}{
+		{"kubernetes", KubernetesDetector, map[string]string{"KUBERNETES_SERVICE_HOST": ""}, nil},
+		{"kubernetes", KubernetesDetector, map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "NODE_NAME": "node-1"}, map[string]string{"k8s.node.name": "node-1"}},
+		{"appservice", AppServiceDetector, map[string]string{"WEBSITE_SITE_NAME": ""}, nil},
+		{"appservice", AppServiceDetector, map[string]string{"WEBSITE_SITE_NAME": "site", "WEBSITE_INSTANCE_ID": "abc", "REGION_NAME": "West Europe"}, map[string]string{"appservice.site.name": "site", RoleKey: "site", RoleInstanceKey: "abc", "appservice.region": "West Europe"}},
+		{"functions", AppServiceDetector, map[string]string{"WEBSITE_SITE_NAME": "func", "WEBSITE_SLOT_NAME": "staging", "FUNCTIONS_WORKER_RUNTIME": "custom"}, map[string]string{RoleKey: "func", "appservice.slot.name": "staging", "functions.worker_runtime": "custom"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		for k, v := range tt.env {
+			previous, ok := os.LookupEnv(k)
+			os.Setenv(k, v)
+			if ok {
+				defer os.Setenv(k, previous)
+			} else {
+				defer os.Unsetenv(k)
+			}
+		}
+		attributes, err := tt.detector.Detect()
+		assert.NoError(err, target)
+		if tt.expected == nil {
+			assert.Empty(attributes, target)
+		}
+		for k, v := range tt.expected {
+			assert.Equal(v, attributes[k], target)
+		}
+	}
+}
+
+func TestVMDetector(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"vmId":"id","name":"vm-1","location":"westeurope","vmSize":"Standard_D2s_v3",` +
+			`"vmScaleSetName":"","subscriptionId":"sub","resourceGroupName":"rg"}`))
+	}))
+	defer server.Close()
+
+	previous := instanceMetadataEndpoint
+	defer func() { instanceMetadataEndpoint = previous }()
+	instanceMetadataEndpoint = server.URL
+
+	attributes, err := VMDetector.Detect()
+	assert.NoError(err)
+	assert.Equal("vm-1", attributes[RoleInstanceKey])
+	assert.Equal("westeurope", attributes["vm.location"])
+	assert.Equal("Standard_D2s_v3", attributes["vm.size"])
+	assert.Equal("sub", attributes["azure.subscription_id"])
+	assert.Equal("rg", attributes["azure.resource_group"])
+	assert.NotContains(attributes, "vm.scale_set.name")
+
+	hook, err := New("test", Config{InstrumentationKey: "key", ResourceDetectors: []ResourceDetector{VMDetector}})
+	assert.NoError(err)
+	assert.Equal("vm-1", hook.client.Context().Tags.Cloud().GetRoleInstance())
+	assert.Equal("westeurope", hook.client.Context().CommonProperties["vm.location"])
+	hook.Close()
+}
+
+func TestAppServiceDetectedByDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		site     string
+		role     string
+		expected string
+	}{
+		{"", "", "test"},
+		{"orders-site", "", "orders-site"},
+		{"orders-site", "orders", "orders"},
+	}
+
+	defer os.Unsetenv("WEBSITE_SITE_NAME")
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		os.Setenv("WEBSITE_SITE_NAME", tt.site)
+		hook, err := New("test", Config{InstrumentationKey: "key", CloudRole: tt.role})
+		if !assert.NoError(err, target) {
+			continue
+		}
+		assert.Equal(tt.expected, hook.client.Context().Tags.Cloud().GetRole(), target)
+		hook.Close()
+	}
+}
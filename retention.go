@@ -0,0 +1,36 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetentionKey is the reserved field holding a retention hint such as "30d"
+// or "2y", sent as a dimension of the same name for export and purge
+// automation to apply differentiated retention.
+const RetentionKey = "retention"
+
+// retentionHint matches a number of days, weeks, months or years.
+var retentionHint = regexp.MustCompile(`^[1-9][0-9]*[dwmy]$`)
+
+// SetDefaultRetention sets the retention hint of entries without one. Hints
+// must be a number of days, weeks, months or years such as "30d" or "2y".
+func (hook *AppInsightsHook) SetDefaultRetention(hint string) error {
+	if hint != "" && !retentionHint.MatchString(hint) {
+		return fmt.Errorf("Invalid retention hint %q", hint)
+	}
+	hook.retention = hint
+	return nil
+}
+
+// entryRetention returns the retention hint of entry, ignoring invalid hints.
+func (hook *AppInsightsHook) entryRetention(entry *logrus.Entry) string {
+	if v, ok := entry.Data[RetentionKey]; ok {
+		if hint := fmt.Sprintf("%v", v); retentionHint.MatchString(hint) {
+			return hint
+		}
+	}
+	return hook.retention
+}
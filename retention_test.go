@@ -0,0 +1,48 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetention(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		defaultHint string
+		value       interface{}
+		expected    string
+	}{
+		{"", nil, ""},
+		{"", "30d", "30d"},
+		{"", "2y", "2y"},
+		{"", "forever", ""},
+		{"", "0d", ""},
+		{"90d", nil, "90d"},
+		{"90d", "7y", "7y"},
+		{"90d", "1 year", "90d"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{ignoreFields: map[string]struct{}{RetentionKey: {}}}
+		assert.NoError(hook.SetDefaultRetention(tt.defaultHint), target)
+		entry := logrus.NewEntry(logrus.New())
+		if tt.value != nil {
+			entry = entry.WithField(RetentionKey, tt.value)
+		}
+		props := hook.buildProperties(entry)
+		if tt.expected == "" {
+			assert.NotContains(props, RetentionKey, target)
+		} else {
+			assert.Equal(tt.expected, props[RetentionKey], target)
+		}
+	}
+
+	hook := AppInsightsHook{}
+	assert.Error(hook.SetDefaultRetention("a while"))
+}
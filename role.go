@@ -0,0 +1,17 @@
+package logrus_appinsights
+
+// SetCloudRole sets the cloud role tag, the name of the service in the
+// Application Map, to role instead of the name the hook was created with.
+func (hook *AppInsightsHook) SetCloudRole(role string) {
+	for _, ctx := range hook.contexts() {
+		ctx.Tags.Cloud().SetRole(role)
+	}
+}
+
+// SetCloudRoleInstance sets the cloud role instance tag, telling the
+// instances of a service apart, to instance instead of the host name.
+func (hook *AppInsightsHook) SetCloudRoleInstance(instance string) {
+	for _, ctx := range hook.contexts() {
+		ctx.Tags.Cloud().SetRoleInstance(instance)
+	}
+}
@@ -0,0 +1,43 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudRole(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		conf     Config
+		role     string
+		instance string
+	}{
+		{Config{InstrumentationKey: "NotEmpty", CloudRole: "orders", CloudRoleInstance: "orders-0"}, "orders", "orders-0"},
+		{Config{InstrumentationKey: "NotEmpty", CloudRoleInstance: "orders-0", DeviceTags: HashDeviceTags}, "test", "orders-0"},
+		{Config{InstrumentationKey: "NotEmpty", SecondaryConnectionString: "InstrumentationKey=Secondary", CloudRole: "orders"}, "orders", ""},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook, err := New("test", tt.conf)
+		assert.NoError(err, target)
+		for _, ctx := range hook.contexts() {
+			assert.Equal(tt.role, ctx.Tags[contracts.CloudRole], target)
+			if tt.instance != "" {
+				assert.Equal(tt.instance, ctx.Tags[contracts.CloudRoleInstance], target)
+			}
+		}
+
+		hook.SetCloudRole("payments")
+		hook.SetCloudRoleInstance("payments-1")
+		for _, ctx := range hook.contexts() {
+			assert.Equal("payments", ctx.Tags[contracts.CloudRole], target)
+			assert.Equal("payments-1", ctx.Tags[contracts.CloudRoleInstance], target)
+		}
+	}
+}
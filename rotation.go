@@ -0,0 +1,206 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+// rotationDrainTimeout is how long the telemetry pending on a rotated out
+// client is retried.
+const rotationDrainTimeout = 30 * time.Second
+
+// rotatingClient sends telemetry to the current client, which can be
+// switched atomically.
+type rotatingClient struct {
+	mu      sync.RWMutex
+	current appinsights.TelemetryClient
+
+	// newClient returns a client of the instrumentation key sending to the
+	// endpoint, configured as the current one
+	newClient func(iKey, endpointUrl string) appinsights.TelemetryClient
+	staged    *time.Timer
+	next      appinsights.TelemetryClient
+	// draining are closed once the rotated out clients are drained
+	draining []<-chan struct{}
+	stopped  bool
+}
+
+func newRotatingClient(client appinsights.TelemetryClient, newClient func(iKey, endpointUrl string) appinsights.TelemetryClient) *rotatingClient {
+	return &rotatingClient{current: client, newClient: newClient}
+}
+
+// StageRotation prepares a client for the connection string and switches the
+// hook to it at the given time, e.g. to rotate instrumentation keys without
+// losing telemetry. The client switched from keeps delivering the telemetry
+// it holds. The new client takes over the tags and properties of the current
+// one, and fails over to the same secondary resource. Staging another
+// rotation replaces the staged one.
+func (hook *AppInsightsHook) StageRotation(connectionString string, at time.Time) error {
+	rotating := hook.rotatingClient()
+	if rotating == nil {
+		return fmt.Errorf("Client does not support rotation")
+	}
+	iKey, endpointUrl, err := parseConnectionString(connectionString)
+	if err != nil {
+		return err
+	}
+	next := rotating.newClient(iKey, endpointUrl)
+
+	rotating.mu.Lock()
+	defer rotating.mu.Unlock()
+	rotating.unstage()
+	rotating.next = next
+	rotating.staged = time.AfterFunc(time.Until(at), func() { rotating.rotateStaged(next) })
+	return nil
+}
+
+// rotatingClient returns the rotating client of the hook, if any.
+func (hook *AppInsightsHook) rotatingClient() *rotatingClient {
+	for _, client := range hook.clients() {
+		if rotating, ok := client.(*rotatingClient); ok {
+			return rotating
+		}
+	}
+	return nil
+}
+
+// rotateStaged rotates to next unless its rotation was replaced or
+// cancelled, and next stopped then.
+func (c *rotatingClient) rotateStaged(next appinsights.TelemetryClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next != next {
+		return
+	}
+	c.staged, c.next = nil, nil
+	c.switchTo(next)
+}
+
+// rotate switches to next and drains the previous client.
+func (c *rotatingClient) rotate(next appinsights.TelemetryClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.switchTo(next)
+}
+
+// switchTo switches to next and drains the previous client, with c.mu held.
+func (c *rotatingClient) switchTo(next appinsights.TelemetryClient) {
+	if c.stopped {
+		return
+	}
+	previous := c.current
+	to := clientContexts(next)
+	for i, from := range clientContexts(previous) {
+		if i < len(to) {
+			inheritContext(from, to[i])
+		}
+	}
+	c.current = next
+	drained := previous.Channel().Close(rotationDrainTimeout)
+	c.draining = append(c.draining, drained)
+	go func() {
+		<-drained
+		stopClient(previous)
+	}()
+}
+
+// inheritContext sets the tags and properties of to to those of from, except
+// for the SDK version.
+func inheritContext(from, to *appinsights.TelemetryContext) {
+	for k := range to.Tags {
+		if _, ok := from.Tags[k]; !ok && k != contracts.InternalSdkVersion {
+			delete(to.Tags, k)
+		}
+	}
+	for k, v := range from.Tags {
+		if k != contracts.InternalSdkVersion {
+			to.Tags[k] = v
+		}
+	}
+	for k, v := range from.CommonProperties {
+		to.CommonProperties[k] = v
+	}
+}
+
+// stop cancels the staged rotation, returning the channels closed once the
+// rotated out clients are drained.
+func (c *rotatingClient) stop() []<-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	c.unstage()
+	return c.draining
+}
+
+// unstage cancels the staged rotation and stops its client, with c.mu held.
+func (c *rotatingClient) unstage() {
+	if c.staged == nil {
+		return
+	}
+	c.staged.Stop()
+	stopClient(c.next)
+	c.next.Channel().Close()
+	c.staged, c.next = nil, nil
+}
+
+func (c *rotatingClient) active() appinsights.TelemetryClient {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current
+}
+
+func (c *rotatingClient) Context() *appinsights.TelemetryContext {
+	return c.active().Context()
+}
+
+func (c *rotatingClient) InstrumentationKey() string {
+	return c.active().InstrumentationKey()
+}
+
+func (c *rotatingClient) Channel() appinsights.TelemetryChannel {
+	return c.active().Channel()
+}
+
+func (c *rotatingClient) IsEnabled() bool {
+	return c.active().IsEnabled()
+}
+
+func (c *rotatingClient) SetIsEnabled(enabled bool) {
+	c.active().SetIsEnabled(enabled)
+}
+
+func (c *rotatingClient) Track(item appinsights.Telemetry) {
+	c.active().Track(item)
+}
+
+func (c *rotatingClient) TrackEvent(name string) {
+	c.active().TrackEvent(name)
+}
+
+func (c *rotatingClient) TrackMetric(name string, value float64) {
+	c.active().TrackMetric(name, value)
+}
+
+func (c *rotatingClient) TrackTrace(message string, severity contracts.SeverityLevel) {
+	c.active().TrackTrace(message, severity)
+}
+
+func (c *rotatingClient) TrackRequest(method, url string, duration time.Duration, responseCode string) {
+	c.active().TrackRequest(method, url, duration, responseCode)
+}
+
+func (c *rotatingClient) TrackRemoteDependency(name, dependencyType, target string, success bool) {
+	c.active().TrackRemoteDependency(name, dependencyType, target, success)
+}
+
+func (c *rotatingClient) TrackAvailability(name string, duration time.Duration, success bool) {
+	c.active().TrackAvailability(name, duration, success)
+}
+
+func (c *rotatingClient) TrackException(err interface{}) {
+	c.active().TrackException(err)
+}
@@ -0,0 +1,158 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStageRotation(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	received := make(map[string][]string) // messages per instrumentation key
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return
+		}
+		buffer := new(bytes.Buffer)
+		buffer.ReadFrom(reader)
+		items, _ := parsePayload(buffer.Bytes())
+		mu.Lock()
+		for _, item := range items {
+			iKey, _ := item.getPath("iKey")
+			message, _ := item.getPath("data.baseData.message")
+			received[iKey.(string)] = append(received[iKey.(string)], message.(string))
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	hook, err := New("orders", Config{
+		InstrumentationKey: "old",
+		EndpointUrl:        server.URL,
+		MaxBatchInterval:   time.Hour,
+		DefaultProperties:  map[string]string{"environment": "production"},
+	})
+	assert.NoError(err)
+	assert.Error(hook.StageRotation("IngestionEndpoint="+server.URL, time.Now()))
+	assert.NoError(hook.StageRotation("InstrumentationKey=new;IngestionEndpoint="+server.URL, time.Now().Add(100*time.Millisecond)))
+
+	logger := logrus.New()
+	logger.Hooks.Add(hook)
+	logger.Info("before")
+	time.Sleep(300 * time.Millisecond)
+	logger.Info("after")
+	assert.Equal("new", hook.client.InstrumentationKey())
+	assert.Equal("orders", hook.client.Context().Tags.Cloud().GetRole())
+	assert.Equal("production", hook.client.Context().CommonProperties["environment"])
+	hook.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal([]string{"before"}, received["old"])
+	assert.Equal([]string{"after"}, received["new"])
+}
+
+func TestStageRotationWrapped(t *testing.T) {
+	assert := assert.New(t)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+
+	hook, err := New("orders", Config{
+		InstrumentationKey: "old",
+		OTLPEndpoint:       collector.URL + "/v1/logs",
+		OTLPOnly:           true,
+		DryRun:             new(bytes.Buffer),
+	})
+	assert.NoError(err)
+	defer hook.Close()
+
+	assert.NoError(hook.StageRotation("InstrumentationKey=new", time.Now().Add(time.Hour)))
+	assert.True(hook.dryRun())
+	assert.True(hook.otlpOnly())
+	assert.NotNil(hook.rotatingClient())
+}
+
+func TestStageRotationInherits(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	hook, err := New("orders", Config{
+		InstrumentationKey:        "old",
+		EndpointUrl:               server.URL,
+		SecondaryConnectionString: "InstrumentationKey=secondary;IngestionEndpoint=" + server.URL,
+		ChannelBufferSize:         10,
+		DeviceTags:                SuppressDeviceTags,
+	})
+	assert.NoError(err)
+	defer hook.Close()
+
+	rotating := hook.rotatingClient()
+	previous := rotating.active().(*bufferedClient)
+	rotating.rotate(rotating.newClient("new", server.URL+"/v2/track"))
+
+	var failover *failoverClient
+	for _, client := range hook.clients() {
+		if c, ok := client.(*failoverClient); ok {
+			failover = c
+		}
+	}
+	if assert.NotNil(failover) {
+		assert.Equal("new", failover.InstrumentationKey())
+		assert.Equal("secondary", failover.secondary.InstrumentationKey())
+	}
+	for _, ctx := range hook.contexts() {
+		assert.NotContains(ctx.Tags, contracts.DeviceId)
+		assert.NotContains(ctx.Tags, contracts.CloudRoleInstance)
+		assert.Equal("orders", ctx.Tags.Cloud().GetRole())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		previous.mu.Lock()
+		stopped := previous.stopped
+		previous.mu.Unlock()
+		if stopped {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	previous.mu.Lock()
+	assert.True(previous.stopped)
+	previous.mu.Unlock()
+}
+
+func TestStageRotationReplaced(t *testing.T) {
+	assert := assert.New(t)
+
+	var staged []*bufferedClient
+	rotating := newRotatingClient(newRecordingClient(), func(iKey, endpointUrl string) appinsights.TelemetryClient {
+		client := newBufferedClient(newRecordingClient(), 10, false)
+		staged = append(staged, client)
+		return client
+	})
+	hook := AppInsightsHook{client: rotating}
+
+	assert.NoError(hook.StageRotation("InstrumentationKey=first", time.Now().Add(time.Hour)))
+	assert.NoError(hook.StageRotation("InstrumentationKey=second", time.Now().Add(time.Hour)))
+	// the replaced client is stopped, the staged one is not
+	assert.True(staged[0].stopped)
+	assert.False(staged[1].stopped)
+
+	rotating.stop()
+	assert.True(staged[1].stopped)
+}
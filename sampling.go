@@ -0,0 +1,248 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SampleRateKey is the property holding the sampling percentage of items
+// sampled at less than 100%, so counts can be extrapolated in queries.
+const SampleRateKey = "sample_rate"
+
+// SampleKey is the field overriding the sampling of an entry, holding
+// SampleAlways, SampleNever or the percentage of such entries to send, e.g.
+//
+//	log.WithField(SampleKey, SampleAlways).Info("cache rebuilt")
+const SampleKey = "_sample"
+
+// Values of the SampleKey field.
+const (
+	SampleAlways = "always"
+	SampleNever  = "never"
+)
+
+var (
+	samplingMu   sync.Mutex
+	samplingRand = rand.New(rand.NewSource(rand.Int63()))
+)
+
+// SetSamplingRates sets the percentage, between 0 and 100, of the entries
+// of each level to send, e.g. all errors but 5% of Info and 1% of Debug
+// entries. Levels missing from rates are all sent.
+func (hook *AppInsightsHook) SetSamplingRates(rates map[logrus.Level]float64) {
+	hook.sampling = make(map[logrus.Level]float64, len(rates))
+	for level, rate := range rates {
+		hook.sampling[level] = rate
+	}
+}
+
+// sampleRate returns the sampling percentage of entries of level.
+func (hook *AppInsightsHook) sampleRate(level logrus.Level) float64 {
+	if rate, ok := hook.sampling[level]; ok {
+		return rate
+	}
+	return 100
+}
+
+// SetSampler sets sampler to decide which entries are sent in place of the
+// sampling rates, adaptive sampling and SampleKey field, e.g. to sample per
+// tenant or feature flag. Exempt entries are sent regardless. A nil sampler
+// restores the built-in sampling.
+func (hook *AppInsightsHook) SetSampler(sampler func(entry *logrus.Entry) bool) {
+	hook.sampler = sampler
+}
+
+// SetSamplingFloor exempts the entries of level and the more severe levels,
+// e.g. Fatal for Panic and Fatal entries, from sampling and operation budgets
+// so they always reach Application Insights.
+func (hook *AppInsightsHook) SetSamplingFloor(level logrus.Level) {
+	hook.sampleFloor = &level
+}
+
+// AddSamplingExemptField exempts the entries whose field name holds value,
+// e.g. audit=true, from sampling and operation budgets so they always reach
+// Application Insights.
+func (hook *AppInsightsHook) AddSamplingExemptField(name string, value interface{}) {
+	hook.exemptFields = append(hook.exemptFields, exemptField{name: name, value: value})
+}
+
+type exemptField struct {
+	name  string
+	value interface{}
+}
+
+// exempt reports whether entry is exempt from sampling and budgets.
+func (hook *AppInsightsHook) exempt(entry *logrus.Entry) bool {
+	if hook.sampleFloor != nil && entry.Level <= *hook.sampleFloor {
+		return true
+	}
+	for _, field := range hook.exemptFields {
+		if v, ok := entry.Data[field.name]; ok && fmt.Sprint(v) == fmt.Sprint(field.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampled reports whether entry is sampled in. Entries of an operation are
+// sampled in or out together, by the sampling score of its ID.
+func (hook *AppInsightsHook) sampled(entry *logrus.Entry) bool {
+	hook.adaptive.observe()
+	if hook.sampler != nil && !hook.exempt(entry) {
+		return hook.sampler(entry)
+	}
+	rate := hook.samplingRate(entry)
+	if rate >= 100 {
+		return true
+	}
+	if operationID := hook.operationID(hook.renamedEntry(entry)); operationID != "" {
+		return samplingScore(operationID) < rate
+	}
+	samplingMu.Lock()
+	defer samplingMu.Unlock()
+	return samplingRand.Float64()*100 < rate
+}
+
+// samplingRate returns the percentage of entries like entry that are sent.
+func (hook *AppInsightsHook) samplingRate(entry *logrus.Entry) float64 {
+	if hook.exempt(entry) {
+		return 100
+	}
+	if rate, ok := sampleOverride(entry); ok {
+		return rate
+	}
+	return hook.sampleRate(entry.Level) * hook.adaptive.rate() / 100
+}
+
+// sampleOverride returns the sampling percentage set by the SampleKey field
+// of entry, if valid.
+func sampleOverride(entry *logrus.Entry) (float64, bool) {
+	v, ok := entry.Data[SampleKey]
+	if !ok {
+		return 0, false
+	}
+	switch s := strings.ToLower(fmt.Sprint(v)); s {
+	case SampleAlways:
+		return 100, true
+	case SampleNever:
+		return 0, true
+	default:
+		rate, err := strconv.ParseFloat(s, 64)
+		if err != nil || rate < 0 || rate > 100 {
+			return 0, false
+		}
+		return rate, true
+	}
+}
+
+// samplingScore returns the score, between 0 and 100, of the operation id as
+// computed by the Application Insights SDKs, so the services of a
+// distributed operation sample it alike.
+func samplingScore(id string) float64 {
+	if id == "" {
+		return 0
+	}
+	for len(id) < 8 {
+		id += id
+	}
+	var hash int32 = 5381
+	for _, c := range utf16.Encode([]rune(id)) {
+		hash = (hash << 5) + hash + int32(c)
+	}
+	if hash == math.MinInt32 {
+		hash = math.MaxInt32
+	} else if hash < 0 {
+		hash = -hash
+	}
+	return float64(hash) / math.MaxInt32 * 100
+}
+
+// addSampleRate adds the sampling percentage of entry to props if sampled.
+func (hook *AppInsightsHook) addSampleRate(props map[string]string, entry *logrus.Entry) {
+	if hook.sampler != nil {
+		return
+	}
+	if rate := hook.samplingRate(entry); rate < 100 {
+		hook.addHookProperty(props, SampleRateKey, strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+}
+
+const (
+	// adaptiveInterval is how often adaptive sampling adjusts its rate.
+	adaptiveInterval = 15 * time.Second
+	// adaptiveRatio is the weight of the last interval in the moving average
+	// of the entries per second.
+	adaptiveRatio = 0.25
+	// minAdaptiveRate is the lowest percentage adaptive sampling sends.
+	minAdaptiveRate = 0.1
+)
+
+// adaptiveSampler adjusts the percentage of entries sent to a target number
+// of entries per second, as the adaptive sampling of the .NET SDK does.
+type adaptiveSampler struct {
+	mu      sync.Mutex
+	target  float64
+	percent float64
+	average float64
+	seen    int
+	start   time.Time
+	now     func() time.Time
+}
+
+// SetAdaptiveSampling sets the hook to send about itemsPerSecond entries per
+// second, adjusting the percentage of entries sent every 15 seconds to the
+// moving average of the entries logged. It applies on top of the rates of
+// SetSamplingRates, and the current percentage is reported in the
+// SamplingRate statistic. Zero or less disables adaptive sampling.
+func (hook *AppInsightsHook) SetAdaptiveSampling(itemsPerSecond float64) {
+	hook.adaptive = nil
+	if itemsPerSecond > 0 {
+		hook.adaptive = &adaptiveSampler{target: itemsPerSecond, percent: 100, start: time.Now(), now: time.Now}
+	}
+}
+
+// observe counts an entry, adjusting the rate at the end of every interval.
+func (s *adaptiveSampler) observe() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen++
+	now := s.now()
+	elapsed := now.Sub(s.start)
+	if elapsed < adaptiveInterval {
+		return
+	}
+	observed := float64(s.seen) / elapsed.Seconds()
+	if s.average == 0 {
+		s.average = observed
+	} else {
+		s.average = s.average*(1-adaptiveRatio) + observed*adaptiveRatio
+	}
+	s.percent = 100 * s.target / s.average
+	if s.percent > 100 {
+		s.percent = 100
+	} else if s.percent < minAdaptiveRate {
+		s.percent = minAdaptiveRate
+	}
+	s.seen, s.start = 0, now
+}
+
+// rate returns the percentage of entries currently sent.
+func (s *adaptiveSampler) rate() float64 {
+	if s == nil {
+		return 100
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.percent
+}
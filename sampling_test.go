@@ -0,0 +1,250 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetSamplingRates(t *testing.T) {
+	assert := assert.New(t)
+
+	rates := map[logrus.Level]float64{logrus.ErrorLevel: 100, logrus.InfoLevel: 10, logrus.DebugLevel: 0}
+	tests := []struct {
+		level      logrus.Level
+		min, max   int
+		sampleRate string
+	}{
+		{logrus.ErrorLevel, 1000, 1000, ""},
+		{logrus.WarnLevel, 1000, 1000, ""},
+		{logrus.InfoLevel, 50, 150, "10"},
+		{logrus.DebugLevel, 0, 0, "0"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		client := newRecordingClient()
+		hook := AppInsightsHook{client: client, levels: logrus.AllLevels}
+		hook.SetSamplingRates(rates)
+		pipeline := hook.NewPipeline()
+		for i := 0; i < 1000; i++ {
+			entry := logrus.NewEntry(logrus.New())
+			entry.Level = tt.level
+			assert.NoError(pipeline.Fire(entry), target)
+		}
+		tracked := client.tracked()
+		assert.True(len(tracked) >= tt.min && len(tracked) <= tt.max, target)
+		for _, item := range tracked {
+			assert.Equal(tt.sampleRate, item.GetProperties()[SampleRateKey], target)
+		}
+	}
+
+	receipt := NewReceipt()
+	hook := AppInsightsHook{client: newRecordingClient()}
+	hook.SetSamplingRates(map[logrus.Level]float64{logrus.InfoLevel: 0})
+	entry := logrus.NewEntry(logrus.New()).WithField(ReceiptKey, receipt)
+	entry.Level = logrus.InfoLevel
+	assert.NoError(hook.Fire(entry))
+	disposition, err := receipt.Disposition()
+	assert.Equal(Dropped, disposition)
+	assert.NoError(err)
+}
+
+func TestSetAdaptiveSampling(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		perSecond []int
+		expected  float64
+	}{
+		{[]int{5}, 100},
+		{[]int{100}, 10},
+		{[]int{100, 20}, 12.5},
+		{[]int{1000000}, minAdaptiveRate},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetAdaptiveSampling(10)
+		pipeline := hook.NewPipeline()
+		entry := logrus.NewEntry(logrus.New())
+		now := time.Unix(0, 0)
+		hook.adaptive.start, hook.adaptive.now = now, func() time.Time { return now }
+		for _, n := range tt.perSecond {
+			entries := n * int(adaptiveInterval/time.Second)
+			for i := 0; i < entries; i++ {
+				if i == entries-1 {
+					now = now.Add(adaptiveInterval)
+				}
+				pipeline.sampled(entry)
+			}
+		}
+		assert.InDelta(tt.expected, hook.Stats().SamplingRate, 0.001, target)
+	}
+
+	hook := AppInsightsHook{}
+	assert.Equal(float64(100), hook.Stats().SamplingRate)
+	buffer := new(bytes.Buffer)
+	assert.NoError(Stats{SamplingRate: 12.5}.WritePrometheus(buffer))
+	assert.Contains(buffer.String(), "logrus_appinsights_sampling_rate_percent 12.5\n")
+}
+
+func TestOperationSampling(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		field string
+	}{
+		{TraceIDKey},
+		{OperationIDKey},
+		{"op"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		client := newRecordingClient()
+		hook := AppInsightsHook{client: client}
+		hook.MapFieldToTag("op", contracts.OperationId)
+		hook.SetSamplingRates(map[logrus.Level]float64{logrus.InfoLevel: 50})
+		kept := 0
+		for op := 0; op < 200; op++ {
+			operationID := newID(16)
+			before := len(client.tracked())
+			for i := 0; i < 5; i++ {
+				entry := logrus.NewEntry(logrus.New()).WithField(tt.field, operationID)
+				entry.Level = logrus.InfoLevel
+				assert.NoError(hook.Fire(entry))
+			}
+			sent := len(client.tracked()) - before
+			assert.Contains([]int{0, 5}, sent, target)
+			assert.Equal(samplingScore(operationID) < 50, sent == 5, target)
+			if sent > 0 {
+				kept++
+			}
+		}
+		assert.True(kept > 50 && kept < 150, target)
+	}
+
+	assert.Equal(float64(0), samplingScore(""))
+	assert.InDelta(samplingScore("abcdabcd"), samplingScore("abcd"), 0)
+	for _, id := range []string{"a", "0af7651916cd43dd8448eb211c80319c", "\u00e9t\u00e9"} {
+		score := samplingScore(id)
+		assert.True(score >= 0 && score <= 100, id)
+	}
+}
+
+func TestSamplingExemptions(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		level    logrus.Level
+		fields   logrus.Fields
+		expected int
+	}{
+		{logrus.InfoLevel, nil, 0},
+		{logrus.InfoLevel, logrus.Fields{"audit": true}, 10},
+		{logrus.InfoLevel, logrus.Fields{"audit": "true"}, 10},
+		{logrus.InfoLevel, logrus.Fields{"audit": false}, 0},
+		{logrus.ErrorLevel, nil, 10},
+		{logrus.WarnLevel, nil, 0},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		client := newRecordingClient()
+		hook := AppInsightsHook{client: client, levels: logrus.AllLevels}
+		hook.SetSamplingRates(map[logrus.Level]float64{logrus.ErrorLevel: 0, logrus.WarnLevel: 0, logrus.InfoLevel: 0})
+		hook.SetSamplingFloor(logrus.ErrorLevel)
+		hook.AddSamplingExemptField("audit", true)
+		hook.SetOperationBudget(1, 0)
+		pipeline := hook.NewPipeline()
+		for i := 0; i < 10; i++ {
+			entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields).WithField(TraceIDKey, "0af7651916cd43dd8448eb211c80319c")
+			entry.Level = tt.level
+			assert.NoError(pipeline.Fire(entry), target)
+		}
+		tracked := client.tracked()
+		assert.Len(tracked, tt.expected, target)
+		for _, item := range tracked {
+			assert.NotContains(item.GetProperties(), SampleRateKey, target)
+		}
+	}
+}
+
+func TestSampleKey(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		sample     interface{}
+		min, max   int
+		sampleRate string
+	}{
+		{SampleAlways, 100, 100, ""},
+		{"ALWAYS", 100, 100, ""},
+		{SampleNever, 0, 0, ""},
+		{25, 10, 45, "25"},
+		{"25.5", 10, 45, "25.5"},
+		{"sometimes", 0, 0, ""},
+		{150, 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		client := newRecordingClient()
+		hook := AppInsightsHook{client: client}
+		hook.SetSamplingRates(map[logrus.Level]float64{logrus.InfoLevel: 0})
+		for i := 0; i < 100; i++ {
+			entry := logrus.NewEntry(logrus.New()).WithField(SampleKey, tt.sample)
+			entry.Level = logrus.InfoLevel
+			assert.NoError(hook.Fire(entry), target)
+		}
+		tracked := client.tracked()
+		assert.True(len(tracked) >= tt.min && len(tracked) <= tt.max, target)
+		for _, item := range tracked {
+			assert.NotContains(item.GetProperties(), SampleKey, target)
+			assert.Equal(tt.sampleRate, item.GetProperties()[SampleRateKey], target)
+		}
+	}
+}
+
+func TestSetSampler(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		tenant   string
+		level    logrus.Level
+		expected int
+	}{
+		{"contoso", logrus.InfoLevel, 1},
+		{"fabrikam", logrus.InfoLevel, 0},
+		{"fabrikam", logrus.ErrorLevel, 1},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		client := newRecordingClient()
+		hook := AppInsightsHook{client: client}
+		hook.SetSamplingRates(map[logrus.Level]float64{logrus.InfoLevel: 0})
+		hook.SetSamplingFloor(logrus.ErrorLevel)
+		hook.SetSampler(func(entry *logrus.Entry) bool { return entry.Data["tenant"] == "contoso" })
+		entry := logrus.NewEntry(logrus.New()).WithField("tenant", tt.tenant)
+		entry.Level = tt.level
+		assert.NoError(hook.NewPipeline().Fire(entry), target)
+		tracked := client.tracked()
+		if assert.Len(tracked, tt.expected, target) && tt.expected > 0 {
+			assert.NotContains(tracked[0].GetProperties(), SampleRateKey, target)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package logrus_appinsights
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPropertyKeyLength is the longest property key Application Insights
+// accepts, in characters.
+const MaxPropertyKeyLength = 150
+
+// KeyCollisionStrategy decides what happens to a property whose sanitized key
+// is already used by another property.
+type KeyCollisionStrategy int
+
+const (
+	// NumberCollidingKeys appends _2, _3 and so on to the sanitized key.
+	NumberCollidingKeys KeyCollisionStrategy = iota
+	// DropCollidingKeys drops the property.
+	DropCollidingKeys
+)
+
+// SanitizeKey returns key without control characters and truncated to
+// MaxPropertyKeyLength characters, or "_" if empty.
+func SanitizeKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, key)
+	if key == "" {
+		return "_"
+	}
+	return truncateRunes(key, MaxPropertyKeyLength)
+}
+
+// SetKeySanitizer sets the transform applied to property keys Application
+// Insights would refuse, SanitizeKey by default. Keys it returns are then
+// sanitized with SanitizeKey if still invalid.
+func (hook *AppInsightsHook) SetKeySanitizer(transform func(key string) string) {
+	hook.keySanitizer = transform
+}
+
+// SetKeyCollisionStrategy sets what happens to properties whose sanitized key
+// is already used, NumberCollidingKeys by default.
+func (hook *AppInsightsHook) SetKeyCollisionStrategy(strategy KeyCollisionStrategy) {
+	hook.keyCollisions = strategy
+}
+
+// validKey reports whether Application Insights accepts key.
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	n := 0
+	for _, r := range key {
+		// invalid UTF-8 ranges as utf8.RuneError
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return false
+		}
+		n++
+	}
+	return n <= MaxPropertyKeyLength
+}
+
+// sanitizeKeys replaces the keys of props Application Insights would refuse.
+func (hook *AppInsightsHook) sanitizeKeys(props map[string]string) {
+	var invalid []string
+	for k := range props {
+		if !validKey(k) {
+			invalid = append(invalid, k)
+		}
+	}
+	sort.Strings(invalid)
+	for _, k := range invalid {
+		v := props[k]
+		delete(props, k)
+		key := k
+		if hook.keySanitizer != nil {
+			key = hook.keySanitizer(key)
+		}
+		if !validKey(key) {
+			key = SanitizeKey(key)
+		}
+		if _, ok := props[key]; ok {
+			if hook.keyCollisions == DropCollidingKeys {
+				continue
+			}
+			key = numberedKey(props, key)
+		}
+		props[key] = v
+	}
+}
+
+// numberedKey returns key followed by the first number from 2 not used in
+// props, truncating key to fit.
+func numberedKey(props map[string]string, key string) string {
+	for n := 2; ; n++ {
+		suffix := "_" + strconv.Itoa(n)
+		numbered := truncateRunes(key, MaxPropertyKeyLength-len(suffix)) + suffix
+		if _, ok := props[numbered]; !ok {
+			return numbered
+		}
+	}
+}
+
+// truncateRunes returns s truncated to n characters.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// MaxPropertyValueLength is the longest property value Application Insights
+// accepts.
+const MaxPropertyValueLength = 8192
+
+// TruncatedSuffix is appended to the key of a truncated property for the
+// property marking it as truncated.
+const TruncatedSuffix = "_truncated"
+
+// SetMaxValueLength sets the length in bytes property values are truncated
+// to, MaxPropertyValueLength by default, marking truncated values with a
+// property named after their key followed by TruncatedSuffix set to true.
+// Zero or less restores the default.
+func (hook *AppInsightsHook) SetMaxValueLength(n int) {
+	hook.maxValueLen = n
+}
+
+// truncateValues truncates the values of props longer than allowed.
+func (hook *AppInsightsHook) truncateValues(props map[string]string) {
+	max := hook.maxValueLen
+	if max <= 0 {
+		max = MaxPropertyValueLength
+	}
+	var truncated []string
+	for k, v := range props {
+		if len(v) > max {
+			truncated = append(truncated, k)
+		}
+	}
+	for _, k := range truncated {
+		if k == "message" {
+			props[k] = headTail(props[k], max)
+		} else {
+			props[k] = truncateBytes(props[k], max)
+		}
+		props[k+TruncatedSuffix] = "true"
+	}
+}
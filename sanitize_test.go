@@ -0,0 +1,112 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeKey(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"user", "user"},
+		{"", "_"},
+		{"line\nbreak\t", "linebreak"},
+		{"\x00", "_"},
+		{"bad\xffutf8", "badutf8"},
+		{strings.Repeat("k", 200), strings.Repeat("k", 150)},
+		{strings.Repeat("é", 151), strings.Repeat("é", 150)},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		assert.Equal(tt.expected, SanitizeKey(tt.key), target)
+	}
+}
+
+func TestSanitizeKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		transform func(string) string
+		strategy  KeyCollisionStrategy
+		fields    logrus.Fields
+		expected  map[string]string
+		absent    []string
+	}{
+		{nil, NumberCollidingKeys, logrus.Fields{"user": "jane", "user\n": "john", "": "empty"}, map[string]string{
+			"user": "jane", "user_2": "john", "_": "empty",
+		}, []string{"user\n", ""}},
+		{nil, DropCollidingKeys, logrus.Fields{"user": "jane", "user\n": "john"}, map[string]string{"user": "jane"}, []string{"user_2"}},
+		{func(key string) string { return strings.Replace(key, "\n", "_nl", -1) }, NumberCollidingKeys, logrus.Fields{"user\n": "john"}, map[string]string{
+			"user_nl": "john",
+		}, nil},
+		{nil, NumberCollidingKeys, logrus.Fields{strings.Repeat("k", 150): "first", strings.Repeat("k", 151): "second"}, map[string]string{
+			strings.Repeat("k", 150):        "first",
+			strings.Repeat("k", 148) + "_2": "second",
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		if tt.transform != nil {
+			hook.SetKeySanitizer(tt.transform)
+		}
+		hook.SetKeyCollisionStrategy(tt.strategy)
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+
+		props := hook.NewPipeline().buildProperties(entry)
+		for k, v := range tt.expected {
+			assert.Equal(v, props[k], target)
+		}
+		for _, k := range tt.absent {
+			assert.NotContains(props, k, target)
+		}
+		for k := range props {
+			assert.True(validKey(k), target)
+		}
+	}
+}
+
+func TestTruncateValues(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		max      int
+		value    string
+		expected string
+	}{
+		{0, "short", "short"},
+		{0, strings.Repeat("v", 9000), strings.Repeat("v", 8192)},
+		{4, "abcdef", "abcd"},
+		{4, "abcd", "abcd"},
+		// multi-byte characters are not split
+		{4, "aéé", "aé"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetMaxValueLength(tt.max)
+		entry := logrus.NewEntry(logrus.New()).WithField("value", tt.value)
+
+		props := hook.NewPipeline().buildProperties(entry)
+		assert.Equal(tt.expected, props["value"], target)
+		if tt.expected != tt.value {
+			assert.Equal("true", props["value_truncated"], target)
+		} else {
+			assert.NotContains(props, "value_truncated", target)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package logrus_appinsights
+
+import "regexp"
+
+// SecretMarker replaces the secrets found in messages and properties.
+const SecretMarker = "[REDACTED]"
+
+// secretPatterns are the common forms of credentials found in logs by
+// mistake, by kind.
+var secretPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	// signatures of Azure shared access signatures
+	{"sas_signature", regexp.MustCompile(`\bsig=[A-Za-z0-9%+/=]{16,}`)},
+	// Azure storage account keys in connection strings
+	{"account_key", regexp.MustCompile(`\bAccountKey=[A-Za-z0-9+/=]{16,}`)},
+	{"bearer_token", regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{8,}=*`)},
+	// PEM encoded private keys
+	{"private_key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY-----(?s:.*?)(?:-----END (?:[A-Z]+ )*PRIVATE KEY-----|$)`)},
+}
+
+// SetSecretScanning sets whether messages and properties are scanned for
+// common forms of credentials, such as AWS access keys, Azure shared access
+// signatures and account keys, bearer tokens and private keys, replaced with
+// SecretMarker and counted in the SecretsRedacted statistic.
+func (hook *AppInsightsHook) SetSecretScanning(enabled bool) {
+	hook.redactionCounts()
+	hook.secrets = enabled
+}
+
+// redactSecrets returns s with the secrets it holds replaced.
+func (hook *AppInsightsHook) redactSecrets(s string) string {
+	for _, secret := range secretPatterns {
+		s = secret.pattern.ReplaceAllStringFunc(s, func(string) string {
+			hook.redactions.add("secret." + secret.kind)
+			return SecretMarker
+		})
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package logrus_appinsights
+
+import (
+	"reflect"
+	"regexp"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+)
+
+// downgradeRule reports whether an Error entry is known to be benign.
+type downgradeRule func(entry *logrus.Entry, err error) bool
+
+// SetLevelMapping sets the severity traces are sent with for the given
+// levels, in place of the default mapping.
+func (hook *AppInsightsHook) SetLevelMapping(mapping map[logrus.Level]contracts.SeverityLevel) {
+	hook.levelMapping = make(map[logrus.Level]contracts.SeverityLevel, len(mapping))
+	for level, severity := range mapping {
+		hook.levelMapping[level] = severity
+	}
+}
+
+// AddSeverityDowngrade lowers Error entries whose message or error text
+// matches pattern to Warning severity, so known-benign failures stop paging.
+func (hook *AppInsightsHook) AddSeverityDowngrade(pattern *regexp.Regexp) {
+	hook.downgrades = append(hook.downgrades, func(entry *logrus.Entry, err error) bool {
+		return pattern.MatchString(entry.Message) || (err != nil && pattern.MatchString(err.Error()))
+	})
+}
+
+// AddSeverityDowngradeType lowers Error entries carrying an error of the same
+// type as sample to Warning severity.
+func (hook *AppInsightsHook) AddSeverityDowngradeType(sample error) {
+	errType := reflect.TypeOf(sample)
+	hook.downgrades = append(hook.downgrades, func(entry *logrus.Entry, err error) bool {
+		return err != nil && reflect.TypeOf(err) == errType
+	})
+}
+
+// severity returns the severity level of entry after downgrade rules.
+func (hook *AppInsightsHook) severity(entry *logrus.Entry) contracts.SeverityLevel {
+	level, ok := hook.levelMapping[entry.Level]
+	if !ok {
+		level = levelMap[entry.Level]
+	}
+	if entry.Level != logrus.ErrorLevel {
+		return level
+	}
+	err, _ := entry.Data[logrus.ErrorKey].(error)
+	for _, rule := range hook.downgrades {
+		if rule(entry, err) {
+			return appinsights.Warning
+		}
+	}
+	return level
+}
@@ -0,0 +1,76 @@
+package logrus_appinsights
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeverityDowngrade(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.AddSeverityDowngrade(regexp.MustCompile(`connection reset`))
+	hook.AddSeverityDowngradeType(&os.PathError{})
+
+	tests := []struct {
+		level    logrus.Level
+		message  string
+		err      error
+		expected contracts.SeverityLevel
+	}{
+		{logrus.ErrorLevel, "request failed", nil, appinsights.Error},
+		{logrus.ErrorLevel, "connection reset by peer", nil, appinsights.Warning},
+		{logrus.ErrorLevel, "request failed", errors.New("read: connection reset by peer"), appinsights.Warning},
+		{logrus.ErrorLevel, "request failed", &os.PathError{Op: "open", Path: "/tmp", Err: os.ErrNotExist}, appinsights.Warning},
+		{logrus.ErrorLevel, "request failed", errors.New("timeout"), appinsights.Error},
+		{logrus.FatalLevel, "connection reset by peer", nil, appinsights.Critical},
+		{logrus.InfoLevel, "connection reset by peer", nil, appinsights.Information},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		entry := logrus.NewEntry(logrus.New())
+		entry.Level = tt.level
+		entry.Message = tt.message
+		if tt.err != nil {
+			entry.Data[logrus.ErrorKey] = tt.err
+		}
+		assert.Equal(tt.expected, hook.severity(entry), target)
+	}
+}
+
+func TestSetLevelMapping(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		mapping  map[logrus.Level]contracts.SeverityLevel
+		level    logrus.Level
+		expected contracts.SeverityLevel
+	}{
+		{nil, logrus.DebugLevel, appinsights.Verbose},
+		{nil, logrus.TraceLevel, appinsights.Verbose},
+		{nil, logrus.WarnLevel, appinsights.Warning},
+		{map[logrus.Level]contracts.SeverityLevel{logrus.DebugLevel: appinsights.Information}, logrus.DebugLevel, appinsights.Information},
+		{map[logrus.Level]contracts.SeverityLevel{logrus.DebugLevel: appinsights.Information}, logrus.InfoLevel, appinsights.Information},
+		{map[logrus.Level]contracts.SeverityLevel{logrus.PanicLevel: appinsights.Error}, logrus.PanicLevel, appinsights.Error},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetLevelMapping(tt.mapping)
+		entry := logrus.NewEntry(logrus.New())
+		entry.Level = tt.level
+		assert.Equal(tt.expected, hook.severity(entry), target)
+	}
+}
@@ -0,0 +1,140 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OperationIDKey is the field identifying the operation an entry belongs to.
+const OperationIDKey = "operation_id"
+
+// maxSnapshotOperations bounds the number of operations remembered as
+// already snapshotted.
+const maxSnapshotOperations = 1024
+
+// errorSnapshots keeps the tail of recent Debug entries of every operation
+// and the operations whose first error has already been snapshotted.
+type errorSnapshots struct {
+	mu        sync.Mutex
+	n         int
+	tails     map[interface{}][]string
+	tailOrder []interface{}
+	seen      map[interface{}]struct{}
+	order     []interface{}
+}
+
+func newErrorSnapshots(n int) *errorSnapshots {
+	return &errorSnapshots{
+		n:     n,
+		tails: make(map[interface{}][]string),
+		seen:  make(map[interface{}]struct{}),
+	}
+}
+
+// SetFirstErrorSnapshot sets the hook to attach a snapshot of the process to
+// the first Error entry of every operation identified by OperationIDKey: the
+// last n Debug entries of the operation, with the fields and message the hook
+// would send, the number of goroutines and memory statistics. Debug entries
+// are buffered for the snapshot but only sent if the hook's levels include
+// them. A value of zero or less disables snapshots.
+func (hook *AppInsightsHook) SetFirstErrorSnapshot(n int) {
+	if n <= 0 {
+		hook.snapshots = nil
+		return
+	}
+	hook.snapshots = newErrorSnapshots(n)
+}
+
+// recordDebug adds a Debug entry to the tail of its operation, if any, as the
+// hook would send it.
+func (hook *AppInsightsHook) recordDebug(entry *logrus.Entry) {
+	entry = hook.scrubbedEntry(hook.conditionalEntry(hook.renamedEntry(hook.normalizedEntry(hook.truncatedEntry(entry)))))
+	operation, ok := entry.Data[OperationIDKey]
+	if !ok {
+		return // never snapshotted
+	}
+	props := make(map[string]string, len(entry.Data))
+	for k, v := range entry.Data {
+		hook.addField(props, k, v)
+	}
+	message, _ := hook.propertyValue("message", entry.Message)
+	hook.snapshots.record(operation, fmt.Sprintf("%s %s %v", entry.Time.Format(time.RFC3339Nano), message, props))
+}
+
+// record adds line to the tail of operation.
+func (s *errorSnapshots) record(operation interface{}, line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[operation]; ok {
+		return // already snapshotted
+	}
+	tail, ok := s.tails[operation]
+	if !ok {
+		if len(s.tailOrder) == maxSnapshotOperations {
+			delete(s.tails, s.tailOrder[0])
+			s.tailOrder = s.tailOrder[1:]
+		}
+		s.tailOrder = append(s.tailOrder, operation)
+	}
+	tail = append(tail, line)
+	if len(tail) > s.n {
+		tail = tail[len(tail)-s.n:]
+	}
+	s.tails[operation] = tail
+}
+
+// first reports whether entry is the first Error entry of its operation.
+func (s *errorSnapshots) first(entry *logrus.Entry) bool {
+	if entry.Level != logrus.ErrorLevel {
+		return false
+	}
+	operation, ok := entry.Data[OperationIDKey]
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[operation]; ok {
+		return false
+	}
+	if len(s.order) == maxSnapshotOperations {
+		delete(s.seen, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.seen[operation] = struct{}{}
+	s.order = append(s.order, operation)
+	return true
+}
+
+// properties returns the snapshot properties of the process and the tail of
+// operation, which is then released.
+func (s *errorSnapshots) properties(operation interface{}) map[string]string {
+	s.mu.Lock()
+	tail := s.tails[operation]
+	if _, ok := s.tails[operation]; ok {
+		delete(s.tails, operation)
+		for i, o := range s.tailOrder {
+			if o == operation {
+				s.tailOrder = append(s.tailOrder[:i], s.tailOrder[i+1:]...)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return map[string]string{
+		"snapshot.debug":      strings.Join(tail, "\n"),
+		"snapshot.goroutines": strconv.Itoa(runtime.NumGoroutine()),
+		"snapshot.heap_alloc": strconv.FormatUint(mem.HeapAlloc, 10),
+		"snapshot.heap_sys":   strconv.FormatUint(mem.HeapSys, 10),
+		"snapshot.num_gc":     strconv.FormatUint(uint64(mem.NumGC), 10),
+	}
+}
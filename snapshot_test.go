@@ -0,0 +1,71 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstErrorSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{levels: []logrus.Level{logrus.ErrorLevel}, ignoreFields: make(map[string]struct{})}
+	hook.SetFirstErrorSnapshot(2)
+	assert.Contains(hook.Levels(), logrus.DebugLevel)
+	assert.NotContains(hook.levels, logrus.DebugLevel)
+
+	hook.AddIgnore("password")
+	log := logrus.New()
+	for i := 0; i < 3; i++ {
+		for _, operation := range []string{"a", "b"} {
+			entry := logrus.NewEntry(log).WithFields(logrus.Fields{OperationIDKey: operation, "password": "hunter2", "step": i})
+			entry.Level = logrus.DebugLevel
+			entry.Message = fmt.Sprintf("%s step %d", operation, i)
+			// buffered only, the hook isn't registered for Debug entries
+			assert.NoError(hook.Fire(entry))
+		}
+	}
+
+	tests := []struct {
+		level     logrus.Level
+		operation interface{}
+		expected  []string
+	}{
+		{logrus.WarnLevel, "a", nil},
+		{logrus.ErrorLevel, nil, nil},
+		{logrus.ErrorLevel, "a", []string{"a step 1", "a step 2"}},
+		{logrus.ErrorLevel, "a", nil},
+		{logrus.ErrorLevel, "b", []string{"b step 1", "b step 2"}},
+		{logrus.ErrorLevel, "c", []string{""}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		entry := logrus.NewEntry(log)
+		if tt.operation != nil {
+			entry = entry.WithField(OperationIDKey, tt.operation)
+		}
+		entry.Level = tt.level
+		props := hook.buildProperties(entry)
+		if tt.expected == nil {
+			assert.NotContains(props, "snapshot.debug", target)
+			continue
+		}
+		debug := strings.Split(props["snapshot.debug"], "\n")
+		if assert.Len(debug, len(tt.expected), target) {
+			for i, expected := range tt.expected {
+				assert.Contains(debug[i], expected, target)
+			}
+		}
+		assert.NotContains(props["snapshot.debug"], "hunter2", target)
+		assert.NotEmpty(props["snapshot.goroutines"], target)
+		assert.NotEmpty(props["snapshot.heap_alloc"], target)
+	}
+
+	hook.SetFirstErrorSnapshot(0)
+	assert.NotContains(hook.Levels(), logrus.DebugLevel)
+}
@@ -0,0 +1,101 @@
+package logrus_appinsights
+
+import (
+	"context"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// SpanBridge connects the hook to a tracing library such as OpenTelemetry
+// without depending on it. With OpenTelemetry:
+//
+//	hook.SetSpanBridge(logrus_appinsights.SpanBridge{
+//		SpanContext: func(ctx context.Context) (string, string) {
+//			sc := trace.SpanContextFromContext(ctx)
+//			return sc.TraceID().String(), sc.SpanID().String()
+//		},
+//		AddEvent: func(ctx context.Context, name string, attributes map[string]string) {
+//			kvs := make([]attribute.KeyValue, 0, len(attributes))
+//			for k, v := range attributes {
+//				kvs = append(kvs, attribute.String(k, v))
+//			}
+//			trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(kvs...))
+//		},
+//		Baggage: func(ctx context.Context) map[string]string {
+//			members := make(map[string]string)
+//			for _, m := range baggage.FromContext(ctx).Members() {
+//				members[m.Key()] = m.Value()
+//			}
+//			return members
+//		},
+//	})
+type SpanBridge struct {
+	// SpanContext returns the hex trace and span IDs of the span active in
+	// ctx. Items are tagged with the trace as operation and the span as
+	// parent.
+	SpanContext func(ctx context.Context) (traceID, spanID string)
+
+	// AddEvent, if set, records each entry as an event named after its
+	// message on the span active in ctx.
+	AddEvent func(ctx context.Context, name string, attributes map[string]string)
+
+	// Baggage, if set, returns the baggage carried by ctx, of which the
+	// members selected with AddBaggageKeys are sent as properties.
+	Baggage func(ctx context.Context) map[string]string
+}
+
+// SetSpanBridge sets the bridge correlating items with the span active in
+// the entry's context. Trace context fields take precedence over it, and it
+// takes precedence over the correlation extractor.
+func (hook *AppInsightsHook) SetSpanBridge(bridge SpanBridge) {
+	hook.spans = bridge
+}
+
+// activeSpan returns the trace and span IDs of the span active in the
+// entry's context, if valid.
+func (hook *AppInsightsHook) activeSpan(entry *logrus.Entry) (traceID, spanID string) {
+	if hook.spans.SpanContext == nil || entry.Context == nil {
+		return "", ""
+	}
+	traceID, spanID = hook.spans.SpanContext(entry.Context)
+	if !validID(traceID, 32) {
+		return "", ""
+	}
+	if !validID(spanID, 16) {
+		spanID = ""
+	}
+	return traceID, spanID
+}
+
+// validID reports whether id is a non-zero lowercase hex ID of n digits, as
+// invalid OpenTelemetry IDs are rendered as zeros.
+func validID(id string, n int) bool {
+	if len(id) != n {
+		return false
+	}
+	zero := true
+	for _, c := range id {
+		switch {
+		case c == '0':
+		case c >= '1' && c <= '9', c >= 'a' && c <= 'f':
+			zero = false
+		default:
+			return false
+		}
+	}
+	return !zero
+}
+
+// addSpanEvent records the entry as an event on the span active in its
+// context, with the properties of item as attributes.
+func (hook *AppInsightsHook) addSpanEvent(entry *logrus.Entry, item appinsights.Telemetry) {
+	if hook.spans.AddEvent == nil || entry.Context == nil {
+		return
+	}
+	attributes := make(map[string]string, len(item.GetProperties()))
+	for k, v := range item.GetProperties() {
+		attributes[k] = v
+	}
+	hook.spans.AddEvent(entry.Context, entry.Message, attributes)
+}
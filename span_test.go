@@ -0,0 +1,95 @@
+package logrus_appinsights
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type spanKey struct{}
+
+type testSpan struct {
+	traceID, spanID string
+	events          []string
+	attributes      []map[string]string
+}
+
+func testSpanBridge() SpanBridge {
+	return SpanBridge{
+		SpanContext: func(ctx context.Context) (string, string) {
+			if span, ok := ctx.Value(spanKey{}).(*testSpan); ok {
+				return span.traceID, span.spanID
+			}
+			return "00000000000000000000000000000000", "0000000000000000"
+		},
+		AddEvent: func(ctx context.Context, name string, attributes map[string]string) {
+			if span, ok := ctx.Value(spanKey{}).(*testSpan); ok {
+				span.events = append(span.events, name)
+				span.attributes = append(span.attributes, attributes)
+			}
+		},
+	}
+}
+
+func TestSetSpanBridge(t *testing.T) {
+	assert := assert.New(t)
+
+	const (
+		traceID = "0af7651916cd43dd8448eb211c80319c"
+		spanID  = "b7ad6b7169203331"
+	)
+
+	tests := []struct {
+		span      *testSpan
+		fields    logrus.Fields
+		operation string
+		parent    string
+	}{
+		{nil, nil, "", ""},
+		{&testSpan{traceID: traceID, spanID: spanID}, nil, traceID, spanID},
+		{&testSpan{traceID: traceID, spanID: "0000000000000000"}, nil, traceID, ""},
+		{&testSpan{traceID: "0AF7651916CD43DD8448EB211C80319C", spanID: spanID}, nil, "", ""},
+		{&testSpan{traceID: traceID, spanID: spanID}, logrus.Fields{TraceIDKey: "4bf92f3577b34da6a3ce929d0e0e4736"}, "4bf92f3577b34da6a3ce929d0e0e4736", ""},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetSpanBridge(testSpanBridge())
+		ctx := context.Background()
+		if tt.span != nil {
+			ctx = context.WithValue(ctx, spanKey{}, tt.span)
+		}
+		entry := logrus.NewEntry(logrus.New()).WithContext(ctx).WithFields(tt.fields)
+		entry.Level = logrus.InfoLevel
+		entry.Message = "charged card"
+
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		tags := contracts.ContextTags(items[0].ContextTags()).Operation()
+		assert.Equal(tt.operation, tags.GetId(), target)
+		assert.Equal(tt.parent, tags.GetParentId(), target)
+		if tt.span != nil && assert.Equal([]string{"charged card"}, tt.span.events, target) {
+			assert.Equal("charged card", tt.span.attributes[0]["message"], target)
+		}
+	}
+}
+
+func TestSpanBridgeFallsBackToExtractor(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	hook.SetSpanBridge(SpanBridge{})
+	entry := logrus.NewEntry(logrus.New()).WithContext(WithOperation(context.Background(), "op", "parent"))
+
+	items, err := hook.buildItems(entry)
+	assert.NoError(err)
+	tags := contracts.ContextTags(items[0].ContextTags()).Operation()
+	assert.Equal("op", tags.GetId())
+	assert.Equal("parent", tags.GetParentId())
+}
@@ -0,0 +1,150 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+)
+
+// SelfReportEventName is the name of the event periodically reporting the
+// hook's delivery statistics.
+const SelfReportEventName = "logrus_appinsights.delivery"
+
+// Stats describes the delivery of the telemetry sent by a hook.
+type Stats struct {
+	// ItemsAccepted is the number of items accepted by Application Insights.
+	ItemsAccepted uint64
+	// ItemsRejected is the number of items Application Insights refused.
+	ItemsRejected uint64
+
+	// Delivery latency percentiles, from the time entries were logged to the
+	// time the batch holding them was acknowledged, over the most recent
+	// deliveries. Only measured once latency tracking is enabled.
+	DeliveryLatencyP50 time.Duration
+	DeliveryLatencyP90 time.Duration
+	DeliveryLatencyP99 time.Duration
+
+	// SecretsRedacted is the number of secrets found and redacted by secret
+	// scanning.
+	SecretsRedacted uint64
+	// Redactions is the number of values modified or dropped by each filter
+	// and scrubbing rule: filter.<field> for the filter of a field,
+	// global_filter, pii.<kind> for personal data and secret.<kind> for
+	// secrets.
+	Redactions map[string]uint64
+
+	// SamplingRate is the percentage of entries currently sent by adaptive
+	// sampling, 100 unless enabled.
+	SamplingRate float64
+}
+
+// SetLatencyTracking sets whether the delivery latency of every item is
+// measured. Measuring requires decoding each batch before it is sent.
+func (hook *AppInsightsHook) SetLatencyTracking(enabled bool) {
+	if hook.stats != nil {
+		hook.stats.setTracking(enabled)
+	}
+}
+
+// Stats returns the delivery statistics of the hook, including its pipelines.
+func (hook *AppInsightsHook) Stats() Stats {
+	var stats Stats
+	if hook.stats != nil {
+		hook.stats.mu.Lock()
+		stats.ItemsAccepted, stats.ItemsRejected = hook.stats.accepted, hook.stats.rejected
+		hook.stats.mu.Unlock()
+		p := hook.stats.percentiles(0.5, 0.9, 0.99)
+		stats.DeliveryLatencyP50, stats.DeliveryLatencyP90, stats.DeliveryLatencyP99 = p[0], p[1], p[2]
+	}
+	redactions := hook.redactions.snapshot()
+	stats.SecretsRedacted, stats.Redactions = secretsRedacted(redactions), redactions
+	stats.SamplingRate = hook.adaptive.rate()
+	return stats
+}
+
+// WritePrometheus writes stats to w in the Prometheus text exposition format.
+func (stats Stats) WritePrometheus(w io.Writer) error {
+	_, err := fmt.Fprintf(w, `# HELP logrus_appinsights_items_accepted_total Items accepted by Application Insights.
+# TYPE logrus_appinsights_items_accepted_total counter
+logrus_appinsights_items_accepted_total %d
+# HELP logrus_appinsights_items_rejected_total Items rejected by Application Insights.
+# TYPE logrus_appinsights_items_rejected_total counter
+logrus_appinsights_items_rejected_total %d
+# HELP logrus_appinsights_delivery_latency_seconds Time from logging to acknowledgement by Application Insights.
+# TYPE logrus_appinsights_delivery_latency_seconds summary
+logrus_appinsights_delivery_latency_seconds{quantile="0.5"} %g
+logrus_appinsights_delivery_latency_seconds{quantile="0.9"} %g
+logrus_appinsights_delivery_latency_seconds{quantile="0.99"} %g
+# HELP logrus_appinsights_secrets_redacted_total Secrets redacted by secret scanning.
+# TYPE logrus_appinsights_secrets_redacted_total counter
+logrus_appinsights_secrets_redacted_total %d
+# HELP logrus_appinsights_sampling_rate_percent Percentage of entries currently sent by adaptive sampling.
+# TYPE logrus_appinsights_sampling_rate_percent gauge
+logrus_appinsights_sampling_rate_percent %g
+# HELP logrus_appinsights_redactions_total Values modified or dropped by filtering and scrubbing rules.
+# TYPE logrus_appinsights_redactions_total counter
+`, stats.ItemsAccepted, stats.ItemsRejected,
+		stats.DeliveryLatencyP50.Seconds(), stats.DeliveryLatencyP90.Seconds(), stats.DeliveryLatencyP99.Seconds(),
+		stats.SecretsRedacted, stats.SamplingRate)
+	if err != nil {
+		return err
+	}
+	rules := make([]string, 0, len(stats.Redactions))
+	for rule := range stats.Redactions {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		if _, err := fmt.Fprintf(w, "logrus_appinsights_redactions_total{rule=%q} %d\n", rule, stats.Redactions[rule]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSelfReportInterval sets the hook to send its delivery statistics as an
+// event named SelfReportEventName at every interval. A zero interval stops
+// reporting.
+func (hook *AppInsightsHook) SetSelfReportInterval(interval time.Duration) {
+	if hook.reporter != nil {
+		close(hook.reporter)
+		hook.reporter = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	hook.reporter = make(chan struct{})
+	go hook.selfReport(interval, hook.reporter)
+}
+
+func (hook *AppInsightsHook) selfReport(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			hook.track(hook.Stats().event())
+		}
+	}
+}
+
+// event returns the self-report event for stats.
+func (stats Stats) event() *appinsights.EventTelemetry {
+	event := appinsights.NewEventTelemetry(SelfReportEventName)
+	event.Measurements["items_accepted"] = float64(stats.ItemsAccepted)
+	event.Measurements["items_rejected"] = float64(stats.ItemsRejected)
+	event.Measurements["delivery_latency_p50_ms"] = stats.DeliveryLatencyP50.Seconds() * 1000
+	event.Measurements["delivery_latency_p90_ms"] = stats.DeliveryLatencyP90.Seconds() * 1000
+	event.Measurements["delivery_latency_p99_ms"] = stats.DeliveryLatencyP99.Seconds() * 1000
+	event.Measurements["secrets_redacted"] = float64(stats.SecretsRedacted)
+	event.Measurements["sampling_rate"] = stats.SamplingRate
+	for rule, n := range stats.Redactions {
+		event.Measurements["redactions."+rule] = float64(n)
+	}
+	return event
+}
@@ -0,0 +1,70 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{}
+	assert.Equal(Stats{SamplingRate: 100}, hook.Stats())
+
+	hook.stats = &deliveryStats{}
+	hook.SetLatencyTracking(true)
+	assert.True(hook.stats.isTracking())
+	hook.stats.record(3, 1, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second})
+
+	stats := hook.Stats()
+	assert.Equal(uint64(3), stats.ItemsAccepted)
+	assert.Equal(uint64(1), stats.ItemsRejected)
+	assert.Equal(2*time.Second, stats.DeliveryLatencyP50)
+	assert.Equal(3*time.Second, stats.DeliveryLatencyP99)
+
+	// pipelines share the statistics of the hook
+	assert.Equal(stats, hook.NewPipeline().Stats())
+}
+
+func TestStatsWritePrometheus(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		stats    Stats
+		expected []string
+	}{
+		{Stats{}, []string{
+			"logrus_appinsights_items_accepted_total 0\n",
+			`logrus_appinsights_delivery_latency_seconds{quantile="0.99"} 0` + "\n",
+		}},
+		{Stats{ItemsAccepted: 10, ItemsRejected: 2, DeliveryLatencyP50: 1500 * time.Millisecond}, []string{
+			"logrus_appinsights_items_accepted_total 10\n",
+			"logrus_appinsights_items_rejected_total 2\n",
+			`logrus_appinsights_delivery_latency_seconds{quantile="0.5"} 1.5` + "\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		buffer := new(bytes.Buffer)
+		assert.NoError(tt.stats.WritePrometheus(buffer), target)
+		for _, line := range tt.expected {
+			assert.True(strings.Contains(buffer.String(), line), target)
+		}
+	}
+}
+
+func TestStatsEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	event := Stats{ItemsAccepted: 4, DeliveryLatencyP90: 250 * time.Millisecond}.event()
+	assert.Equal(SelfReportEventName, event.Name)
+	assert.Equal(float64(4), event.Measurements["items_accepted"])
+	assert.Equal(float64(250), event.Measurements["delivery_latency_p90_ms"])
+}
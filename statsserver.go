@@ -0,0 +1,38 @@
+package logrus_appinsights
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// StatsHandler returns a handler serving the delivery statistics of the hook
+// as JSON at /stats and in the Prometheus text exposition format at
+// /metrics, for applications mounting it on their own mux.
+func (hook *AppInsightsHook) StatsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hook.Stats().event().Measurements)
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		hook.Stats().WritePrometheus(w)
+	})
+	return mux
+}
+
+// ServeStats listens on addr and serves StatsHandler until the hook is
+// closed, for applications that cannot expose it through their own server,
+// e.g. to be scraped by a sidecar. It returns once listening, with the
+// address listened on.
+func (hook *AppInsightsHook) ServeStats(addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: hook.StatsHandler()}
+	go server.Serve(listener)
+	hook.statsServers = append(hook.statsServers, server)
+	return listener.Addr(), nil
+}
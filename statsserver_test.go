@@ -0,0 +1,65 @@
+package logrus_appinsights
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := AppInsightsHook{stats: &deliveryStats{}}
+	hook.stats.record(3, 1, []time.Duration{2 * time.Second})
+
+	tests := []struct {
+		path        string
+		contentType string
+		expected    string
+	}{
+		{"/stats", "application/json", `"items_accepted":3`},
+		{"/metrics", "text/plain; version=0.0.4", "logrus_appinsights_items_rejected_total 1\n"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		recorder := httptest.NewRecorder()
+		hook.StatsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", tt.path, nil))
+		assert.Equal(http.StatusOK, recorder.Code, target)
+		assert.Equal(tt.contentType, recorder.Header().Get("Content-Type"), target)
+		assert.True(strings.Contains(recorder.Body.String(), tt.expected), target)
+	}
+}
+
+func TestServeStats(t *testing.T) {
+	assert := assert.New(t)
+
+	hook, err := New("test", Config{InstrumentationKey: "key", StatsAddress: "127.0.0.1:0"})
+	assert.NoError(err)
+	assert.Len(hook.statsServers, 1)
+
+	addr, err := hook.ServeStats("127.0.0.1:0")
+	assert.NoError(err)
+	resp, err := http.Get("http://" + addr.String() + "/stats")
+	assert.NoError(err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	measurements := map[string]float64{}
+	assert.NoError(json.Unmarshal(body, &measurements))
+	assert.Contains(measurements, "delivery_latency_p99_ms")
+
+	hook.Close()
+	_, err = http.Get("http://" + addr.String() + "/stats")
+	assert.Error(err)
+
+	_, err = New("test", Config{InstrumentationKey: "key", StatsAddress: "invalid"})
+	assert.Error(err)
+}
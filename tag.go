@@ -0,0 +1,46 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+)
+
+// MapFieldToTag sends the values of field as the context tag instead of a
+// property, one of the contracts keys such as contracts.UserAuthUserId and
+// contracts.SessionId, enabling the Users and Sessions experiences, or
+// contracts.CloudRole and contracts.OperationName. Mapped fields take
+// precedence over the tags set otherwise.
+func (hook *AppInsightsHook) MapFieldToTag(field, tag string) error {
+	if !isContextTag(tag) {
+		return fmt.Errorf("%q is not a context tag", tag)
+	}
+	if hook.tagFields == nil {
+		hook.tagFields = make(map[string]string)
+	}
+	hook.tagFields[field] = tag
+	return nil
+}
+
+// addFieldTags sets the context tags mapped to the fields of entry on items.
+func (hook *AppInsightsHook) addFieldTags(entry *logrus.Entry, items ...appinsights.Telemetry) {
+	for field, tag := range hook.tagFields {
+		v, ok := entry.Data[field]
+		if !ok {
+			continue
+		}
+		value := hook.scrub(fmt.Sprintf("%v", formatData(v)))
+		for _, item := range items {
+			item.ContextTags()[tag] = value
+		}
+	}
+}
+
+// SetOperationNameField sends the values of field, such as the route or job
+// name, as the operation name tag, grouping the items of a logical operation
+// in the portal.
+func (hook *AppInsightsHook) SetOperationNameField(field string) {
+	hook.MapFieldToTag(field, contracts.OperationName)
+}
@@ -0,0 +1,79 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapFieldToTag(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		field     string
+		tag       string
+		value     interface{}
+		expectErr bool
+		expected  string
+	}{
+		{"user", contracts.UserAuthUserId, "alice", false, "alice"},
+		{"anonymous", contracts.UserId, 42, false, "42"},
+		{"session", contracts.SessionId, "s-1", false, "s-1"},
+		{"role", contracts.CloudRole, "api", false, "api"},
+		{"route", contracts.OperationName, "GET /orders", false, "GET /orders"},
+		{"device", contracts.DeviceId, "d-1", false, "d-1"},
+		{"role", "cloud.role", "api", true, ""},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		err := hook.MapFieldToTag(tt.field, tt.tag)
+		if tt.expectErr {
+			assert.Error(err, target)
+			continue
+		}
+		assert.NoError(err, target)
+
+		hook.SetRequestsEnabled(true)
+		entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+			tt.field:      tt.value,
+			HTTPMethodKey: "GET",
+			HTTPURLKey:    "/orders",
+		})
+		items, err := hook.buildItems(entry)
+		assert.NoError(err, target)
+		for _, item := range items {
+			assert.Equal(tt.expected, item.ContextTags()[tt.tag], target)
+			assert.NotContains(item.GetProperties(), tt.field, target)
+		}
+	}
+}
+
+func TestSetOperationNameField(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields   logrus.Fields
+		expected string
+	}{
+		{logrus.Fields{}, ""},
+		{logrus.Fields{"operation": "GET /orders/{id}"}, "GET /orders/{id}"},
+		{logrus.Fields{"operation": "nightly-export", "tag": "fieldTag"}, "nightly-export"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetOperationNameField("operation")
+		items, err := hook.buildItems(logrus.NewEntry(logrus.New()).WithFields(tt.fields))
+		assert.NoError(err, target)
+		assert.Equal(tt.expected, contracts.ContextTags(items[0].ContextTags()).Operation().GetName(), target)
+		assert.NotContains(items[0].GetProperties(), "operation", target)
+	}
+}
@@ -0,0 +1,32 @@
+package logrus_appinsights
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TimeSource returns the timestamp of the items built from an entry.
+type TimeSource func(entry *logrus.Entry) time.Time
+
+// EntryTime is the default time source, returning the time the entry was
+// logged as is, or now if it was not set. Replayed or backfilled entries keep
+// their original time.
+func EntryTime(entry *logrus.Entry) time.Time {
+	if entry.Time.IsZero() {
+		return time.Now()
+	}
+	return entry.Time
+}
+
+// ReceiveTime is a time source returning the time the hook receives the
+// entry, e.g. when the clocks of the hosts logging entries cannot be trusted.
+func ReceiveTime(entry *logrus.Entry) time.Time {
+	return time.Now()
+}
+
+// SetTimeSource sets how the timestamp of items is derived from entries,
+// EntryTime by default.
+func (hook *AppInsightsHook) SetTimeSource(source TimeSource) {
+	hook.timeSource = source
+}
@@ -0,0 +1,48 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTimeSource(t *testing.T) {
+	assert := assert.New(t)
+
+	logged := time.Date(2018, 5, 1, 10, 7, 30, 123456789, time.UTC)
+	fixed := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		source   TimeSource
+		fields   logrus.Fields
+		expected time.Time // zero for the receive time
+	}{
+		{nil, nil, logged},
+		{EntryTime, logrus.Fields{TypeKey: TypeEvent}, logged},
+		{ReceiveTime, nil, time.Time{}},
+		{func(*logrus.Entry) time.Time { return fixed }, logrus.Fields{DependencyTypeKey: "HTTP"}, fixed},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt.fields)
+
+		hook := AppInsightsHook{}
+		hook.SetTimeSource(tt.source)
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+		entry.Time = logged
+
+		before := time.Now()
+		items, err := hook.buildItems(entry)
+		after := time.Now()
+		assert.NoError(err, target)
+		if tt.expected.IsZero() {
+			assert.False(items[0].Time().Before(before), target)
+			assert.False(items[0].Time().After(after), target)
+		} else {
+			assert.Equal(tt.expected, items[0].Time(), target)
+		}
+	}
+}
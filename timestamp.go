@@ -0,0 +1,55 @@
+package logrus_appinsights
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// levelNames are the names of the logrus levels, sent as source_level.
+var levelNames = func() []string {
+	names := make([]string, len(logrus.AllLevels))
+	for _, level := range logrus.AllLevels {
+		names[level] = level.String()
+	}
+	return names
+}()
+
+// levelName returns the name of level.
+func levelName(level logrus.Level) string {
+	if int(level) < len(levelNames) {
+		return levelNames[level]
+	}
+	return level.String()
+}
+
+// SetCoarseTimestamps sets whether the source_timestamp property is sent in
+// RFC 3339 format in UTC with second precision, formatted once per second,
+// instead of with nanosecond precision in the local time zone, saving the
+// formatting of every entry's time.
+func (hook *AppInsightsHook) SetCoarseTimestamps(enabled bool) {
+	hook.coarseTimes = enabled
+}
+
+type formattedSecond struct {
+	unix      int64
+	formatted string
+}
+
+// lastSecond caches the formatted second of the latest coarse timestamp.
+var lastSecond atomic.Value
+
+// sourceTimestamp returns the source_timestamp property of t.
+func (hook *AppInsightsHook) sourceTimestamp(t time.Time) string {
+	if !hook.coarseTimes {
+		return t.String()
+	}
+	unix := t.Unix()
+	if last, ok := lastSecond.Load().(formattedSecond); ok && last.unix == unix {
+		return last.formatted
+	}
+	formatted := t.UTC().Format(time.RFC3339)
+	lastSecond.Store(formattedSecond{unix: unix, formatted: formatted})
+	return formatted
+}
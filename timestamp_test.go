@@ -0,0 +1,58 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCoarseTimestamps(t *testing.T) {
+	assert := assert.New(t)
+
+	local := time.FixedZone("CET", 3600)
+	tests := []struct {
+		time     time.Time
+		coarse   bool
+		expected string
+	}{
+		{time.Date(2020, 1, 2, 3, 4, 5, 678, local), false, "2020-01-02 03:04:05.000000678 +0100 CET"},
+		{time.Date(2020, 1, 2, 3, 4, 5, 678, local), true, "2020-01-02T02:04:05Z"},
+		{time.Date(2020, 1, 2, 3, 4, 5, 999999999, local), true, "2020-01-02T02:04:05Z"},
+		{time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC), true, "2020-01-02T03:04:06Z"},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.SetCoarseTimestamps(tt.coarse)
+		entry := logrus.NewEntry(logrus.New())
+		entry.Time, entry.Level = tt.time, logrus.WarnLevel
+		props := hook.NewPipeline().buildProperties(entry)
+		assert.Equal(tt.expected, props["source_timestamp"], target)
+		assert.Equal("warning", props["source_level"], target)
+	}
+
+	for _, level := range logrus.AllLevels {
+		assert.Equal(level.String(), levelName(level))
+	}
+	assert.Equal(logrus.Level(42).String(), levelName(42))
+}
+
+func BenchmarkSourceTimestamp(b *testing.B) {
+	for _, coarse := range []bool{false, true} {
+		b.Run(fmt.Sprintf("coarse=%v", coarse), func(b *testing.B) {
+			hook := AppInsightsHook{}
+			hook.SetCoarseTimestamps(coarse)
+			now := time.Now()
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				hook.sourceTimestamp(now)
+			}
+		})
+	}
+}
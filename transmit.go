@@ -0,0 +1,156 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+// envelopeTimeFormat is the format of envelope times, with microseconds.
+const envelopeTimeFormat = "2006-01-02T15:04:05.999999Z"
+
+// backendResponse is the body returned by the ingestion endpoint.
+type backendResponse struct {
+	ItemsReceived int `json:"itemsReceived"`
+	ItemsAccepted int `json:"itemsAccepted"`
+	Errors        []struct {
+		Index      int    `json:"index"`
+		StatusCode int    `json:"statusCode"`
+		Message    string `json:"message"`
+	} `json:"errors"`
+}
+
+// envelop wraps item in an envelope the same way the client does before
+// handing it to its channel.
+func envelop(ctx *appinsights.TelemetryContext, item appinsights.Telemetry) *contracts.Envelope {
+	if props := item.GetProperties(); props != nil {
+		for k, v := range ctx.CommonProperties {
+			if _, ok := props[k]; !ok {
+				props[k] = v
+			}
+		}
+	}
+
+	tdata := item.TelemetryData()
+	data := contracts.NewData()
+	data.BaseType = tdata.BaseType()
+	data.BaseData = tdata
+
+	envelope := contracts.NewEnvelope()
+	envelope.Name = tdata.EnvelopeName(strings.Replace(ctx.InstrumentationKey(), "-", "", -1))
+	envelope.Data = data
+	envelope.IKey = ctx.InstrumentationKey()
+
+	timestamp := item.Time()
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	envelope.Time = timestamp.UTC().Format(envelopeTimeFormat)
+
+	envelope.Tags = make(map[string]string, len(ctx.Tags))
+	for k, v := range ctx.Tags {
+		envelope.Tags[k] = v
+	}
+	for k, v := range item.ContextTags() {
+		envelope.Tags[k] = v
+	}
+
+	tdata.Sanitize()
+	contracts.SanitizeTags(envelope.Tags)
+	return envelope
+}
+
+// transmit submits envelopes encoded with encoding to endpoint using client,
+// or the default HTTP client if nil, and returns an error unless every one of
+// them was accepted.
+func transmit(client *http.Client, endpoint string, envelopes []*contracts.Envelope, encoding payloadEncoding) error {
+	payload, err := encodePayload(envelopes, encoding)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Type", "application/x-json-stream")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == StatusStoredOffline {
+		return ErrStoredOffline
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("Application Insights rejected telemetry with status %d", resp.StatusCode)
+	}
+	response := backendResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode == http.StatusOK {
+			// some proxies reply with an empty body, trust the status code
+			return nil
+		}
+		return err
+	}
+	if response.ItemsAccepted < len(envelopes) {
+		if len(response.Errors) > 0 {
+			return fmt.Errorf("Application Insights accepted %d of %d items: %s", response.ItemsAccepted, len(envelopes), response.Errors[0].Message)
+		}
+		return fmt.Errorf("Application Insights accepted %d of %d items", response.ItemsAccepted, len(envelopes))
+	}
+	return nil
+}
+
+// encodePayload returns envelopes encoded as a gzipped JSON stream.
+func encodePayload(envelopes []*contracts.Envelope, encoding payloadEncoding) (*bytes.Buffer, error) {
+	payload := new(bytes.Buffer)
+	gzipWriter := getGzipWriter(payload, encoding.level)
+	defer putGzipWriter(gzipWriter, encoding.level)
+	if encoding.preSerialize {
+		stream := getStreamBuffer()
+		defer putStreamBuffer(stream)
+		if err := encodeStream(stream, envelopes); err != nil {
+			return nil, err
+		}
+		if _, err := gzipWriter.Write(stream.Bytes()); err != nil {
+			return nil, err
+		}
+	} else if err := encodeStream(gzipWriter, envelopes); err != nil {
+		gzipWriter.Close()
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// encodeStream writes envelopes to w as a JSON stream.
+func encodeStream(w io.Writer, envelopes []*contracts.Envelope) error {
+	encoder := json.NewEncoder(w)
+	for _, e := range envelopes {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
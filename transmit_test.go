@@ -0,0 +1,103 @@
+package logrus_appinsights
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFireAndWait(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		statusCode int
+		body       string
+		expectErr  bool
+	}{
+		{http.StatusOK, `{"itemsReceived":1,"itemsAccepted":1,"errors":[]}`, false},
+		{http.StatusOK, ``, false},
+		{http.StatusPartialContent, `{"itemsReceived":1,"itemsAccepted":0,"errors":[{"index":0,"statusCode":400,"message":"invalid"}]}`, true},
+		{http.StatusInternalServerError, ``, true},
+		{http.StatusTooManyRequests, ``, true},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		var received jsonPayload
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reader, err := gzip.NewReader(r.Body)
+			if err == nil {
+				buffer := new(bytes.Buffer)
+				buffer.ReadFrom(reader)
+				received, _ = parsePayload(buffer.Bytes())
+			}
+			w.WriteHeader(tt.statusCode)
+			w.Write([]byte(tt.body))
+		}))
+
+		hook, err := New("TestClient", Config{
+			InstrumentationKey: "NotEmpty",
+			EndpointUrl:        server.URL,
+		})
+		assert.NoError(err, target)
+
+		entry := logrus.NewEntry(logrus.New()).WithField("tag", "fieldTag")
+		entry.Level = logrus.ErrorLevel
+		entry.Message = "audit record"
+
+		err = hook.FireAndWait(entry)
+		server.Close()
+
+		if tt.expectErr {
+			assert.Error(err, target)
+		} else {
+			assert.NoError(err, target)
+		}
+		if assert.Len(received, 1, target) {
+			assert.NoError(received[0].assertPath("iKey", "NotEmpty"), target)
+			tags, _ := received[0].getPath("tags")
+			assert.Equal("TestClient", tags.(map[string]interface{})["ai.cloud.role"], target)
+			assert.NoError(received[0].assertPath("data.baseData.message", "audit record"), target)
+			assert.NoError(received[0].assertPath("data.baseData.properties.tag", "fieldTag"), target)
+			assert.NoError(received[0].assertPath("data.baseData.severityLevel", 3), target)
+		}
+	}
+}
+
+func BenchmarkEncodePayload(b *testing.B) {
+	client := appinsights.NewTelemetryClient("key")
+	envelopes := make([]*contracts.Envelope, 100)
+	for i := range envelopes {
+		trace := appinsights.NewTraceTelemetry("my message", appinsights.Information)
+		trace.Properties["tag"] = "fieldTag"
+		trace.Properties["request_id"] = newID(16)
+		envelopes[i] = envelop(client.Context(), trace)
+	}
+
+	for _, level := range []int{gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression} {
+		for _, preSerialize := range []bool{false, true} {
+			encoding := payloadEncoding{level: level, preSerialize: preSerialize}
+			b.Run(fmt.Sprintf("level=%d/preserialize=%t", level, preSerialize), func(b *testing.B) {
+				b.ReportAllocs()
+				var size int
+				for i := 0; i < b.N; i++ {
+					payload, err := encodePayload(envelopes, encoding)
+					if err != nil {
+						b.Fatal(err)
+					}
+					size = payload.Len()
+				}
+				b.ReportMetric(float64(size), "bytes/payload")
+			})
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package logrus_appinsights
+
+import "reflect"
+
+// AddTypeFilter adds a filter applied to every value of the type of sample,
+// whatever the field holding it, e.g. to format identifiers of a domain type
+// consistently. sample may also be the reflect.Type itself. The filter of a
+// field, if any, takes precedence.
+func (hook *AppInsightsHook) AddTypeFilter(sample interface{}, fn func(interface{}) interface{}) {
+	t, ok := sample.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(sample)
+	}
+	if hook.typeFilters == nil {
+		hook.typeFilters = make(map[reflect.Type]func(interface{}) interface{})
+	}
+	hook.redactionCounts()
+	hook.typeFilters[t] = fn
+}
+
+// filterOf returns the filter of the field k holding v, and the type it
+// applies to if it is a type filter.
+func (hook *AppInsightsHook) filterOf(k string, v interface{}) (func(interface{}) interface{}, reflect.Type, bool) {
+	hook.settings.RLock()
+	fn, ok := hook.filters[k]
+	hook.settings.RUnlock()
+	if ok {
+		return fn, nil, true
+	}
+	if v == nil || len(hook.typeFilters) == 0 {
+		return nil, nil, false
+	}
+	t := reflect.TypeOf(v)
+	if fn, ok := hook.typeFilters[t]; ok {
+		return fn, t, true
+	}
+	return nil, nil, false
+}
+
+// filterRule returns the name of the redaction rule of the filter of the
+// field k, or of the type t if not nil.
+func filterRule(k string, t reflect.Type) string {
+	if t != nil {
+		return "type_filter." + t.String()
+	}
+	return "filter." + k
+}
@@ -0,0 +1,52 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type userID string
+
+type account struct {
+	ID   userID
+	Name string
+}
+
+func TestAddTypeFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	mask := func(v interface{}) interface{} { return "user-" + strings.Repeat("*", len(v.(userID))) }
+	tests := []struct {
+		sample   interface{}
+		fields   logrus.Fields
+		expected map[string]string
+	}{
+		{userID(""), logrus.Fields{"owner": userID("jane"), "name": "jane"}, map[string]string{"owner": "user-****", "name": "jane"}},
+		{reflect.TypeOf(userID("")), logrus.Fields{"owner": userID("jo")}, map[string]string{"owner": "user-**"}},
+		{userID(""), logrus.Fields{"account.ID": userID("jane"), "user": userID("jo")}, map[string]string{"account.ID": "user-****", "user": "hidden"}},
+		{userID(""), logrus.Fields{"account": account{ID: "jane", Name: "Jane"}}, map[string]string{"account.ID": "user-****", "account.Name": "Jane"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{filters: make(map[string]func(interface{}) interface{})}
+		hook.SetFlattening(".", 2)
+		hook.AddTypeFilter(tt.sample, mask)
+		hook.AddFilter("user", func(interface{}) interface{} { return "hidden" })
+		props := hook.NewPipeline().buildProperties(logrus.NewEntry(logrus.New()).WithFields(tt.fields))
+		for k, v := range tt.expected {
+			assert.Equal(v, props[k], target)
+		}
+	}
+
+	hook := AppInsightsHook{}
+	hook.AddTypeFilter(userID(""), mask)
+	hook.buildProperties(logrus.NewEntry(logrus.New()).WithField("owner", userID("jane")))
+	assert.Equal(map[string]uint64{"type_filter.logrus_appinsights.userID": 1}, hook.Stats().Redactions)
+}
@@ -0,0 +1,39 @@
+package logrus_appinsights
+
+import "strconv"
+
+// HTTPStatusClasses maps HTTP status codes to their class, such as "2xx" or
+// "5xx", for use with AddValueMapping.
+var HTTPStatusClasses = httpStatusClasses()
+
+func httpStatusClasses() map[string]string {
+	classes := make(map[string]string, 500)
+	for code := 100; code < 600; code++ {
+		classes[strconv.Itoa(code)] = strconv.Itoa(code/100) + "xx"
+	}
+	return classes
+}
+
+// AddValueMapping sends the values of field name found in mapping as the
+// label they map to, e.g. an errno as its name or a status code as its class,
+// other values being sent as they are. Labels are checked against the allowed
+// values of the field, and mappings added later replace earlier labels.
+func (hook *AppInsightsHook) AddValueMapping(name string, mapping map[string]string) {
+	if hook.valueMappings == nil {
+		hook.valueMappings = make(map[string]map[string]string)
+	}
+	if hook.valueMappings[name] == nil {
+		hook.valueMappings[name] = make(map[string]string, len(mapping))
+	}
+	for value, label := range mapping {
+		hook.valueMappings[name][value] = label
+	}
+}
+
+// mapValue returns the label v of field name maps to, or v.
+func (hook *AppInsightsHook) mapValue(name, v string) string {
+	if label, ok := hook.valueMappings[name][v]; ok {
+		return label
+	}
+	return v
+}
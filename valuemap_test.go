@@ -0,0 +1,41 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddValueMapping(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		fields   logrus.Fields
+		expected map[string]string
+	}{
+		{logrus.Fields{"status": 204, "errno": 2}, map[string]string{"status": "2xx", "errno": "ENOENT"}},
+		{logrus.Fields{"status": "503", "errno": 13}, map[string]string{"status": "5xx", "errno": "EACCES"}},
+		{logrus.Fields{"status": 42, "errno": 99}, map[string]string{"status": "42", "errno": "99"}},
+		{logrus.Fields{"status": 418, "errno": 1}, map[string]string{"status": OtherValue, "errno": "EPERM"}},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		hook := AppInsightsHook{}
+		hook.AddValueMapping("status", HTTPStatusClasses)
+		hook.AddAllowedValues("status", "2xx", "5xx", "42")
+		hook.AddValueMapping("errno", map[string]string{"1": "EPERM", "2": "ENOENT"})
+		hook.AddValueMapping("errno", map[string]string{"13": "EACCES"})
+		entry := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+
+		for _, h := range []*AppInsightsHook{&hook, hook.NewPipeline()} {
+			props := h.buildProperties(entry)
+			for k, v := range tt.expected {
+				assert.Equal(v, props[k], target)
+			}
+		}
+	}
+}
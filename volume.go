@@ -0,0 +1,81 @@
+package logrus_appinsights
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+)
+
+// BudgetExceededMessage is the message of the trace sent once the volume
+// budget of the hour is exceeded.
+const BudgetExceededMessage = "logrus_appinsights.budget_exceeded"
+
+// volumeBudget caps the entries and approximate bytes sent per hour.
+type volumeBudget struct {
+	mu       sync.Mutex
+	maxItems int64
+	maxBytes int64
+	items    int64
+	bytes    int64
+	start    time.Time
+	exceeded bool
+	now      func() time.Time
+}
+
+// SetVolumeBudget caps the entries sent per hour to itemsPerHour and their
+// approximate size to bytesPerHour, guarding against runaway logging. Once
+// either is exceeded, only Error, Fatal and Panic entries and exempt entries
+// are sent until the end of the hour, and a single trace with the message
+// BudgetExceededMessage reports it. Zero or less leaves the entries or bytes
+// uncapped.
+func (hook *AppInsightsHook) SetVolumeBudget(itemsPerHour, bytesPerHour int64) {
+	hook.volume = nil
+	if itemsPerHour > 0 || bytesPerHour > 0 {
+		hook.volume = &volumeBudget{maxItems: itemsPerHour, maxBytes: bytesPerHour, start: time.Now(), now: time.Now}
+	}
+}
+
+// withinVolume reports whether entry is within the volume budget, sending
+// the budget exceeded trace when it is first exceeded.
+func (hook *AppInsightsHook) withinVolume(entry *logrus.Entry) bool {
+	if hook.volume == nil {
+		return true
+	}
+	var size int64
+	if hook.volume.maxBytes > 0 {
+		size = estimateSize(entry)
+	}
+	critical := entry.Level <= logrus.ErrorLevel || hook.exempt(entry)
+	allowed, exceeded := hook.volume.allow(size, critical)
+	if exceeded != nil {
+		hook.track(exceeded)
+	}
+	return allowed
+}
+
+// allow counts an entry of size bytes, returning whether it is within budget
+// or critical, and the budget exceeded trace if it has just been exceeded.
+func (b *volumeBudget) allow(size int64, critical bool) (bool, *appinsights.TraceTelemetry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now := b.now(); now.Sub(b.start) >= time.Hour {
+		b.items, b.bytes, b.start, b.exceeded = 0, 0, now, false
+	}
+	b.items++
+	b.bytes += size
+	if b.exceeded {
+		return critical, nil
+	}
+	if (b.maxItems <= 0 || b.items <= b.maxItems) && (b.maxBytes <= 0 || b.bytes <= b.maxBytes) {
+		return true, nil
+	}
+	b.exceeded = true
+	trace := appinsights.NewTraceTelemetry(BudgetExceededMessage, appinsights.Warning)
+	trace.Properties["max_items_per_hour"] = strconv.FormatInt(b.maxItems, 10)
+	trace.Properties["max_bytes_per_hour"] = strconv.FormatInt(b.maxBytes, 10)
+	trace.Properties["budget_reset"] = b.start.Add(time.Hour).UTC().Format(time.RFC3339)
+	return critical, trace
+}
@@ -0,0 +1,65 @@
+package logrus_appinsights
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetVolumeBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		items, bytes int64
+		message      string
+		expected     int
+		exceeded     int
+	}{
+		{0, 0, "", 20, 0},
+		// 3 Info and 2 Error entries, then the 8 remaining Error entries
+		{5, 0, "", 5 + 1 + 8, 1},
+		{0, 5 * (envelopeOverhead + 200), strings.Repeat("x", 100), 5 + 1 + 8, 1},
+		{100, 0, "", 20, 0},
+	}
+
+	for _, tt := range tests {
+		target := fmt.Sprintf("%+v", tt)
+
+		client := newRecordingClient()
+		hook := AppInsightsHook{client: client}
+		hook.SetVolumeBudget(tt.items, tt.bytes)
+		pipeline := hook.NewPipeline()
+		for i := 0; i < 10; i++ {
+			for _, level := range []logrus.Level{logrus.InfoLevel, logrus.ErrorLevel} {
+				entry := logrus.NewEntry(logrus.New())
+				entry.Level, entry.Message = level, tt.message
+				assert.NoError(pipeline.Fire(entry), target)
+			}
+		}
+		tracked := client.tracked()
+		assert.Len(tracked, tt.expected, target)
+		exceeded := 0
+		for _, item := range tracked {
+			if trace, ok := item.(*appinsights.TraceTelemetry); ok && trace.Message == BudgetExceededMessage {
+				exceeded++
+			}
+		}
+		assert.Equal(tt.exceeded, exceeded, target)
+	}
+
+	hook := AppInsightsHook{client: newRecordingClient()}
+	hook.SetVolumeBudget(1, 0)
+	now := time.Now()
+	hook.volume.now = func() time.Time { return now }
+	entry := logrus.NewEntry(logrus.New())
+	entry.Level = logrus.InfoLevel
+	assert.True(hook.withinVolume(entry))
+	assert.False(hook.withinVolume(entry))
+	now = now.Add(time.Hour)
+	assert.True(hook.withinVolume(entry))
+}